@@ -18,9 +18,10 @@ export class HelloCdkStack extends cdk.Stack {
     super(scope, id, props);
 
     // Create DynamoDB table with primary key id (string)
+    // The stream includes old images so the function can see what a MODIFY changed
     const myTable = new dynamodb.Table(this, 'MyTable', {
       partitionKey: { name: 'id', type: dynamodb.AttributeType.STRING },
-      stream: StreamViewType.NEW_IMAGE,
+      stream: StreamViewType.NEW_AND_OLD_IMAGES,
     });
 
     // Create S3 bucket 
@@ -72,7 +73,8 @@ export class HelloCdkStack extends cdk.Stack {
       batchSize: 5,
       bisectBatchOnError: true,
       onFailure: new SqsDlq(dlQueue),
-      retryAttempts: 10
+      retryAttempts: 10,
+      reportBatchItemFailures: true, // The function returns the sequence number of the first record that failed
     }));
 
     // S3 Lambda function
@@ -118,11 +120,22 @@ export class HelloCdkStack extends cdk.Stack {
       runtime: lambda.Runtime.GO_1_X,
       handler: 'main',
       code: new lambda.AssetCode('src/sqs'), // Go source file is (relative to cdk.json): src/sqs/main.go
+      environment: {
+        // Messages that fail this many times are sent to the dead-letter queue
+        maxReceiveCount: '3',
+        deadLetterQueueUrl: dlQueue.queueUrl,
+      },
     });
 
+    // Let the SQS function send poison messages to the dead-letter queue
+    dlQueue.grantSendMessages(mySQSFunction);
+
     // Configure Lambda function to handle events from SQS queue.
+    // The function returns the IDs of the messages it could not process,
+    // so only those messages are returned to the queue.
     mySQSFunction.addEventSource(new SqsEventSource(myQueue, {
       batchSize: 10, // default
+      reportBatchItemFailures: true,
     }));
     
     // Barf out info about the resources
@@ -0,0 +1,5 @@
+module mymain
+
+go 1.15
+
+require github.com/aws/aws-lambda-go v1.28.0
@@ -8,20 +8,53 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 )
 
-func handler(ctx context.Context, e events.DynamoDBEvent) {
-	for _, record := range e.Records {
-		fmt.Printf("Processing request data for event ID %s, type %s.\n", record.EventID, record.EventName)
+// Item is an item in the table
+type Item struct {
+	ID      string   `json:"id"`
+	Message string   `json:"Message"`
+	Labels  []string `json:"Labels"`
+}
+
+var processor = StreamProcessor{
+	NewItem: func() interface{} { return &Item{} },
+
+	OnInsert: func(ctx context.Context, newItem interface{}) error {
+		item := newItem.(*Item)
+		fmt.Printf("Inserted item %s: %s\n", item.ID, item.Message)
+
+		return nil
+	},
+
+	OnModify: func(ctx context.Context, oldItem, newItem interface{}, changes []FieldChange) error {
+		item := newItem.(*Item)
+
+		for _, c := range changes {
+			fmt.Printf("Item %s: %s changed from %v to %v\n", item.ID, c.Name, c.Old, c.New)
 
-		// Print new values for attributes of type String
-		// See
-		//   https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/dynamodb@v1.0.0/types#AttributeValue
-		//   for information on AttibuteValue.
-		for name, value := range record.Change.NewImage {
-			if value.DataType() == events.DataTypeString {
-				fmt.Printf("Attribute name: %s, value: %s\n", name, value.String())
+			// This is where you'd start downstream work off a change,
+			// such as reindexing the item when its labels change
+			if c.Name == "Labels" {
+				fmt.Printf("Reindex item %s with labels %v\n", item.ID, item.Labels)
 			}
 		}
+
+		return nil
+	},
+
+	OnRemove: func(ctx context.Context, oldItem interface{}) error {
+		item := oldItem.(*Item)
+		fmt.Printf("Removed item %s\n", item.ID)
+
+		return nil
+	},
+}
+
+func handler(ctx context.Context, e events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	for _, record := range e.Records {
+		fmt.Printf("Processing request data for event ID %s, type %s.\n", record.EventID, record.EventName)
 	}
+
+	return processor.ProcessEvent(ctx, e), nil
 }
 
 func main() {
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// FieldChange is a top-level attribute that a MODIFY record changed.
+// Old is nil if the attribute was added; New is nil if it was removed.
+type FieldChange struct {
+	Name string
+	Old  interface{}
+	New  interface{}
+}
+
+// StreamProcessor decodes the images in stream records into structs
+// and calls the callback for the kind of change.
+// Leave a callback nil to ignore that kind of change.
+type StreamProcessor struct {
+	// NewItem returns a pointer to an empty item, such as &Item{};
+	// images are decoded into it using its json tags
+	NewItem func() interface{}
+
+	OnInsert func(ctx context.Context, newItem interface{}) error
+	OnModify func(ctx context.Context, oldItem, newItem interface{}, changes []FieldChange) error
+	OnRemove func(ctx context.Context, oldItem interface{}) error
+}
+
+// attributeValue converts av into the value encoding/json would produce
+// when decoding the same data into an interface{}.
+// Sets have no order, so they're sorted, and the same set always has the same value.
+func attributeValue(av events.DynamoDBAttributeValue) (interface{}, error) {
+	switch av.DataType() {
+	case events.DataTypeString:
+		return av.String(), nil
+	case events.DataTypeNumber:
+		return json.Number(av.Number()), nil
+	case events.DataTypeBoolean:
+		return av.Boolean(), nil
+	case events.DataTypeNull:
+		return nil, nil
+	case events.DataTypeBinary:
+		return av.Binary(), nil
+	case events.DataTypeStringSet:
+		set := append([]string{}, av.StringSet()...)
+		sort.Strings(set)
+
+		return set, nil
+	case events.DataTypeBinarySet:
+		set := append([][]byte{}, av.BinarySet()...)
+		sort.Slice(set, func(i, j int) bool {
+			return bytes.Compare(set[i], set[j]) < 0
+		})
+
+		return set, nil
+	case events.DataTypeNumberSet:
+		set := append([]string{}, av.NumberSet()...)
+		sort.Strings(set)
+
+		numbers := []json.Number{}
+		for _, n := range set {
+			numbers = append(numbers, json.Number(n))
+		}
+
+		return numbers, nil
+	case events.DataTypeList:
+		list := []interface{}{}
+		for _, element := range av.List() {
+			v, err := attributeValue(element)
+			if err != nil {
+				return nil, err
+			}
+
+			list = append(list, v)
+		}
+
+		return list, nil
+	case events.DataTypeMap:
+		return imageValues(av.Map())
+	}
+
+	return nil, errors.New("Unsupported attribute data type")
+}
+
+// imageValues converts a stream image into a map of plain Go values
+func imageValues(image map[string]events.DynamoDBAttributeValue) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+
+	for name, av := range image {
+		v, err := attributeValue(av)
+		if err != nil {
+			return nil, errors.New("Could not convert attribute " + name + ": " + err.Error())
+		}
+
+		values[name] = v
+	}
+
+	return values, nil
+}
+
+// decodeImage decodes image into a new item from NewItem
+func (p StreamProcessor) decodeImage(image map[string]events.DynamoDBAttributeValue) (interface{}, map[string]interface{}, error) {
+	values, err := imageValues(image)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b, err := json.Marshal(values)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	item := p.NewItem()
+
+	err = json.Unmarshal(b, item)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return item, values, nil
+}
+
+// diffImages returns the attributes that differ between oldValues and newValues,
+// sorted by name
+func diffImages(oldValues, newValues map[string]interface{}) []FieldChange {
+	changes := []FieldChange{}
+
+	for name, o := range oldValues {
+		n, ok := newValues[name]
+		if !ok {
+			changes = append(changes, FieldChange{Name: name, Old: o})
+		} else if !reflect.DeepEqual(o, n) {
+			changes = append(changes, FieldChange{Name: name, Old: o, New: n})
+		}
+	}
+
+	for name, n := range newValues {
+		_, ok := oldValues[name]
+		if !ok {
+			changes = append(changes, FieldChange{Name: name, New: n})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+
+	return changes
+}
+
+// ProcessRecord decodes the images in record and calls the matching callback.
+// MODIFY records need a stream view type of NEW_AND_OLD_IMAGES to compute changes.
+func (p StreamProcessor) ProcessRecord(ctx context.Context, record events.DynamoDBEventRecord) error {
+	switch record.EventName {
+	case "INSERT":
+		if p.OnInsert == nil {
+			return nil
+		}
+
+		newItem, _, err := p.decodeImage(record.Change.NewImage)
+		if err != nil {
+			return err
+		}
+
+		return p.OnInsert(ctx, newItem)
+	case "MODIFY":
+		if p.OnModify == nil {
+			return nil
+		}
+
+		oldItem, oldValues, err := p.decodeImage(record.Change.OldImage)
+		if err != nil {
+			return err
+		}
+
+		newItem, newValues, err := p.decodeImage(record.Change.NewImage)
+		if err != nil {
+			return err
+		}
+
+		return p.OnModify(ctx, oldItem, newItem, diffImages(oldValues, newValues))
+	case "REMOVE":
+		if p.OnRemove == nil {
+			return nil
+		}
+
+		oldItem, _, err := p.decodeImage(record.Change.OldImage)
+		if err != nil {
+			return err
+		}
+
+		return p.OnRemove(ctx, oldItem)
+	}
+
+	return errors.New("Unknown event name: " + record.EventName)
+}
+
+// ProcessEvent processes the records in order and stops at the first failure.
+// It returns that record's sequence number as the checkpoint,
+// so Lambda retries the batch from there.
+func (p StreamProcessor) ProcessEvent(ctx context.Context, e events.DynamoDBEvent) events.DynamoDBEventResponse {
+	resp := events.DynamoDBEventResponse{
+		BatchItemFailures: []events.DynamoDBBatchItemFailure{},
+	}
+
+	for _, record := range e.Records {
+		err := p.ProcessRecord(ctx, record)
+		if err != nil {
+			resp.BatchItemFailures = append(resp.BatchItemFailures, events.DynamoDBBatchItemFailure{
+				ItemIdentifier: record.Change.SequenceNumber,
+			})
+
+			break
+		}
+	}
+
+	return resp
+}
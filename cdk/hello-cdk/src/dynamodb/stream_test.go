@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+type testItem struct {
+	ID     string   `json:"id"`
+	Count  int      `json:"count"`
+	Labels []string `json:"labels"`
+}
+
+func TestProcessRecord(t *testing.T) {
+	oldImage := map[string]events.DynamoDBAttributeValue{
+		"id":     events.NewStringAttribute("1"),
+		"count":  events.NewNumberAttribute("1"),
+		"labels": events.NewStringSetAttribute([]string{"Dog"}),
+		"note":   events.NewStringAttribute("old"),
+		"sizes":  events.NewNumberSetAttribute([]string{"2", "10"}),
+		"hashes": events.NewBinarySetAttribute([][]byte{{2}, {1}}),
+	}
+
+	newImage := map[string]events.DynamoDBAttributeValue{
+		"id":     events.NewStringAttribute("1"),
+		"count":  events.NewNumberAttribute("2"),
+		"labels": events.NewStringSetAttribute([]string{"Dog", "Cat"}),
+		"extra":  events.NewBooleanAttribute(true),
+		// The same sets in a different order aren't changes
+		"sizes":  events.NewNumberSetAttribute([]string{"10", "2"}),
+		"hashes": events.NewBinarySetAttribute([][]byte{{1}, {2}}),
+	}
+
+	var gotOld, gotNew *testItem
+	var gotChanges []FieldChange
+
+	p := StreamProcessor{
+		NewItem: func() interface{} { return &testItem{} },
+		OnModify: func(ctx context.Context, oldItem, newItem interface{}, changes []FieldChange) error {
+			gotOld = oldItem.(*testItem)
+			gotNew = newItem.(*testItem)
+			gotChanges = changes
+
+			return nil
+		},
+	}
+
+	record := events.DynamoDBEventRecord{
+		EventName: "MODIFY",
+		Change: events.DynamoDBStreamRecord{
+			OldImage: oldImage,
+			NewImage: newImage,
+		},
+	}
+
+	err := p.ProcessRecord(context.Background(), record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gotOld.Count != 1 || gotNew.Count != 2 || len(gotNew.Labels) != 2 {
+		t.Errorf("Images decoded incorrectly: old %+v, new %+v", gotOld, gotNew)
+	}
+
+	names := []string{}
+	for _, c := range gotChanges {
+		names = append(names, c.Name)
+	}
+
+	want := []string{"count", "extra", "labels", "note"}
+
+	if len(names) != len(want) {
+		t.Fatalf("Got changes %v, want %v", names, want)
+	}
+
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("Got changes %v, want %v", names, want)
+		}
+	}
+
+	if gotChanges[0].Old != json.Number("1") || gotChanges[0].New != json.Number("2") {
+		t.Errorf("Unexpected count change: %+v", gotChanges[0])
+	}
+
+	if gotChanges[1].Old != nil || gotChanges[3].New != nil {
+		t.Errorf("Expected added and removed attributes to have nil values: %+v", gotChanges)
+	}
+}
+
+func TestProcessEventCheckpoint(t *testing.T) {
+	p := StreamProcessor{
+		NewItem: func() interface{} { return &testItem{} },
+		OnInsert: func(ctx context.Context, newItem interface{}) error {
+			if newItem.(*testItem).ID == "bad" {
+				return errors.New("could not process item")
+			}
+
+			return nil
+		},
+	}
+
+	insert := func(id, seq string) events.DynamoDBEventRecord {
+		return events.DynamoDBEventRecord{
+			EventName: "INSERT",
+			Change: events.DynamoDBStreamRecord{
+				SequenceNumber: seq,
+				NewImage:       map[string]events.DynamoDBAttributeValue{"id": events.NewStringAttribute(id)},
+			},
+		}
+	}
+
+	e := events.DynamoDBEvent{
+		Records: []events.DynamoDBEventRecord{insert("1", "100"), insert("bad", "200"), insert("3", "300")},
+	}
+
+	resp := p.ProcessEvent(context.Background(), e)
+
+	if len(resp.BatchItemFailures) != 1 || resp.BatchItemFailures[0].ItemIdentifier != "200" {
+		t.Errorf("Expected a checkpoint at 200, got %v", resp.BatchItemFailures)
+	}
+}
@@ -3,19 +3,150 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
+	"strconv"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 )
 
-func handler(ctx context.Context, sqsEvent events.SQSEvent) error {
-	for _, message := range sqsEvent.Records {
-		fmt.Printf("The message %s for event source %s = %s \n", message.MessageId, message.EventSource, message.Body)
+// MessageProcessor processes one message.
+// Return an error to leave the message on the queue.
+type MessageProcessor interface {
+	ProcessMessage(ctx context.Context, message events.SQSMessage) error
+}
+
+// printProcessor prints each message
+type printProcessor struct{}
+
+func (p printProcessor) ProcessMessage(ctx context.Context, message events.SQSMessage) error {
+	fmt.Printf("The message %s for event source %s = %s \n", message.MessageId, message.EventSource, message.Body)
+	return nil
+}
+
+// DeadLetterHandler receives a failed message that has been received maxReceiveCount times.
+// If it returns nil, the message is deleted from the queue.
+type DeadLetterHandler interface {
+	HandleDeadLetter(ctx context.Context, message events.SQSMessage, cause error) error
+}
+
+// printDeadLetterHandler prints the message and leaves it on the queue
+type printDeadLetterHandler struct{}
+
+func (h printDeadLetterHandler) HandleDeadLetter(ctx context.Context, message events.SQSMessage, cause error) error {
+	fmt.Printf("Poison message %s: %s \n", message.MessageId, cause)
+	return cause
+}
+
+// queueDeadLetterHandler sends the message to the queue with URL queueURL
+type queueDeadLetterHandler struct {
+	client   *sqs.Client
+	queueURL string
+}
+
+func (h queueDeadLetterHandler) HandleDeadLetter(ctx context.Context, message events.SQSMessage, cause error) error {
+	input := &sqs.SendMessageInput{
+		QueueUrl:    aws.String(h.queueURL),
+		MessageBody: aws.String(message.Body),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"SourceMessageId": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(message.MessageId),
+			},
+			"Error": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(cause.Error()),
+			},
+		},
+	}
+
+	_, err := h.client.SendMessage(ctx, input)
+	if err != nil {
+		return err
 	}
 
+	fmt.Printf("Sent poison message %s to %s \n", message.MessageId, h.queueURL)
+
 	return nil
 }
 
+var processor MessageProcessor = printProcessor{}
+
+var deadLetterHandler DeadLetterHandler = printDeadLetterHandler{}
+
+var maxReceiveCount = 3
+
+func init() {
+	// Get the poison-message settings from the environment
+	count := os.Getenv("maxReceiveCount")
+	if count != "" {
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			fmt.Println("Could not parse maxReceiveCount '" + count + "'")
+		} else {
+			maxReceiveCount = n
+		}
+	}
+
+	queueURL := os.Getenv("deadLetterQueueUrl")
+	if queueURL != "" {
+		cfg, err := config.LoadDefaultConfig(context.TODO())
+		if err != nil {
+			fmt.Println("Got configuration error loading context: " + err.Error())
+			return
+		}
+
+		deadLetterHandler = queueDeadLetterHandler{
+			client:   sqs.NewFromConfig(cfg),
+			queueURL: queueURL,
+		}
+	}
+}
+
+func receiveCount(message events.SQSMessage) int {
+	n, err := strconv.Atoi(message.Attributes["ApproximateReceiveCount"])
+	if err != nil {
+		return 1
+	}
+
+	return n
+}
+
+// The event source mapping reports batch item failures,
+// so Lambda only returns the failed messages to the queue.
+func handler(ctx context.Context, sqsEvent events.SQSEvent) (events.SQSEventResponse, error) {
+	resp := events.SQSEventResponse{
+		BatchItemFailures: []events.SQSBatchItemFailure{},
+	}
+
+	for _, message := range sqsEvent.Records {
+		err := processor.ProcessMessage(ctx, message)
+		if err == nil {
+			continue
+		}
+
+		fmt.Printf("Could not process message %s: %s \n", message.MessageId, err)
+
+		// A message that keeps failing is a poison message
+		if maxReceiveCount > 0 && receiveCount(message) >= maxReceiveCount {
+			err = deadLetterHandler.HandleDeadLetter(ctx, message, err)
+			if err == nil {
+				continue
+			}
+		}
+
+		resp.BatchItemFailures = append(resp.BatchItemFailures, events.SQSBatchItemFailure{
+			ItemIdentifier: message.MessageId,
+		})
+	}
+
+	return resp, nil
+}
+
 func main() {
 	lambda.Start(handler)
 }
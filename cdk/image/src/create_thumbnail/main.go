@@ -10,7 +10,6 @@ import (
 	"image/png"
 	"io"
 	"math"
-	"os"
 	"strconv"
 	"strings"
 
@@ -21,20 +20,25 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
-// MyRequestParameters is the event we receive
-type MyRequestParameters struct {
+// MyEvent is the event we receive from the previous state.
+// Bucket and Key are set by the save object data function;
+// BucketName is the bucketName request parameter from CloudTrail.
+type MyEvent struct {
+	Bucket     string `json:"Bucket"`
 	BucketName string `json:"bucketName"`
-	Host       string `json:"Host"`
-	Key        string `json:"key"`
-	XID        string `json:"x-id"`
+	Key        string `json:"Key"`
 }
 
-/* MyEvent defines the event we get
-type MyEvent struct {
-	Bucket string `json:"Bucket"`
-	Key    string `json:"Key"`
+// ThumbnailResult is what we return to the next state
+type ThumbnailResult struct {
+	Bucket          string   `json:"bucket"`
+	SourceKey       string   `json:"sourceKey"`
+	ThumbnailKeys   []string `json:"thumbnailKeys"`
+	Width           int      `json:"width"`
+	Height          int      `json:"height"`
+	ThumbnailWidth  int      `json:"thumbnailWidth"`
+	ThumbnailHeight int      `json:"thumbnailHeight"`
 }
-*/
 
 func calculateRatioFit(srcWidth, srcHeight int, maxWidth, maxHeight float64) (int, int) {
 	ratio := math.Min(maxWidth/float64(srcWidth), maxHeight/float64(srcHeight))
@@ -85,19 +89,35 @@ func putObject(bucket, key string, body io.Reader) error {
 	return nil
 }
 
-func makeThumbnail(bucket, key string) (string, error) {
+func makeThumbnail(bucket, key string) (*ThumbnailResult, error) {
 	var maxWidth float64 = 80
 	var maxHeight float64 = 80
 
+	// Create new object name from existing key name
+	// uploads/filename.jpg -> uploads/filename, jpg
+	parts := strings.Split(key, ".")
+
+	if len(parts) < 2 {
+		msg := "Could not split '" + key + "' into name/extension"
+		return nil, errors.New(msg)
+	}
+
+	if parts[1] != "jpg" && parts[1] != "png" {
+		msg := "Unsupported format: " + parts[1]
+		return nil, errors.New(msg)
+	}
+
 	// Get key from bucket
 	body, err := getObject(bucket, key)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
+	defer body.Close()
+
 	img, _, err := image.Decode(body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	b := img.Bounds()
@@ -116,15 +136,12 @@ func makeThumbnail(bucket, key string) (string, error) {
 	// Call the resize library for image scaling
 	m := resize.Resize(uint(w), uint(h), img, resize.Lanczos3)
 
-	// Create new object name from existing key name
-	parts := strings.Split(key, ".")
-
 	// If it has a "uploads/" prefix, delete the prefix
 	nameParts := strings.Split(parts[0], "/")
 	name := parts[0] + "thumb." + parts[1]
 
-	if nameParts[0] == "uploads" {
-		name = nameParts[1] + "thumb." + parts[1]
+	if nameParts[0] == "uploads" && len(nameParts) > 1 {
+		name = strings.Join(nameParts[1:], "/") + "thumb." + parts[1]
 	}
 
 	// Body of S3 object
@@ -133,77 +150,72 @@ func makeThumbnail(bucket, key string) (string, error) {
 	// save the file in JPG or PNG format
 	switch parts[1] {
 	case "jpg":
-		err := jpeg.Encode(&buf, m, nil)
-		if err != nil {
-			return "", err
-		}
-
-		break
+		err = jpeg.Encode(&buf, m, nil)
 	case "png":
 		err = png.Encode(&buf, m)
-		if err != nil {
-			return "", err
-		}
-
-		break
+	}
 
-	default:
-		msg := "Unsupported format: " + parts[1]
-		return "", errors.New(msg)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create S3 object with name == name and body == buf
 	r := bytes.NewReader(buf.Bytes())
 
 	// Add thumbs/ prefix so we can find all of them in thumbs/
-	err = putObject(bucket, "thumbs/"+name, io.Reader(r))
-	return name, err
+	thumbKey := "thumbs/" + name
+
+	err = putObject(bucket, thumbKey, io.Reader(r))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ThumbnailResult{
+		Bucket:          bucket,
+		SourceKey:       key,
+		ThumbnailKeys:   []string{thumbKey},
+		Width:           width,
+		Height:          height,
+		ThumbnailWidth:  w,
+		ThumbnailHeight: h,
+	}
+
+	return result, nil
 }
 
-// func handler(ctx context.Context, event MyEvent) (string, error) {
-// func handler(ctx context.Context, myEvent MyRequestParameters) (string, error) {
-func handler(ctx context.Context, myEvent MyRequestParameters) error {
-	// Get bucket and key names from environment
-	bucketName := os.Getenv("bucketName")
-	keyName := os.Getenv("keyName")
+func handler(ctx context.Context, myEvent MyEvent) (*ThumbnailResult, error) {
+	fmt.Println("Got event in create thumbnail handler:")
+	fmt.Println(myEvent)
+
+	// Get bucket and key names from the event
+	bucketName := myEvent.Bucket
+	if bucketName == "" {
+		bucketName = myEvent.BucketName
+	}
+
+	keyName := myEvent.Key
 
 	if bucketName == "" || keyName == "" {
 		msg := "Did not get bucket and key"
-		return errors.New(msg)
+		return nil, errors.New(msg)
 	}
 
-	fmt.Print("Got bucket name '" + bucketName + "' from environment variable")
-	fmt.Print("Got key name    '" + bucketName + "' from environment variable")
-
-	//	fmt.Println("Got event in create thumbmail handler:")
-	//	fmt.Println(myEvent)
+	fmt.Println("Got bucket name '" + bucketName + "' from event")
+	fmt.Println("Got key name    '" + keyName + "' from event")
 
-	//	savedObject, err := makeThumbnail(myEvent.BucketName, myEvent.Key)
-	savedObject, err := makeThumbnail(bucketName, keyName)
+	result, err := makeThumbnail(bucketName, keyName)
 	if err != nil {
-		msg := "Got error creating thumbnail from key '" + myEvent.Key + "' in bucket '" + myEvent.BucketName + "':"
+		msg := "Got error creating thumbnail from key '" + keyName + "' in bucket '" + bucketName + "':"
 		fmt.Println(msg)
 		fmt.Println(err)
 
-		return /*"",*/ err
+		return nil, err
 	}
 
-	msg := "Created thumbnail '" + savedObject + "' in bucket '" + /*myEvent.BucketName*/ bucketName + "'"
+	msg := "Created thumbnail '" + result.ThumbnailKeys[0] + "' in bucket '" + bucketName + "'"
 	fmt.Println(msg)
 
-	//return "{ \"bucket\": " + event.Bucket + ", \"key\": " + savedObject + " }", nil
-
-	/* fmt.Println("Returning: ")
-	   fmt.Println(myEvent)
-
-	output, err := json.Marshal(&myEvent)
-	if err != nil {
-		return "", err
-	}
-
-	return string(output), nil
-	*/
-	return nil
+	return result, nil
 }
 
 func main() {
@@ -1,14 +1,20 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
 	"strings"
 
+	"github.com/Doug-AWS/code-examples/go/dynamodb/entity"
+	"github.com/Doug-AWS/code-examples/go/dynamodb/registry"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
@@ -18,18 +24,20 @@ import (
 type Config struct {
 	Table string `json:"TableName"`
 	Key   string `json:"KeyName"`
-	//Service   string
-	Services []string `json:"ServiceNames"` // To validate the -s option
-	//Sdk       string
-	Sdks []string `json:"SdkNames"` // To validate the -k option
+	Index string `json:"IndexName"` // To find entries that look like duplicates
 	//Target    string
-	Targets []string `json:"TargetNames"` // To validate the -t option
+	Targets []string          `json:"TargetNames"` // To validate the -t option
+	Action  string            `json:"ActionName"`  // The default action
+	Rules   []entity.PathRule `json:"PathRules"`   // To validate the -p option
 }
 
 var configFileName = "config.json"
 
 var globalConfig Config
 
+// globalRegistry is the services and SDKs an entity can have
+var globalRegistry *registry.Registry
+
 func populateConfiguration() error {
 	content, err := ioutil.ReadFile(configFileName)
 	if err != nil {
@@ -43,51 +51,80 @@ func populateConfiguration() error {
 		return err
 	}
 
-	if globalConfig.Table == "" || globalConfig.Key == "" {
-		msg := "You musts supply a value for TableName and KeyName in " + configFileName
+	if globalConfig.Table == "" || globalConfig.Key == "" || globalConfig.Index == "" {
+		msg := "You musts supply a value for TableName, KeyName, and IndexName in " + configFileName
 		return errors.New(msg)
 	}
 
 	return nil
 }
 
-// ValidAwsGuidePrefix defines the valid prefix for path when target is "guide" FOR MOST GUIDES
-const ValidAwsGuidePrefix = "https://docs.aws.amazon.com"
+// DynamoDBEntityAPI defines the interface for the functions AddEntity calls
+type DynamoDBEntityAPI interface {
+	GetItem(ctx context.Context,
+		params *dynamodb.GetItemInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
 
-// ValidGoGuidePrefix defines the valid prefix for the go dev guide
-const ValidGoGuidePrefix = "https://aws.github.io/aws-sdk-go-v2/docs/code-examples/"
+	PutItem(ctx context.Context,
+		params *dynamodb.PutItemInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
 
-func isPathValid(path string, sdk string, target string) error {
-	// If target is "guide"
-	// path must start with https://docs.aws.amazon.com
-	msg := ""
+	Query(ctx context.Context,
+		params *dynamodb.QueryInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+}
 
-	switch sdk {
-	case "go":
-		switch target {
-		case "guide":
-			valid := strings.HasPrefix(path, ValidGoGuidePrefix)
-			if valid {
-				return nil
-			}
-		}
-	default:
-		switch target {
-		case "guide":
-			valid := strings.HasPrefix(path, ValidAwsGuidePrefix)
-			if valid {
-				return nil
-			}
+// Entity is one item in the table
+type Entity struct {
+	Path        string
+	Action      string
+	Sdk         string
+	Service     string
+	Target      string
+	Description string
+}
 
-			msg = "Path does not start with " + ValidAwsGuidePrefix
-			return errors.New(msg)
+// item returns the entity as a table item
+func (e Entity) item() map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"path":        &types.AttributeValueMemberS{Value: e.Path},
+		"action":      &types.AttributeValueMemberS{Value: e.Action},
+		"sdk":         &types.AttributeValueMemberS{Value: e.Sdk},
+		"service":     &types.AttributeValueMemberS{Value: e.Service},
+		"target":      &types.AttributeValueMemberS{Value: e.Target},
+		"description": &types.AttributeValueMemberS{Value: e.Description},
+	}
+}
 
-		default:
-			msg = "Valid path prefix is not set for " + target + " target"
+// entityFromItem returns the entity in a table item; attributes that aren't strings are left empty
+func entityFromItem(item map[string]types.AttributeValue) Entity {
+	value := func(name string) string {
+		s, ok := item[name].(*types.AttributeValueMemberS)
+		if !ok {
+			return ""
 		}
+
+		return s.Value
+	}
+
+	return Entity{
+		Path:        value("path"),
+		Action:      value("action"),
+		Sdk:         value("sdk"),
+		Service:     value("service"),
+		Target:      value("target"),
+		Description: value("description"),
 	}
+}
 
-	return errors.New(msg)
+// printEntity shows the entity the way the debug output does
+func printEntity(w io.Writer, e Entity) {
+	fmt.Fprintln(w, "  Path:        "+e.Path)
+	fmt.Fprintln(w, "  Action:      "+e.Action)
+	fmt.Fprintln(w, "  SDK:         "+e.Sdk)
+	fmt.Fprintln(w, "  Service:     "+e.Service)
+	fmt.Fprintln(w, "  Target:      "+e.Target)
+	fmt.Fprintln(w, "  Description: "+e.Description)
 }
 
 func isValidItem(item string, list []string) bool {
@@ -101,64 +138,47 @@ func isValidItem(item string, list []string) bool {
 	return false
 }
 
-func debugPrint(debug bool, s string) {
-	if debug {
-		fmt.Println(s)
+func validateService(service string) error {
+	if !globalRegistry.IsService(service) {
+		return errors.New(service + " is not in the list of services: " + strings.Join(globalRegistry.ServiceIDs(), ", "))
 	}
-}
 
-func main() {
-	path := flag.String("p", "", "The fully-qualified path to the link target")
-	service := flag.String("s", "", "The service for which the entity is created")
-	sdk := flag.String("k", "", "The SDK's programming language extension (cpp, py, etc.)")
-	target := flag.String("t", "", "The target of the entity: a service (guide) or code (catalog)")
-	action := flag.String("a", "", "Whether the link is to a (section) such as 'sns', or Action, such as 'CreateTopic'")
-	debug := flag.Bool("d", false, "Whether to barf out more info")
+	return nil
+}
 
-	flag.Parse()
+func validateSdk(sdk string) error {
+	if !globalRegistry.IsSDK(sdk) {
+		return errors.New(sdk + " is not in the list of SDKs: " + strings.Join(globalRegistry.SDKIDs(), ", "))
+	}
 
-	debugPrint(*debug, "Debugging enabled")
+	return nil
+}
 
-	if *path == "" || *service == "" || *sdk == "" || *target == "" || *action == "" {
-		fmt.Println("You must supply a path, service, sdk, target, and action value")
-		fmt.Println("-p PATH -s SERVICE -k SDK -t TARGET -a ACTION")
-		fmt.Println("See config.json for valid values for service, sdk, target, and action")
-		return
+func validateTarget(target string) error {
+	if !isValidItem(target, globalConfig.Targets) {
+		return errors.New(target + " is not in the list of targets: " + strings.Join(globalConfig.Targets, ", "))
 	}
 
-	debugPrint(*debug, "")
-	debugPrint(*debug, "Path:    "+*path)
-	debugPrint(*debug, "Service: "+*service)
-	debugPrint(*debug, "SDK:     "+*sdk)
-	debugPrint(*debug, "Target:  "+*target)
-	debugPrint(*debug, "Action:  "+*action)
-	debugPrint(*debug, "")
+	return nil
+}
 
-	err := populateConfiguration()
-	if err != nil {
-		fmt.Println("Could not parse " + configFileName)
-		return
+// validateEntity checks that every value is set and valid
+func validateEntity(e Entity) error {
+	if e.Path == "" || e.Action == "" || e.Description == "" {
+		return errors.New("The path, action, and description must not be empty")
 	}
 
-	isValid := isValidItem(*service, globalConfig.Services)
-	if !isValid {
-		fmt.Println(*service + " is not in the list of services:")
-		fmt.Println(globalConfig.Services)
-		return
+	err := validateService(e.Service)
+	if err == nil {
+		err = validateSdk(e.Sdk)
 	}
 
-	isValid = isValidItem(*sdk, globalConfig.Sdks)
-	if !isValid {
-		fmt.Println(*sdk + " is not in the list of SDKs:")
-		fmt.Println(globalConfig.Sdks)
-		return
+	if err == nil {
+		err = validateTarget(e.Target)
 	}
 
-	isValid = isValidItem(*target, globalConfig.Targets)
-	if !isValid {
-		fmt.Println(*target + " is not in the list of targets:")
-		fmt.Println(globalConfig.Targets)
-		return
+	if err != nil {
+		return err
 	}
 
 	/*
@@ -168,32 +188,185 @@ func main() {
 		Otherwise, it's an individual topic for the *action operation.
 	*/
 
-	err = isPathValid(*path, *sdk, *target)
+	err = entity.CheckPath(globalConfig.Rules, e.Path, e.Sdk, e.Target)
 	if err != nil {
-		fmt.Println(*path + " is not a valid path for target " + *target)
-		return
+		return errors.New(e.Path + " is not a valid path for target " + e.Target + ": " + err.Error())
+	}
+
+	return nil
+}
+
+// FindExisting returns the item with the entity's path and action, if there is one,
+// and the entries for the same service, action, SDK, and target at other paths,
+// which are probably the same example added twice
+func FindExisting(c context.Context, api DynamoDBEntityAPI, table string, index string, e Entity) (*Entity, []Entity, error) {
+	resp, err := api.GetItem(c, &dynamodb.GetItemInput{
+		TableName: aws.String(table),
+		Key: map[string]types.AttributeValue{
+			"path":   &types.AttributeValueMemberS{Value: e.Path},
+			"action": &types.AttributeValueMemberS{Value: e.Action},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var existing *Entity
+
+	if len(resp.Item) > 0 {
+		found := entityFromItem(resp.Item)
+		existing = &found
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(table),
+		IndexName:              aws.String(index),
+		KeyConditionExpression: aws.String("#service = :service AND #action = :action"),
+		ExpressionAttributeNames: map[string]string{
+			"#service": "service",
+			"#action":  "action",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":service": &types.AttributeValueMemberS{Value: e.Service},
+			":action":  &types.AttributeValueMemberS{Value: e.Action},
+		},
+	}
+
+	similar := []Entity{}
+
+	for {
+		resp, err := api.Query(c, input)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, item := range resp.Items {
+			other := entityFromItem(item)
+			if other.Sdk == e.Sdk && other.Target == e.Target && other.Path != e.Path {
+				similar = append(similar, other)
+			}
+		}
+
+		if len(resp.LastEvaluatedKey) == 0 {
+			break
+		}
+
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+
+	return existing, similar, nil
+}
+
+// Upsert adds the entity, or replaces existing, the item with the same path and action.
+// The write fails if someone else added or changed that item since FindExisting read it.
+func Upsert(c context.Context, api DynamoDBEntityAPI, table string, e Entity, existing *Entity) error {
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(table),
+		Item:      e.item(),
+		ExpressionAttributeNames: map[string]string{
+			"#path": "path",
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#path)"),
+	}
+
+	if existing != nil {
+		input.ExpressionAttributeNames = map[string]string{
+			"#sdk":         "sdk",
+			"#service":     "service",
+			"#target":      "target",
+			"#description": "description",
+		}
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":sdk":         &types.AttributeValueMemberS{Value: existing.Sdk},
+			":service":     &types.AttributeValueMemberS{Value: existing.Service},
+			":target":      &types.AttributeValueMemberS{Value: existing.Target},
+			":description": &types.AttributeValueMemberS{Value: existing.Description},
+		}
+		input.ConditionExpression = aws.String("#sdk = :sdk AND #service = :service AND #target = :target AND #description = :description")
+	}
+
+	_, err := api.PutItem(c, input)
+	if err != nil {
+		var failed *types.ConditionalCheckFailedException
+		if errors.As(err, &failed) {
+			return errors.New("The item for " + e.Path + " and " + e.Action + " changed while we were looking at it; run AddEntity again")
+		}
+
+		return err
 	}
 
-	attrs := make(map[string]types.AttributeValue, 5)
+	return nil
+}
+
+func debugPrint(debug bool, s string) {
+	if debug {
+		fmt.Println(s)
+	}
+}
+
+func main() {
+	path := flag.String("p", "", "The fully-qualified path to the link target")
+	service := flag.String("s", "", "The service for which the entity is created")
+	sdk := flag.String("k", "", "The SDK's programming language extension (cpp, py, etc.)")
+	target := flag.String("t", "", "The target of the entity: a service (guide) or code (catalog)")
+	action := flag.String("a", "", "Whether the link is to a (section) such as 'sns', or Action, such as 'CreateTopic'")
+	description := flag.String("desc", "", "The text of the link")
+	interactive := flag.Bool("i", false, "Ask for every value, using the flags as defaults; AddEntity asks for any value that's missing anyway")
+	yes := flag.Bool("y", false, "Replace an existing item, or add one that looks like a duplicate, without asking")
+	registryFile := flag.String("registry", registry.DefaultFile, "The YAML file with the services and SDKs")
+	debug := flag.Bool("d", false, "Whether to barf out more info")
+
+	flag.Parse()
+
+	debugPrint(*debug, "Debugging enabled")
 
-	attrs["path"] = &types.AttributeValueMemberS{
-		Value: *path,
+	err := populateConfiguration()
+	if err != nil {
+		fmt.Println("Could not parse " + configFileName)
+		return
 	}
 
-	attrs["service"] = &types.AttributeValueMemberS{
-		Value: *service,
+	globalRegistry, err = registry.Load(*registryFile)
+	if err != nil {
+		fmt.Println("Could not load the services and SDKs: " + err.Error())
+		return
 	}
 
-	attrs["sdk"] = &types.AttributeValueMemberS{
-		Value: *sdk,
+	e := Entity{
+		Path:        *path,
+		Action:      *action,
+		Sdk:         *sdk,
+		Service:     *service,
+		Target:      *target,
+		Description: *description,
 	}
 
-	attrs["target"] = &types.AttributeValueMemberS{
-		Value: *target,
+	in := bufio.NewReader(os.Stdin)
+
+	if *interactive || e.Path == "" || e.Service == "" || e.Sdk == "" || e.Target == "" || e.Action == "" || e.Description == "" {
+		e, err = AskEntity(in, os.Stdout, e, *interactive)
+		if err != nil {
+			fmt.Println(err.Error())
+			fmt.Println("-p PATH -s SERVICE -k SDK -t TARGET -a ACTION -desc DESCRIPTION")
+			fmt.Println("See " + *registryFile + " for valid values for service and sdk, and config.json for target and action")
+			os.Exit(1)
+		}
 	}
 
-	attrs["action"] = &types.AttributeValueMemberS{
-		Value: *action,
+	debugPrint(*debug, "")
+	debugPrint(*debug, "Path:        "+e.Path)
+	debugPrint(*debug, "Service:     "+e.Service)
+	debugPrint(*debug, "SDK:         "+e.Sdk)
+	debugPrint(*debug, "Target:      "+e.Target)
+	debugPrint(*debug, "Action:      "+e.Action)
+	debugPrint(*debug, "Description: "+e.Description)
+	debugPrint(*debug, "")
+
+	err = validateEntity(e)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
 	}
 
 	cfg, err := config.LoadDefaultConfig(context.TODO())
@@ -203,15 +376,61 @@ func main() {
 
 	dynamodbClient := dynamodb.NewFromConfig(cfg)
 
-	dynamodbInput := &dynamodb.PutItemInput{
-		TableName: &globalConfig.Table,
-		Item:      attrs,
+	existing, similar, err := FindExisting(context.TODO(), dynamodbClient, globalConfig.Table, globalConfig.Index, e)
+	if err != nil {
+		fmt.Println("Got error looking for existing items: ")
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	question := ""
+
+	switch {
+	case existing != nil && existing.Sdk != e.Sdk:
+		// The table's key is the path and action, so writing would replace another SDK's entry
+		fmt.Println("The " + existing.Sdk + " SDK already has an item with this path and action:")
+		printEntity(os.Stdout, *existing)
+		fmt.Println("Use a different path for the " + e.Sdk + " SDK")
+		os.Exit(1)
+
+	case existing != nil && *existing == e:
+		fmt.Println("The table already has this item")
+		return
+
+	case existing != nil:
+		fmt.Println("The table already has an item with this path and action:")
+		printEntity(os.Stdout, *existing)
+		fmt.Println("It would become:")
+		printEntity(os.Stdout, e)
+		question = "Replace it?"
+
+	case len(similar) > 0:
+		fmt.Println("The table already has the " + e.Action + " action for the " + e.Service + " service, " + e.Sdk + " SDK, and " + e.Target + " target at:")
+		for _, s := range similar {
+			fmt.Println("  " + s.Path)
+		}
+
+		question = "Add " + e.Path + " too?"
+	}
+
+	if question != "" && !*yes {
+		ok, err := Confirm(in, os.Stdout, question)
+		if err != nil || !ok {
+			fmt.Println("Nothing was changed")
+			return
+		}
 	}
 
-	_, err = dynamodbClient.PutItem(context.TODO(), dynamodbInput)
+	err = Upsert(context.TODO(), dynamodbClient, globalConfig.Table, e, existing)
 	if err != nil {
 		fmt.Println("Got error calling PutItem: ")
 		fmt.Println(err)
-		return
+		os.Exit(1)
+	}
+
+	if existing != nil {
+		fmt.Println("Replaced the item for " + e.Path + " and " + e.Action)
+	} else {
+		fmt.Println("Added the item for " + e.Path + " and " + e.Action)
 	}
 }
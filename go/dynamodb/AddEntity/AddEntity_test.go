@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/Doug-AWS/code-examples/go/dynamodb/entity"
+	"github.com/Doug-AWS/code-examples/go/dynamodb/registry"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// mockEntityClient is a table keyed on path and action
+type mockEntityClient struct {
+	items map[string]Entity
+}
+
+func key(path, action string) string {
+	return path + "\x00" + action
+}
+
+func (m *mockEntityClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	e := entityFromItem(params.Key)
+
+	found, ok := m.items[key(e.Path, e.Action)]
+	if !ok {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+
+	return &dynamodb.GetItemOutput{Item: found.item()}, nil
+}
+
+func (m *mockEntityClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	e := entityFromItem(params.Item)
+	found, ok := m.items[key(e.Path, e.Action)]
+
+	// Check the two conditions Upsert uses
+	if *params.ConditionExpression == "attribute_not_exists(#path)" {
+		if ok {
+			return nil, &types.ConditionalCheckFailedException{}
+		}
+	} else if !ok || found.Sdk != params.ExpressionAttributeValues[":sdk"].(*types.AttributeValueMemberS).Value ||
+		found.Description != params.ExpressionAttributeValues[":description"].(*types.AttributeValueMemberS).Value {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	m.items[key(e.Path, e.Action)] = e
+
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m *mockEntityClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	service := params.ExpressionAttributeValues[":service"].(*types.AttributeValueMemberS).Value
+	action := params.ExpressionAttributeValues[":action"].(*types.AttributeValueMemberS).Value
+
+	resp := &dynamodb.QueryOutput{}
+
+	for _, e := range m.items {
+		if e.Service == service && e.Action == action {
+			resp.Items = append(resp.Items, e.item())
+		}
+	}
+
+	return resp, nil
+}
+
+func init() {
+	globalConfig = Config{
+		Table:   "CodeExamplesEntries",
+		Key:     "path",
+		Index:   "service-index",
+		Targets: []string{"guide", "catalog"},
+		Action:  "section",
+		Rules: []entity.PathRule{
+			{Sdk: "go", Target: "guide", Prefixes: []string{"https://aws.github.io/aws-sdk-go-v2/docs/code-examples/"}},
+			{Sdk: "*", Target: "guide", Prefixes: []string{"https://docs.aws.amazon.com"}},
+		},
+	}
+
+	var err error
+
+	globalRegistry, err = registry.Parse([]byte(`
+services:
+  - {id: sns, entity: SNS}
+  - {id: sqs, entity: SQS}
+sdks:
+  - {id: go, entity: Golong}
+  - {id: java, entity: JavaV2long}
+`))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestConfigRules(t *testing.T) {
+	b, err := ioutil.ReadFile(configFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c Config
+
+	err = json.Unmarshal(b, &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every target needs a rule, or no path is valid for it
+	for _, target := range c.Targets {
+		found := false
+
+		for _, r := range c.Rules {
+			if r.Target == target || r.Target == "*" {
+				found = true
+			}
+		}
+
+		if !found {
+			t.Errorf("There is no path rule for the %s target in %s", target, configFileName)
+		}
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	client := &mockEntityClient{items: map[string]Entity{}}
+	c := context.Background()
+
+	e := Entity{Path: "https://docs.aws.amazon.com/sns/publish", Action: "Publish", Sdk: "java", Service: "sns", Target: "guide", Description: "Publishes a message"}
+
+	existing, similar, err := FindExisting(c, client, "CodeExamplesEntries", "service-index", e)
+	if err != nil || existing != nil || len(similar) != 0 {
+		t.Fatalf("Got %v, %v, and %v for an empty table", existing, similar, err)
+	}
+
+	err = Upsert(c, client, "CodeExamplesEntries", e, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Adding it again without knowing it's there fails rather than overwriting it
+	err = Upsert(c, client, "CodeExamplesEntries", e, nil)
+	if err == nil {
+		t.Error("Expected an error adding an item that's already there")
+	}
+
+	changed := e
+	changed.Description = "Publishes a message to a topic"
+
+	existing, _, err = FindExisting(c, client, "CodeExamplesEntries", "service-index", changed)
+	if err != nil || existing == nil || *existing != e {
+		t.Fatalf("Got %v and %v, not the item that's there", existing, err)
+	}
+
+	err = Upsert(c, client, "CodeExamplesEntries", changed, existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if client.items[key(e.Path, e.Action)].Description != changed.Description {
+		t.Error("Expected the item to be replaced")
+	}
+
+	// The same example at another path looks like a duplicate
+	moved := e
+	moved.Path = "https://docs.aws.amazon.com/sns/publish-message"
+
+	existing, similar, err = FindExisting(c, client, "CodeExamplesEntries", "service-index", moved)
+	if err != nil || existing != nil || len(similar) != 1 || similar[0].Path != e.Path {
+		t.Errorf("Got %v, %v, and %v for a likely duplicate", existing, similar, err)
+	}
+}
+
+func TestAskEntity(t *testing.T) {
+	// The flags gave the SDK and a path that isn't valid for it
+	e := Entity{Sdk: "go", Path: "https://docs.aws.amazon.com/sns"}
+
+	input := strings.Join([]string{
+		"ec2", // Not a service
+		"sns", // Service
+		"",    // Target: keep guide
+		"",    // Action: keep section
+		"",    // Path: keep the one that isn't valid, so it's asked again
+		"https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns/",
+		"", // Description can't be empty
+		"Amazon SNS examples",
+	}, "\n") + "\n"
+
+	var out bytes.Buffer
+
+	got, err := AskEntity(bufio.NewReader(strings.NewReader(input)), &out, e, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Entity{
+		Path:        "https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns/",
+		Action:      "section",
+		Sdk:         "go",
+		Service:     "sns",
+		Target:      "guide",
+		Description: "Amazon SNS examples",
+	}
+
+	if got != want {
+		t.Errorf("Got %v", got)
+	}
+
+	if strings.Contains(out.String(), "SDK (") || !strings.Contains(out.String(), "ec2 is not in the list of services") {
+		t.Errorf("Expected to be asked for everything but the SDK, got:\n%s", out.String())
+	}
+
+	_, err = AskEntity(bufio.NewReader(strings.NewReader("sns\n")), &out, Entity{}, false)
+	if err == nil {
+		t.Error("Expected an error when the answers run out")
+	}
+
+	ok, err := Confirm(bufio.NewReader(strings.NewReader("Yes\n")), &out, "Replace it?")
+	if err != nil || !ok {
+		t.Errorf("Got %v and %v for yes", ok, err)
+	}
+
+	ok, _ = Confirm(bufio.NewReader(strings.NewReader("\n")), &out, "Replace it?")
+	if ok {
+		t.Error("Expected no to be the default")
+	}
+}
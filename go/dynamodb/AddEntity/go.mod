@@ -3,6 +3,13 @@ module mymain
 go 1.15
 
 require (
+	github.com/Doug-AWS/code-examples/go/dynamodb/entity v0.0.0
+	github.com/Doug-AWS/code-examples/go/dynamodb/registry v0.0.0
+	github.com/aws/aws-sdk-go-v2 v1.2.0
 	github.com/aws/aws-sdk-go-v2/config v1.1.1
 	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.1.1
 )
+
+replace github.com/Doug-AWS/code-examples/go/dynamodb/entity => ../entity
+
+replace github.com/Doug-AWS/code-examples/go/dynamodb/registry => ../registry
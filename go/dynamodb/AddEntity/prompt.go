@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Doug-AWS/code-examples/go/dynamodb/entity"
+)
+
+// question is a value AskEntity asks for
+type question struct {
+	prompt   string
+	choices  []string
+	value    *string
+	validate func(string) error
+}
+
+// ask asks the question until it gets a valid answer; an empty answer keeps the current value
+func ask(in *bufio.Reader, out io.Writer, q question) error {
+	for {
+		prompt := q.prompt
+		if len(q.choices) > 0 {
+			prompt += " (" + strings.Join(q.choices, ", ") + ")"
+		}
+
+		if *q.value != "" {
+			prompt += " [" + *q.value + "]"
+		}
+
+		fmt.Fprint(out, prompt+": ")
+
+		line, err := in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			fmt.Fprintln(out)
+			return errors.New("Got no answer for " + strings.ToLower(q.prompt))
+		}
+
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = *q.value
+		}
+
+		if answer == "" {
+			fmt.Fprintln(out, q.prompt+" must not be empty")
+			continue
+		}
+
+		if q.validate != nil {
+			err = q.validate(answer)
+			if err != nil {
+				fmt.Fprintln(out, err.Error())
+				continue
+			}
+		}
+
+		*q.value = answer
+
+		return nil
+	}
+}
+
+// AskEntity asks for the values of the entity that are missing or not valid, or for all of them,
+// offering the values it already has as defaults
+func AskEntity(in *bufio.Reader, out io.Writer, e Entity, all bool) (Entity, error) {
+	// Remember what was given before filling in the defaults, which still need asking about
+	given := map[*string]bool{
+		&e.Service:     e.Service != "",
+		&e.Sdk:         e.Sdk != "",
+		&e.Target:      e.Target != "",
+		&e.Action:      e.Action != "",
+		&e.Path:        e.Path != "",
+		&e.Description: e.Description != "",
+	}
+
+	if e.Target == "" {
+		e.Target = "guide"
+	}
+
+	if e.Action == "" {
+		e.Action = globalConfig.Action
+	}
+
+	questions := []question{
+		{"Service", globalRegistry.ServiceIDs(), &e.Service, validateService},
+		{"SDK", globalRegistry.SDKIDs(), &e.Sdk, validateSdk},
+		{"Target", globalConfig.Targets, &e.Target, validateTarget},
+		{"Action (section, or an API operation such as CreateTopic)", nil, &e.Action, nil},
+		// The path is checked against the prefix rules for the SDK and target that were just answered
+		{"Path", nil, &e.Path, func(path string) error {
+			return entity.CheckPath(globalConfig.Rules, path, e.Sdk, e.Target)
+		}},
+		{"Description", nil, &e.Description, nil},
+	}
+
+	fmt.Fprintln(out, "Press Enter to keep the value in brackets")
+
+	for _, q := range questions {
+		if !all && given[q.value] && (q.validate == nil || q.validate(*q.value) == nil) {
+			continue
+		}
+
+		err := ask(in, out, q)
+		if err != nil {
+			return e, err
+		}
+	}
+
+	return e, nil
+}
+
+// Confirm asks a yes or no question; anything but y or yes is no
+func Confirm(in *bufio.Reader, out io.Writer, question string) (bool, error) {
+	fmt.Fprint(out, question+" [y/N]: ")
+
+	line, err := in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		fmt.Fprintln(out)
+		return false, err
+	}
+
+	answer := strings.ToLower(strings.TrimSpace(line))
+
+	return answer == "y" || answer == "yes", nil
+}
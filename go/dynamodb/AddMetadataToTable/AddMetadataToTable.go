@@ -7,13 +7,17 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
+	"github.com/Doug-AWS/code-examples/go/dynamodb/batch"
+	"github.com/Doug-AWS/code-examples/go/dynamodb/registry"
+	"github.com/Doug-AWS/code-examples/go/utilities/metadata"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"gopkg.in/yaml.v2"
 )
 
 // Config holds the info in config.json
@@ -21,26 +25,13 @@ type Config struct {
 	Table string `json:"TableName"`
 }
 
-type Service struct {
-	Service string   `yaml:"service"`
-	Actions []string `yaml:"actions"`
-}
-
-type File struct {
-	Description string    `yaml:"description"`
-	Path        string    `yaml:"path"`
-	Services    []Service `yaml:"services"`
-}
-
-// Metadata caches the info in metadata.yaml
-type Metadata struct {
-	Files []File `yaml:"files"`
-}
-
 var configFileName = "config.json"
 
 var globalConfig Config
 
+// globalRegistry maps service names to their entity names
+var globalRegistry *registry.Registry
+
 func populateConfiguration() error {
 	content, err := ioutil.ReadFile(configFileName)
 	if err != nil {
@@ -81,58 +72,8 @@ func transmogrifyPath(debug bool, service, path string) string {
 	return "https://aws.github.io/aws-sdk-go-v2/docs/code-examples/" + service + "/" + dir
 }
 
-func getServiceEntityName(debug bool, service string) (string, error) {
-	retVal := ""
-	var err error
-
-	switch service {
-	case "cloudwatch":
-		retVal = "CloudWatch"
-		break
-	case "dynamodb":
-		retVal = "DynamoDB"
-		break
-	case "ec2":
-		retVal = "EC2"
-		break
-	case "iam":
-		retVal = "IAM"
-		break
-	case "kinesis":
-		retVal = "Kinesis"
-		break
-	case "kms":
-		retVal = "KMS"
-		break
-	case "rekognition":
-		retVal = "Rekognition"
-		break
-	case "s3":
-		retVal = "S3"
-		break
-	case "sqs":
-		retVal = "SQS"
-		break
-	case "sns":
-		retVal = "SNS"
-		break
-	case "ssm":
-		retVal = "SSM"
-		break
-	case "sts":
-		retVal = "STS"
-		break
-
-	default:
-		msg := "Unidentified service: " + service
-		err = errors.New(msg)
-
-	}
-
-	return retVal, err
-}
-
-func addDirToTable(debug bool, dirName string, tablename string, ext string, target string) error {
+// sectionItem returns the item for the section of the guide about the service in dirName
+func sectionItem(debug bool, dirName string, ext string, target string) (map[string]types.AttributeValue, error) {
 	debugPrint(debug, "Adding "+dirName+" to table")
 
 	// DirName is something like:
@@ -169,133 +110,111 @@ func addDirToTable(debug bool, dirName string, tablename string, ext string, tar
 		Value: target,
 	}
 
-	name, err := getServiceEntityName(debug, service)
+	name, err := globalRegistry.ServiceEntityName(service)
 	if err != nil {
 		fmt.Print("Got an unknown service name: " + service)
-		return err
+		return nil, err
 	}
 
 	attrs["description"] = &types.AttributeValueMemberS{
 		Value: "This topic describes how to perform some of the basic functions of " + name + " using version 2 of the AWS SDK for Go.",
 	}
 
-	dynamodbInput := &dynamodb.PutItemInput{
-		TableName: &globalConfig.Table,
-		Item:      attrs,
-	}
-
-	// Create DynamoDB client
-	cfg, err := config.LoadDefaultConfig(context.TODO())
-	if err != nil {
-		panic("configuration error, " + err.Error())
-	}
-
-	client := dynamodb.NewFromConfig(cfg)
-
-	_, err = client.PutItem(context.TODO(), dynamodbInput)
-	if err != nil {
-		fmt.Println("Got error calling PutItem: ")
-		fmt.Println(err)
-		return err
-	}
-
-	return nil
+	return attrs, nil
 }
 
-func addMetadataToTable(debug bool, filename string, tablename string, ext string, target string) (int, error) {
+// metadataItems returns an item for each action in filename, and how many test actions it skipped
+func metadataItems(debug bool, filename string, ext string, target string) ([]map[string]types.AttributeValue, int, error) {
 	debugPrint(debug, "Parsing "+filename)
 
-	yamlFile, err := ioutil.ReadFile(filename)
+	abs, err := filepath.Abs(filename)
 	if err != nil {
-		msg := "Got error reading YAML file: " + filename + "\n" + err.Error()
-		return 0, errors.New(msg)
+		return nil, 0, err
 	}
 
-	var metadata Metadata
-	err = yaml.Unmarshal(yamlFile, &metadata)
+	meta, err := metadata.LoadAs(filename, metadata.DefaultVersion(abs))
 	if err != nil {
-		msg := "Got error unmarshalling YAML: for " + filename + "\n" + err.Error()
-		return 0, errors.New(msg)
+		msg := "Got error reading metadata file: " + filename + "\n" + err.Error()
+		return nil, 0, errors.New(msg)
 	}
 
-	// Create DynamoDB client
-	cfg, err := config.LoadDefaultConfig(context.TODO())
-	if err != nil {
-		panic("configuration error, " + err.Error())
-	}
-
-	client := dynamodb.NewFromConfig(cfg)
+	items := []map[string]types.AttributeValue{}
+	skipped := 0
 
-	itemsAdded := 0
-
-	for _, f := range metadata.Files {
+	for _, f := range meta.Files {
 		for _, s := range f.Services {
 			path := transmogrifyPath(debug, s.Service, f.Path)
 			for _, a := range s.Actions {
 				// Don't add actions that are "test"
-				if a != "test" {
-					debugPrint(debug, "Path:        "+path)
-					debugPrint(debug, "Action:      "+a)
-					debugPrint(debug, "SDK:         "+ext)
-					debugPrint(debug, "Service:     "+s.Service)
-					debugPrint(debug, "Target:      "+target)
-					debugPrint(debug, "Description: "+f.Description)
-					debugPrint(debug, "")
-
-					// Create attributes for new table item
-					attrs := make(map[string]types.AttributeValue, 6)
-
-					attrs["path"] = &types.AttributeValueMemberS{
-						Value: path,
-					}
+				if a == "test" {
+					skipped++
+					continue
+				}
 
-					attrs["action"] = &types.AttributeValueMemberS{
-						Value: a,
-					}
+				debugPrint(debug, "Path:        "+path)
+				debugPrint(debug, "Action:      "+a)
+				debugPrint(debug, "SDK:         "+ext)
+				debugPrint(debug, "Service:     "+s.Service)
+				debugPrint(debug, "Target:      "+target)
+				debugPrint(debug, "Description: "+f.Description)
+				debugPrint(debug, "")
 
-					attrs["sdk"] = &types.AttributeValueMemberS{
-						Value: ext,
-					}
+				// Create attributes for new table item
+				attrs := make(map[string]types.AttributeValue, 6)
 
-					attrs["service"] = &types.AttributeValueMemberS{
-						Value: s.Service,
-					}
+				attrs["path"] = &types.AttributeValueMemberS{
+					Value: path,
+				}
 
-					attrs["target"] = &types.AttributeValueMemberS{
-						Value: target,
-					}
+				attrs["action"] = &types.AttributeValueMemberS{
+					Value: a,
+				}
 
-					attrs["description"] = &types.AttributeValueMemberS{
-						Value: f.Description,
-					}
+				attrs["sdk"] = &types.AttributeValueMemberS{
+					Value: ext,
+				}
 
-					dynamodbInput := &dynamodb.PutItemInput{
-						TableName: &globalConfig.Table,
-						Item:      attrs,
-					}
+				attrs["service"] = &types.AttributeValueMemberS{
+					Value: s.Service,
+				}
 
-					_, err = client.PutItem(context.TODO(), dynamodbInput)
-					if err != nil {
-						fmt.Println("Got error calling PutItem: ")
-						fmt.Println(err)
-						return 0, err
-					}
+				attrs["target"] = &types.AttributeValueMemberS{
+					Value: target,
+				}
 
-					itemsAdded++
+				attrs["description"] = &types.AttributeValueMemberS{
+					Value: f.Description,
 				}
+
+				items = append(items, attrs)
 			}
 		}
 
 		debugPrint(debug, "")
 	}
 
-	return itemsAdded, nil
+	return items, skipped, nil
+}
+
+// itemKey returns the path and action of the item, which are the table's key
+func itemKey(item map[string]types.AttributeValue) string {
+	path, _ := item["path"].(*types.AttributeValueMemberS)
+	action, _ := item["action"].(*types.AttributeValueMemberS)
+
+	if path == nil || action == nil {
+		return ""
+	}
+
+	return path.Value + "\x00" + action.Value
 }
 
 func main() {
 	root := flag.String("r", "", "The root of the Go v2 directory on this computer")
 	ext := flag.String("e", "", "The file extension of the code examples")
 	target := flag.String("t", "guide", "guide or catalog")
+	registryFile := flag.String("registry", registry.DefaultFile, "The YAML file with the services and SDKs")
+	workers := flag.Int("w", 4, "How many batches of items to write at once")
+	rate := flag.Int("rate", 0, "The most items to write each second, to stay under a provisioned table's write capacity; 0 means no limit")
 	debug := flag.Bool("d", false, "Whether to barf out more info")
 
 	flag.Parse()
@@ -329,6 +248,12 @@ func main() {
 		return
 	}
 
+	globalRegistry, err = registry.Load(*registryFile)
+	if err != nil {
+		fmt.Println("Could not load the services and SDKs: " + err.Error())
+		return
+	}
+
 	// Navigate into sub-directories,
 	// if metatdata.yaml found,
 	// call addMetadataToTable with full path.
@@ -338,7 +263,8 @@ func main() {
 		return
 	}
 
-	numEntries := 0
+	items := []map[string]types.AttributeValue{}
+	skipped := 0
 
 	for _, f := range files {
 		if f.IsDir() {
@@ -354,14 +280,16 @@ func main() {
 
 			for _, m := range dFiles {
 				if m.Name() == "metadata.yaml" {
-					err = addDirToTable(*debug, *root+"/"+f.Name(), globalConfig.Table, *ext, *target)
+					item, err := sectionItem(*debug, *root+"/"+f.Name(), *ext, *target)
 					if err != nil {
 						fmt.Println("Got an error adding " + f.Name() + "to table:")
 						fmt.Println(err.Error())
 						return
 					}
 
-					num, err := addMetadataToTable(*debug, *root+"/"+f.Name()+"/"+m.Name(), globalConfig.Table, *ext, *target)
+					items = append(items, item)
+
+					actions, num, err := metadataItems(*debug, *root+"/"+f.Name()+"/"+m.Name(), *ext, *target)
 					if err != nil {
 						fmt.Println("Got an error adding items to table:")
 						fmt.Println(err.Error())
@@ -369,12 +297,49 @@ func main() {
 					}
 
 					debugPrint(*debug, "Read contents of "+*root+"/"+f.Name()+"/"+m.Name())
-					numEntries += num
-
+					items = append(items, actions...)
+					skipped += num
 				}
 			}
 		}
 	}
 
-	fmt.Println("Added " + strconv.Itoa(numEntries) + " items to the table")
+	// A batch can't have two items with the same key, and the second would overwrite the first anyway
+	unique := []map[string]types.AttributeValue{}
+	seen := map[string]bool{}
+
+	for _, item := range items {
+		key := itemKey(item)
+		if seen[key] {
+			debugPrint(*debug, "Skipping duplicate "+strings.Replace(key, "\x00", " ", 1))
+			skipped++
+			continue
+		}
+
+		seen[key] = true
+		unique = append(unique, item)
+	}
+
+	// Create DynamoDB client
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		panic("configuration error, " + err.Error())
+	}
+
+	writer := batch.NewWriter(dynamodb.NewFromConfig(cfg), globalConfig.Table)
+	writer.Workers = *workers
+	writer.ItemsPerSecond = *rate
+	writer.Progress = os.Stdout
+
+	stats, errs := writer.Write(context.TODO(), unique)
+
+	for _, err := range errs {
+		fmt.Println("Got an error adding items to table: " + err.Error())
+	}
+
+	fmt.Println("Wrote " + strconv.Itoa(stats.Written) + " items, skipped " + strconv.Itoa(skipped) + ", and failed to write " + strconv.Itoa(stats.Failed))
+
+	if stats.Failed > 0 {
+		os.Exit(1)
+	}
 }
@@ -3,7 +3,15 @@ module mymain
 go 1.15
 
 require (
+	github.com/Doug-AWS/code-examples/go/dynamodb/batch v0.0.0
+	github.com/Doug-AWS/code-examples/go/dynamodb/registry v0.0.0
+	github.com/Doug-AWS/code-examples/go/utilities/metadata v0.0.0
 	github.com/aws/aws-sdk-go-v2/config v1.1.1
 	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.1.1
-	gopkg.in/yaml.v2 v2.2.8
 )
+
+replace github.com/Doug-AWS/code-examples/go/dynamodb/batch => ../batch
+
+replace github.com/Doug-AWS/code-examples/go/dynamodb/registry => ../registry
+
+replace github.com/Doug-AWS/code-examples/go/utilities/metadata => ../../utilities/metadata
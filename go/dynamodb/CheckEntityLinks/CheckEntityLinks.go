@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Doug-AWS/code-examples/go/dynamodb/entity"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"gopkg.in/yaml.v2"
+)
+
+// Config holds the info in config.json
+type Config struct {
+	Table string `json:"TableName"`
+}
+
+var configFileName = "config.json"
+
+var globalConfig Config
+
+func populateConfiguration() error {
+	content, err := ioutil.ReadFile(configFileName)
+	if err != nil {
+		return err
+	}
+
+	text := string(content)
+
+	err = json.Unmarshal([]byte(text), &globalConfig)
+	if err != nil {
+		return err
+	}
+
+	if globalConfig.Table == "" {
+		msg := "You musts supply a value for TableName " + configFileName
+		return errors.New(msg)
+	}
+
+	return nil
+}
+
+// DynamoDBScanAPI defines the interface for the Scan function
+type DynamoDBScanAPI interface {
+	Scan(ctx context.Context,
+		params *dynamodb.ScanInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+// uniquePaths returns the paths sorted, without empty ones or any path twice
+func uniquePaths(paths []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+
+	for _, p := range paths {
+		p = strings.TrimSpace(p)
+		if p == "" || seen[p] {
+			continue
+		}
+
+		seen[p] = true
+		unique = append(unique, p)
+	}
+
+	sort.Strings(unique)
+
+	return unique
+}
+
+// ScanPaths returns the path of every item in the table, following LastEvaluatedKey
+func ScanPaths(c context.Context, api DynamoDBScanAPI, table string) ([]string, error) {
+	paths := []string{}
+	projection := "#path"
+
+	input := &dynamodb.ScanInput{
+		TableName:                &table,
+		ProjectionExpression:     &projection,
+		ExpressionAttributeNames: map[string]string{"#path": "path"},
+	}
+
+	for {
+		resp, err := api.Scan(c, input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range resp.Items {
+			s, ok := item["path"].(*types.AttributeValueMemberS)
+			if ok {
+				paths = append(paths, s.Value)
+			}
+		}
+
+		if len(resp.LastEvaluatedKey) == 0 {
+			break
+		}
+
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+
+	return uniquePaths(paths), nil
+}
+
+// ReadPaths returns the paths in a file ExportEntities wrote, in the csv, jsonl, or yaml format
+func ReadPaths(r io.Reader, format string, delimiter rune) ([]string, error) {
+	paths := []string{}
+
+	switch format {
+	case "csv":
+		reader := csv.NewReader(r)
+		reader.Comma = delimiter
+		reader.FieldsPerRecord = -1
+
+		header, err := reader.Read()
+		if err != nil {
+			return nil, err
+		}
+
+		column := -1
+		for i, name := range header {
+			if strings.TrimPrefix(strings.TrimSpace(name), "\ufeff") == "path" {
+				column = i
+			}
+		}
+
+		if column < 0 {
+			return nil, errors.New("The header has no path column")
+		}
+
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			if column < len(record) {
+				paths = append(paths, record[column])
+			}
+		}
+
+	case "jsonl":
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+		line := 0
+		for scanner.Scan() {
+			line++
+
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+
+			var e struct {
+				Path string `json:"path"`
+			}
+
+			err := json.Unmarshal(scanner.Bytes(), &e)
+			if err != nil {
+				return nil, errors.New("Line " + strconv.Itoa(line) + ": " + err.Error())
+			}
+
+			paths = append(paths, e.Path)
+		}
+
+		err := scanner.Err()
+		if err != nil {
+			return nil, err
+		}
+
+	case "yaml":
+		var metadata struct {
+			Files []struct {
+				Path string `yaml:"path"`
+			} `yaml:"files"`
+		}
+
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+
+		err = yaml.Unmarshal(b, &metadata)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range metadata.Files {
+			paths = append(paths, f.Path)
+		}
+
+	default:
+		return nil, errors.New("The format must be csv, jsonl, or yaml, not " + format)
+	}
+
+	return uniquePaths(paths), nil
+}
+
+// WriteReport writes the results that need attention, grouped by kind,
+// and returns how many are broken, timed out, or failed
+func WriteReport(w io.Writer, results []Result) int {
+	byKind := map[string][]Result{}
+	cached := 0
+
+	for _, r := range results {
+		byKind[r.Kind] = append(byKind[r.Kind], r)
+		if r.Cached {
+			cached++
+		}
+	}
+
+	fmt.Fprintln(w, "Checked "+strconv.Itoa(len(results))+" links ("+strconv.Itoa(cached)+" from the cache): "+
+		strconv.Itoa(len(byKind[KindOK]))+" OK, "+
+		strconv.Itoa(len(byKind[KindRedirect]))+" redirected, "+
+		strconv.Itoa(len(byKind[KindBroken]))+" broken, "+
+		strconv.Itoa(len(byKind[KindTimeout]))+" timed out, and "+
+		strconv.Itoa(len(byKind[KindError]))+" failed")
+
+	sections := []struct {
+		kind  string
+		title string
+		line  func(Result) string
+	}{
+		{KindBroken, "Broken", func(r Result) string {
+			s := strconv.Itoa(r.Status) + " " + r.URL
+			if len(r.Redirects) > 0 {
+				s += " (redirected to " + r.Final() + ")"
+			}
+
+			return s
+		}},
+		{KindRedirect, "Redirected", func(r Result) string {
+			return r.URL + " -> " + strings.Join(r.Redirects, " -> ")
+		}},
+		{KindTimeout, "Timed out", func(r Result) string {
+			return r.URL
+		}},
+		{KindError, "Failed", func(r Result) string {
+			return r.URL + ": " + r.Error
+		}},
+	}
+
+	for _, s := range sections {
+		if len(byKind[s.kind]) == 0 {
+			continue
+		}
+
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, s.title+":")
+
+		for _, r := range byKind[s.kind] {
+			fmt.Fprintln(w, "  "+s.line(r))
+		}
+	}
+
+	return len(byKind[KindBroken]) + len(byKind[KindTimeout]) + len(byKind[KindError])
+}
+
+func main() {
+	file := flag.String("f", "", "A file ExportEntities wrote to read the paths from; by default they're read from the table")
+	format := flag.String("format", "", "The format of -f: csv, jsonl, or yaml; by default it comes from the file's extension, or is csv")
+	delimiter := flag.String("D", "|", "The character that separates the columns of a CSV file; use tab for a TSV file")
+	cacheFile := flag.String("cache", "links.json", "The file to keep results in between runs; empty means don't use one")
+	maxAge := flag.Duration("max-age", 24*time.Hour, "How long a good result in the cache is used before the link is checked again")
+	workers := flag.Int("w", 8, "How many links to check at once")
+	rate := flag.Float64("rate", 2, "The most requests to send to one host each second; 0 means no limit")
+	timeout := flag.Duration("timeout", 15*time.Second, "How long to wait for each request")
+	jsonReport := flag.Bool("json", false, "Write the results as JSON instead of a report")
+	flag.Parse()
+
+	var paths []string
+	var err error
+
+	if *file != "" {
+		if *format == "" {
+			switch filepath.Ext(*file) {
+			case ".jsonl":
+				*format = "jsonl"
+			case ".yaml", ".yml":
+				*format = "yaml"
+			default:
+				*format = "csv"
+			}
+		}
+
+		delim, err := entity.ParseDelimiter(*delimiter)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+
+		f, err := os.Open(*file)
+		if err != nil {
+			fmt.Println("Got an error opening " + *file)
+			os.Exit(1)
+		}
+
+		paths, err = ReadPaths(f, *format, delim)
+		f.Close()
+
+		if err != nil {
+			fmt.Println("Got an error reading " + *file + ": " + err.Error())
+			os.Exit(1)
+		}
+	} else {
+		err = populateConfiguration()
+		if err != nil {
+			fmt.Println("Could not parse " + configFileName)
+			os.Exit(1)
+		}
+
+		cfg, err := config.LoadDefaultConfig(context.TODO())
+		if err != nil {
+			panic("configuration error, " + err.Error())
+		}
+
+		paths, err = ScanPaths(context.TODO(), dynamodb.NewFromConfig(cfg), globalConfig.Table)
+		if err != nil {
+			fmt.Println("Got an error scanning " + globalConfig.Table + ":")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	cache := Cache{}
+
+	if *cacheFile != "" {
+		cache, err = LoadCache(*cacheFile)
+		if err != nil {
+			fmt.Println("Could not read the cache: " + err.Error())
+			os.Exit(1)
+		}
+	}
+
+	checker := NewChecker(*timeout)
+	checker.Workers = *workers
+
+	if *rate > 0 {
+		checker.HostInterval = time.Duration(float64(time.Second) / *rate)
+	}
+
+	results := checker.CheckAll(context.TODO(), paths, cache, *maxAge)
+
+	if *cacheFile != "" {
+		cache.Add(results)
+
+		err = cache.Save(*cacheFile)
+		if err != nil {
+			fmt.Println("Could not save the cache: " + err.Error())
+		}
+	}
+
+	problems := 0
+
+	if *jsonReport {
+		for _, r := range results {
+			if r.Kind == KindBroken || r.Kind == KindTimeout || r.Kind == KindError {
+				problems++
+			}
+		}
+
+		b, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			fmt.Println("Could not write the results: " + err.Error())
+			os.Exit(1)
+		}
+
+		fmt.Println(string(b))
+	} else {
+		problems = WriteReport(os.Stdout, results)
+	}
+
+	if problems > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newServer returns a server with a page, a missing page, redirects, a loop,
+// a page that only answers GET, and a slow page.
+// It counts the requests for each path.
+func newServer(t *testing.T) (*httptest.Server, map[string]int, *sync.Mutex) {
+	var mu sync.Mutex
+	requests := map[string]int{}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/moved-again", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/moved-again", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	})
+	mux.HandleFunc("/moved-away", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/gone", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/get-only", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Method+" "+r.URL.Path]++
+		mu.Unlock()
+
+		mux.ServeHTTP(w, r)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server, requests, &mu
+}
+
+func TestCheckAll(t *testing.T) {
+	server, requests, _ := newServer(t)
+
+	checker := NewChecker(100 * time.Millisecond)
+	checker.MaxRedirects = 3
+
+	links := []string{}
+	for _, p := range []string{"/ok", "/gone", "/moved", "/moved-away", "/loop", "/get-only", "/slow"} {
+		links = append(links, server.URL+p)
+	}
+
+	links = append(links, "mailto:someone@example.com")
+
+	results := checker.CheckAll(context.Background(), links, Cache{}, time.Hour)
+
+	want := []struct {
+		kind      string
+		status    int
+		redirects int
+	}{
+		{KindOK, 200, 0},
+		{KindBroken, 404, 0},
+		{KindRedirect, 200, 2},
+		{KindBroken, 404, 1},
+		{KindError, 302, 3},
+		{KindOK, 200, 0},
+		{KindTimeout, 0, 0},
+		{KindError, 0, 0},
+	}
+
+	for i, w := range want {
+		r := results[i]
+		if r.URL != links[i] || r.Kind != w.kind || r.Status != w.status || len(r.Redirects) != w.redirects {
+			t.Errorf("Expected %s with %d and %d redirects for %s, got %+v", w.kind, w.status, w.redirects, links[i], r)
+		}
+	}
+
+	if results[2].Final() != server.URL+"/ok" {
+		t.Errorf("Expected /moved to end up at /ok, got %s", results[2].Final())
+	}
+
+	if requests["GET /get-only"] != 1 || requests["GET /ok"] != 0 {
+		t.Errorf("Expected GET only after HEAD wasn't allowed, got %v", requests)
+	}
+
+	var report bytes.Buffer
+
+	problems := WriteReport(&report, results)
+	if problems != 5 {
+		t.Errorf("Expected 5 problems, got %d", problems)
+	}
+
+	for _, s := range []string{
+		"Checked 8 links (0 from the cache): 2 OK, 1 redirected, 2 broken, 1 timed out, and 2 failed\n",
+		"Broken:\n  404 " + server.URL + "/gone\n  404 " + server.URL + "/moved-away (redirected to " + server.URL + "/gone)\n",
+		"Redirected:\n  " + server.URL + "/moved -> " + server.URL + "/moved-again -> " + server.URL + "/ok\n",
+		"Timed out:\n  " + server.URL + "/slow\n",
+	} {
+		if !strings.Contains(report.String(), s) {
+			t.Errorf("Expected the report to contain %q, got:\n%s", s, report.String())
+		}
+	}
+}
+
+func TestHostInterval(t *testing.T) {
+	server, _, _ := newServer(t)
+
+	checker := NewChecker(time.Second)
+	checker.HostInterval = 50 * time.Millisecond
+
+	links := []string{server.URL + "/ok", server.URL + "/ok?a", server.URL + "/ok?b", server.URL + "/ok?c"}
+
+	start := time.Now()
+	checker.CheckAll(context.Background(), links, Cache{}, time.Hour)
+
+	// Four requests to one host, at least 50ms apart, whatever the number of workers
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected the requests to be spread out, but they took %s", elapsed)
+	}
+}
+
+func TestCache(t *testing.T) {
+	server, requests, mu := newServer(t)
+
+	checker := NewChecker(time.Second)
+	links := []string{server.URL + "/ok", server.URL + "/gone"}
+
+	cache := Cache{}
+	cache.Add(checker.CheckAll(context.Background(), links, cache, time.Hour))
+
+	path := filepath.Join(t.TempDir(), "links.json")
+
+	err := cache.Save(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("Expected the cache file to be readable by everyone, got %v and %v", info, err)
+	}
+
+	loaded, err := LoadCache(path)
+	if err != nil || len(loaded) != 2 {
+		t.Fatalf("Got %v and %v from the cache file", loaded, err)
+	}
+
+	results := checker.CheckAll(context.Background(), links, loaded, time.Hour)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// The good link comes from the cache, and the broken one is checked again
+	if !results[0].Cached || results[1].Cached || requests["HEAD /ok"] != 1 || requests["HEAD /gone"] != 2 {
+		t.Errorf("Got %+v after %v", results, requests)
+	}
+
+	results = checker.CheckAll(context.Background(), links[:1], loaded, 0)
+	if results[0].Cached {
+		t.Error("Expected a result older than the max age to be checked again")
+	}
+
+	empty, err := LoadCache(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || len(empty) != 0 {
+		t.Errorf("Got %v and %v for a cache file that doesn't exist", empty, err)
+	}
+}
+
+func TestReadPaths(t *testing.T) {
+	tests := map[string]string{
+		"csv":   "path|action\nhttps://example.com/b|section\nhttps://example.com/a|Publish\nhttps://example.com/b|Subscribe\n",
+		"jsonl": `{"path":"https://example.com/b","action":"section"}` + "\n\n" + `{"path":"https://example.com/a"}` + "\n",
+		"yaml":  "files:\n  - path: https://example.com/b\n  - path: https://example.com/a\n",
+	}
+
+	for format, input := range tests {
+		paths, err := ReadPaths(strings.NewReader(input), format, '|')
+		if err != nil || len(paths) != 2 || paths[0] != "https://example.com/a" || paths[1] != "https://example.com/b" {
+			t.Errorf("Got %v and %v for %s", paths, err, format)
+		}
+	}
+}
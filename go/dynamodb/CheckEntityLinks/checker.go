@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Doug-AWS/code-examples/go/utilities/atomicfile"
+)
+
+// The kinds of result
+const (
+	KindOK       = "ok"
+	KindRedirect = "redirect"
+	KindBroken   = "broken"
+	KindTimeout  = "timeout"
+	KindError    = "error"
+)
+
+// Result is what checking a URL found
+type Result struct {
+	URL  string `json:"url"`
+	Kind string `json:"kind"`
+	// The status of the last response, or 0 if there wasn't one
+	Status int `json:"status,omitempty"`
+	// Where each redirect went, in order; the last one is where the URL ends up
+	Redirects []string  `json:"redirects,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Checked   time.Time `json:"checked"`
+	// Whether the result came from the cache file
+	Cached bool `json:"-"`
+}
+
+// Final returns where the URL ends up after its redirects
+func (r Result) Final() string {
+	if len(r.Redirects) == 0 {
+		return r.URL
+	}
+
+	return r.Redirects[len(r.Redirects)-1]
+}
+
+// Checker checks URLs with a pool of workers.
+// Each URL gets a HEAD request, and a GET request if the server doesn't allow HEAD.
+// Redirects are followed one at a time so every hop is recorded and rate limited.
+type Checker struct {
+	Client *http.Client
+	// How many URLs are checked at once
+	Workers int
+	// The least time between two requests to the same host; 0 means no limit
+	HostInterval time.Duration
+	// How many redirects are followed before giving up
+	MaxRedirects int
+
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+// NewChecker returns a Checker with eight workers that gives up on a request after timeout
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		Client: &http.Client{
+			Timeout: timeout,
+			// Don't follow redirects, so Check can record them
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		Workers:      8,
+		MaxRedirects: 10,
+	}
+}
+
+// wait waits until a request can be sent to the host without going over HostInterval
+func (c *Checker) wait(ctx context.Context, host string) error {
+	if c.HostInterval <= 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+
+	if c.next == nil {
+		c.next = map[string]time.Time{}
+	}
+
+	now := time.Now()
+	next := c.next[host]
+	if next.Before(now) {
+		next = now
+	}
+
+	c.next[host] = next.Add(c.HostInterval)
+
+	c.mu.Unlock()
+
+	select {
+	case <-time.After(next.Sub(now)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// request sends one request and returns its status and where it redirects to, if anywhere
+func (c *Checker) request(ctx context.Context, method string, u *url.URL) (int, string, error) {
+	err := c.wait(ctx, u.Host)
+	if err != nil {
+		return 0, "", err
+	}
+
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return 0, "", err
+	}
+
+	resp, err := c.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, "", err
+	}
+
+	// Read a little of the body so the connection can be reused
+	io.CopyN(ioutil.Discard, resp.Body, 64*1024)
+	resp.Body.Close()
+
+	location := ""
+
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		loc, err := resp.Location()
+		if err != nil {
+			return resp.StatusCode, "", errors.New("Got a " + strconv.Itoa(resp.StatusCode) + " redirect without a Location")
+		}
+
+		location = loc.String()
+	}
+
+	return resp.StatusCode, location, nil
+}
+
+// isTimeout returns whether the error is from a request that took too long
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Check checks one URL, following its redirects
+func (c *Checker) Check(ctx context.Context, link string) Result {
+	r := Result{URL: link, Checked: time.Now().UTC()}
+
+	fail := func(err error) Result {
+		r.Kind = KindError
+		if isTimeout(err) {
+			r.Kind = KindTimeout
+		}
+
+		r.Error = err.Error()
+
+		return r
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return fail(err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fail(errors.New("Not an http or https URL"))
+	}
+
+	for {
+		status, location, err := c.request(ctx, http.MethodHead, u)
+
+		// Some servers don't allow HEAD
+		if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+			status, location, err = c.request(ctx, http.MethodGet, u)
+		}
+
+		r.Status = status
+
+		if err != nil {
+			return fail(err)
+		}
+
+		if location == "" {
+			break
+		}
+
+		if len(r.Redirects) == c.MaxRedirects {
+			return fail(errors.New("Stopped after " + strconv.Itoa(c.MaxRedirects) + " redirects"))
+		}
+
+		u, err = u.Parse(location)
+		if err != nil {
+			return fail(err)
+		}
+
+		r.Redirects = append(r.Redirects, u.String())
+	}
+
+	switch {
+	case r.Status >= 400:
+		r.Kind = KindBroken
+	case len(r.Redirects) > 0:
+		r.Kind = KindRedirect
+	default:
+		r.Kind = KindOK
+	}
+
+	return r
+}
+
+// CheckAll checks each URL once and returns the results in the order of the URLs.
+// A result in the cache that isn't older than maxAge is used instead of checking again,
+// unless it was a failure, which is always checked again.
+func (c *Checker) CheckAll(ctx context.Context, links []string, cache Cache, maxAge time.Duration) []Result {
+	results := make([]Result, len(links))
+
+	workers := c.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				cached, ok := cache[links[i]]
+				if ok && (cached.Kind == KindOK || cached.Kind == KindRedirect) && time.Since(cached.Checked) <= maxAge {
+					cached.Cached = true
+					results[i] = cached
+					continue
+				}
+
+				results[i] = c.Check(ctx, links[i])
+			}
+		}()
+	}
+
+	for i := range links {
+		jobs <- i
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// Cache is the results of earlier checks by URL
+type Cache map[string]Result
+
+// LoadCache reads the cache file; a file that doesn't exist yet is an empty cache
+func LoadCache(path string) (Cache, error) {
+	cache := Cache{}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := []Result{}
+
+	err = json.Unmarshal(b, &results)
+	if err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+
+	for _, r := range results {
+		cache[r.URL] = r
+	}
+
+	return cache, nil
+}
+
+// Add adds the results to the cache
+func (cache Cache) Add(results []Result) {
+	for _, r := range results {
+		cache[r.URL] = r
+	}
+}
+
+// Save writes the cache, sorted by URL, through a temporary file and a rename
+func (cache Cache) Save(path string) error {
+	results := []Result{}
+	for _, r := range cache {
+		results = append(results, r)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].URL < results[j].URL
+	})
+
+	b, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(path, append(b, '\n'), 0644)
+}
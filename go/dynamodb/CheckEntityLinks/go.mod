@@ -0,0 +1,15 @@
+module mymain
+
+go 1.15
+
+require (
+	github.com/Doug-AWS/code-examples/go/dynamodb/entity v0.0.0
+	github.com/Doug-AWS/code-examples/go/utilities/atomicfile v0.0.0
+	github.com/aws/aws-sdk-go-v2/config v1.1.1
+	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.1.1
+	gopkg.in/yaml.v2 v2.2.8
+)
+
+replace github.com/Doug-AWS/code-examples/go/dynamodb/entity => ../entity
+
+replace github.com/Doug-AWS/code-examples/go/utilities/atomicfile => ../../utilities/atomicfile
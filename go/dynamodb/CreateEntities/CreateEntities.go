@@ -6,12 +6,17 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"html"
+	"io"
 	"io/ioutil"
 	"os"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"text/template"
 
+	"github.com/Doug-AWS/code-examples/go/dynamodb/registry"
+	"github.com/Doug-AWS/code-examples/go/utilities/atomicfile"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
@@ -31,15 +36,17 @@ type Entry struct {
 
 // Config stores the values from config.json
 type Config struct {
-	Table    string   `json:"TableName"`    // The name of the DynamoDB table
-	Services []string `json:"ServiceNames"` // The list of services
-	Sdks     []string `json:"SdkNames"`     // The list of SDKs, by language extension
+	Table string `json:"TableName"` // The name of the DynamoDB table
+	Index string `json:"IndexName"` // The global secondary index with service as its partition key
 }
 
 var configFileName = "config.json"
 
 var globalConfig Config
 
+// globalRegistry maps service and SDK names to their entity names
+var globalRegistry *registry.Registry
+
 func populateConfiguration() error {
 	content, err := ioutil.ReadFile(configFileName)
 	if err != nil {
@@ -53,293 +60,257 @@ func populateConfiguration() error {
 		return err
 	}
 
-	if globalConfig.Table == "" {
-		msg := "You musts supply a value for TableName in " + configFileName
+	if globalConfig.Table == "" || globalConfig.Index == "" {
+		msg := "You musts supply a value for TableName and IndexName in " + configFileName
 		return errors.New(msg)
 	}
 
 	return nil
 }
 
+// DynamoDBQueryAPI defines the interface for the Query function
+type DynamoDBQueryAPI interface {
+	Query(ctx context.Context,
+		params *dynamodb.QueryInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+}
+
 func debugPrint(debug bool, s string) {
 	if debug {
 		fmt.Println(s)
 	}
 }
 
-func getSdkEntityName(debug bool, sdk string) (string, error) {
-	retVal := ""
-	var err error
-
-	switch sdk {
-	case "java":
-		retVal = "JavaV2long"
-		break
-	case "js":
-		retVal = "JSBlong"
-		break
-	case "go":
-		retVal = "Golong"
-		break
-	case "php":
-		retVal = "PHPlong"
-		break
-	case "rb":
-		retVal = "Rubylong"
-		break
-
-	default:
-		msg := "Unidentified SDK: " + sdk
-		err = errors.New(msg)
-		break
-	}
+// QueryServiceEntries returns the entries for the service from the index, following LastEvaluatedKey
+func QueryServiceEntries(c context.Context, api DynamoDBQueryAPI, table string, index string, service string) ([]Entry, error) {
+	keyCond := expression.Key("service").Equal(expression.Value(service))
 
-	return retVal, err
-}
+	proj := expression.NamesList(expression.Name("path"), expression.Name("action"), expression.Name("service"), expression.Name("sdk"), expression.Name("target"), expression.Name("description"))
 
-func getServiceEntityName(debug bool, service string) (string, error) {
-	retVal := ""
-	var err error
-
-	switch service {
-	case "cloudwatch":
-		retVal = "CloudWatch"
-		break
-	case "dynamodb":
-		retVal = "DynamoDB"
-		break
-	case "ec2":
-		retVal = "EC2"
-		break
-	case "iam":
-		retVal = "IAM"
-		break
-	case "kinesis":
-		retVal = "Kinesis"
-		break
-	case "kms":
-		retVal = "KMS"
-		break
-	case "rekognition":
-		retVal = "Rekognition"
-		break
-	case "s3":
-		retVal = "S3"
-		break
-	case "sqs":
-		retVal = "SQS"
-		break
-	case "sns":
-		retVal = "SNS"
-		break
-	case "ssm":
-		retVal = "SSM"
-		break
-	case "sts":
-		retVal = "STS"
-		break
-
-	default:
-		msg := "Unidentified service: " + service
-		err = errors.New(msg)
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).WithProjection(proj).Build()
+	if err != nil {
+		fmt.Println("Got error building expression:")
+		return nil, err
+	}
 
+	input := &dynamodb.QueryInput{
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ProjectionExpression:      expr.Projection(),
+		IndexName:                 aws.String(index),
+		TableName:                 aws.String(table),
 	}
 
-	return retVal, err
-}
+	entries := []Entry{}
 
-/*
-   The entity for sns-code-examples (action == section);
-   I'm only showing go, but you get the drift:
-
-   <!ENTITY sns-code-examples '<table class="table">
-      <title>Amazon SNS code examples in AWS SDK developer guides</title>
-      <tgroup cols="1">
-        <tbody>
-          <row>
-            <entry>
-              <para><ulink url="https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns/">&Golong;</ulink></para>
-            </entry>
-          </row>
-        </tbody>
-      </tgroup>
-    </table>'>
-*/
-
-func createEntity(debug bool, service string, action string, actionEntries []Entry, f *os.File) error {
-	if action == "" {
-		return nil
-	}
+	for {
+		resp, err := api.Query(c, input)
+		if err != nil {
+			return nil, err
+		}
 
-	debugPrint(debug, "Got "+strconv.Itoa(len(actionEntries))+" entry/entries for action "+action)
+		page := []Entry{}
 
-	// Sort entries by sdk
-	sort.Slice(actionEntries[:], func(i, j int) bool {
-		return actionEntries[i].SDK < actionEntries[j].SDK
-	})
+		err = attributevalue.UnmarshalListOfMaps(resp.Items, &page)
+		if err != nil {
+			fmt.Println("Got an error unmarshalling table entries")
+			return nil, err
+		}
 
-	entity := ""
-	serviceEntity, err := getServiceEntityName(debug, service)
-	if err != nil {
-		msg := "Got an error retrieving the entity name for the " + service + " service"
-		return errors.New(msg)
-	}
+		entries = append(entries, page...)
 
-	if action == "section" {
-		debugPrint(debug, "Creating section entity for "+service)
-		entity += "<!ENTITY " + service + "-code-examples '<table class=\"table\">\n"
-		entity += "   <title>&" + serviceEntity + "; code examples in AWS SDK developer guides</title>\n"
-	} else {
-		debugPrint(debug, "Creating section/action entity for "+service+"/"+action)
-		entity += "<!ENTITY " + service + "-" + action + "-code-examples '<table class=\"table\">\n"
-		entity += "   <title>&" + serviceEntity + "; " + action + " code examples in AWS SDK developer guides</title>\n"
+		if len(resp.LastEvaluatedKey) == 0 {
+			break
+		}
+
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
 	}
 
-	entity += "   <tgroup cols=\"1\">\n"
-	entity += "     <tbody>\n"
+	return entries, nil
+}
 
-	for _, a := range actionEntries {
-		debugPrint(debug, "Path:        "+a.Path)
-		debugPrint(debug, "Description: "+a.Description)
+// sortEntries sorts the entries by action, SDK, path, and target,
+// so the entities come out the same no matter what order the table returns them in
+func sortEntries(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
 
-		if err != nil {
-			fmt.Println("Got an error retrieving the entity name for the " + a.SDK + " SDK")
+		if a.Action != b.Action {
+			return a.Action < b.Action
 		}
 
-		// Escape characters in description
-		description := html.EscapeString(a.Description)
+		if a.SDK != b.SDK {
+			return a.SDK < b.SDK
+		}
 
-		entity += "       <row>\n"
-		entity += "         <entry>\n"
-		entity += "           <para><ulink url=\"" + a.Path + "\">" + description + "</ulink></para>\n"
-		entity += "         </entry>\n"
-		entity += "       </row>\n"
-	}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
 
-	entity += "     </tbody>\n"
-	entity += "   </tgroup>\n"
-	entity += " </table>'>\n"
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
 
-	_, err = f.WriteString(entity + "\n")
-	if err != nil {
-		fmt.Println("Got an error creating entity for " + service + " action " + action)
-		return err
-	}
+		return a.Description < b.Description
+	})
+}
 
-	return nil
+// Group is the entries for one action of a service, which become one entity
+type Group struct {
+	// The name of the entity, such as sns-code-examples or sns-Publish-code-examples
+	Name    string  `json:"name"`
+	Action  string  `json:"action"`
+	Section bool    `json:"section"` // Whether the action is "section"
+	Entries []Entry `json:"entries"`
 }
 
-func createServiceEntities(debug bool, table string, service string) error {
-	outFileName := service + ".ent"
-	debugPrint(debug, "Creating entities for "+service+" service in "+outFileName)
+// ServiceData is what a template gets for each service
+type ServiceData struct {
+	Service string  `json:"service"`
+	Entity  string  `json:"entity"` // The DocBook entity for the service, such as SNS
+	Name    string  `json:"name"`   // The name to show people, such as Amazon SNS
+	Groups  []Group `json:"groups"`
+}
 
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+// newServiceData groups the service's entries by action
+func newServiceData(service string, entries []Entry) (ServiceData, error) {
+	e, err := globalRegistry.Service(service)
 	if err != nil {
-		fmt.Println("Got a configuration error")
-		return err
+		return ServiceData{}, err
 	}
 
-	dynamodbClient := dynamodb.NewFromConfig(cfg)
+	data := ServiceData{
+		Service: service,
+		Entity:  e.Entity,
+		Name:    e.Name,
+		Groups:  []Group{},
+	}
 
-	// Get items for that service
-	filt := expression.Name("service").Equal(expression.Value(service))
+	if data.Name == "" {
+		data.Name = service
+	}
 
-	// Get back the title and rating (we know the year).
-	proj := expression.NamesList(expression.Name("path"), expression.Name("action"), expression.Name("service"), expression.Name("sdk"), expression.Name("target"), expression.Name("description"))
+	sortEntries(entries)
 
-	expr, err := expression.NewBuilder().WithFilter(filt).WithProjection(proj).Build()
-	if err != nil {
-		fmt.Println("Got error building expression:")
-		return err
-	}
+	for _, entry := range entries {
+		n := len(data.Groups)
+		if n > 0 && data.Groups[n-1].Action == entry.Action {
+			data.Groups[n-1].Entries = append(data.Groups[n-1].Entries, entry)
+			continue
+		}
 
-	input := &dynamodb.ScanInput{
-		ExpressionAttributeNames:  expr.Names(),
-		ExpressionAttributeValues: expr.Values(),
-		FilterExpression:          expr.Filter(),
-		ProjectionExpression:      expr.Projection(),
-		TableName:                 aws.String(table),
+		name := service + "-" + entry.Action + "-code-examples"
+		if entry.Action == "section" {
+			name = service + "-code-examples"
+		}
+
+		data.Groups = append(data.Groups, Group{
+			Name:    name,
+			Action:  entry.Action,
+			Section: entry.Action == "section",
+			Entries: []Entry{entry},
+		})
 	}
 
-	resp, err := dynamodbClient.Scan(context.TODO(), input)
+	return data, nil
+}
+
+// createServiceEntities writes the service's entries with the template
+func createServiceEntities(debug bool, service string, entries []Entry, tmpl *template.Template, w io.Writer) error {
+	data, err := newServiceData(service, entries)
 	if err != nil {
-		fmt.Println("Got error calling Scan: ")
 		return err
 	}
 
-	entries := []Entry{}
+	for _, g := range data.Groups {
+		debugPrint(debug, "Got "+strconv.Itoa(len(g.Entries))+" entry/entries for action "+g.Action)
+	}
 
-	err = attributevalue.UnmarshalListOfMaps(resp.Items, &entries)
+	err = tmpl.Execute(w, data)
 	if err != nil {
-		fmt.Println("Got an error unmarshalling table entries")
-		return err
+		return errors.New("Got an error creating entities for " + service + ": " + err.Error())
 	}
 
-	if debug {
-		fmt.Println("path:        " + entries[0].Path)
-		fmt.Println("Action:      " + entries[0].Action)
-		fmt.Println("SDK:         " + entries[0].SDK)
-		fmt.Println("Service:     " + entries[0].Service)
-		fmt.Println("Target:      " + entries[0].Target)
-		fmt.Println("Description: " + entries[0].Description)
-	}
+	return nil
+}
 
-	debugPrint(debug, "Creating output file: "+outFileName)
+// ServiceEntities is the entities for one service
+type ServiceEntities struct {
+	Service  string
+	Entries  int
+	Entities string
+	Err      error
+}
 
-	f, err := os.OpenFile(outFileName, os.O_RDWR|os.O_CREATE, 0644)
-	if err != nil {
-		fmt.Println("Got an error opening " + outFileName)
-		return err
+// GenerateEntities queries the entries for every service and creates their entities with the template,
+// working on up to workers services at once.
+// The results are in the same order as services, however long each one takes.
+func GenerateEntities(c context.Context, debug bool, api DynamoDBQueryAPI, table string, index string, services []string, tmpl *template.Template, workers int) []ServiceEntities {
+	results := make([]ServiceEntities, len(services))
+
+	if workers < 1 {
+		workers = 1
 	}
 
-	defer f.Close()
+	jobs := make(chan int)
 
-	// Sort entries by Action
-	sort.Slice(entries[:], func(i, j int) bool {
-		return entries[i].Action < entries[j].Action
-	})
+	var wg sync.WaitGroup
 
-	initAction := ""
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
 
-	i := 0
-	var actionEntries []Entry
+		go func() {
+			defer wg.Done()
 
-	for i < len(entries) {
-		if entries[i].Action != initAction {
-			// Create entity from set of actions
-			err := createEntity(debug, service, initAction, actionEntries, f)
-			if err != nil {
-				fmt.Println("Got an error creating entity")
-				return err
-			}
+			for i := range jobs {
+				r := &results[i]
+				r.Service = services[i]
 
-			// Reset actionEntries
-			actionEntries = nil
-			actionEntries = make([]Entry, 1)
+				debugPrint(debug, "Creating entities for "+r.Service+" service")
 
-			initAction = entries[i].Action
+				entries, err := QueryServiceEntries(c, api, table, index, r.Service)
+				if err != nil {
+					r.Err = err
+					continue
+				}
 
-			debugPrint(debug, "Looking for entries with "+initAction+" action")
+				r.Entries = len(entries)
 
-			actionEntries[0] = entries[i]
-		} else {
-			actionEntries = append(actionEntries, entries[i])
-		}
+				var b strings.Builder
 
-		i++
+				r.Err = createServiceEntities(debug, r.Service, entries, tmpl, &b)
+				r.Entities = b.String()
+			}
+		}()
 	}
 
-	// We have to create an entity for the last item
-	err = createEntity(debug, service, initAction, actionEntries, f)
-	if err != nil {
-		fmt.Println("Got an error creating entity")
-		return err
+	for i := range services {
+		jobs <- i
 	}
 
-	return nil
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// readFile returns the contents of the file, or nothing if it doesn't exist yet
+func readFile(name string) (string, error) {
+	b, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+
+	return string(b), err
 }
 
 func main() {
+	registryFile := flag.String("registry", registry.DefaultFile, "The YAML file with the services and SDKs")
+	format := flag.String("f", "docbook", "The built-in template to use: "+strings.Join(formatNames(), ", "))
+	templateFile := flag.String("template", "", "A text/template file to use instead of a built-in template; see templates.go for what it gets")
+	workers := flag.Int("w", 4, "How many services to query at once")
+	check := flag.Bool("check", false, "Don't write any files; print a diff of each file that isn't current and exit with 1 if any aren't")
 	debug := flag.Bool("d", false, "Whether to barf out more info")
 
 	flag.Parse()
@@ -349,13 +320,79 @@ func main() {
 	err := populateConfiguration()
 	if err != nil {
 		fmt.Println("Could not parse " + configFileName)
-		return
+		os.Exit(1)
+	}
+
+	globalRegistry, err = registry.Load(*registryFile)
+	if err != nil {
+		fmt.Println("Could not load the services and SDKs: " + err.Error())
+		os.Exit(1)
 	}
 
-	for _, e := range globalConfig.Services {
-		err := createServiceEntities(*debug, globalConfig.Table, e)
+	tmpl, ext, err := LoadTemplate(*format, *templateFile)
+	if err != nil {
+		fmt.Println("Could not load the template: " + err.Error())
+		os.Exit(1)
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		fmt.Println("Got a configuration error")
+		os.Exit(1)
+	}
+
+	results := GenerateEntities(context.TODO(), *debug, dynamodb.NewFromConfig(cfg), globalConfig.Table, globalConfig.Index, globalRegistry.ServiceIDs(), tmpl, *workers)
+
+	failed := false
+	stale := 0
+
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Println("Could not create entities for " + r.Service + " service: " + r.Err.Error())
+			failed = true
+			continue
+		}
+
+		outFileName := r.Service + ext
+
+		old, err := readFile(outFileName)
 		if err != nil {
-			fmt.Println("Could not create entities for " + e + " service")
+			fmt.Println("Got an error reading " + outFileName + ": " + err.Error())
+			failed = true
+			continue
 		}
+
+		// Leave a file that's already current alone, so its modification time doesn't change
+		if old == r.Entities {
+			debugPrint(*debug, outFileName+" is current")
+			continue
+		}
+
+		stale++
+
+		if *check {
+			fmt.Print(UnifiedDiff(outFileName, outFileName+" (generated)", old, r.Entities))
+			continue
+		}
+
+		debugPrint(*debug, "Creating output file: "+outFileName)
+
+		err = atomicfile.WriteFile(outFileName, []byte(r.Entities), 0644)
+		if err != nil {
+			fmt.Println("Got an error writing " + outFileName + ": " + err.Error())
+			failed = true
+			continue
+		}
+
+		debugPrint(*debug, "Wrote "+strconv.Itoa(r.Entries)+" entries for "+r.Service+" to "+outFileName)
+	}
+
+	if *check && stale > 0 {
+		fmt.Println(strconv.Itoa(stale) + " of " + strconv.Itoa(len(results)) + " files are not current; run CreateEntities without -check to update them")
+		failed = true
+	}
+
+	if failed {
+		os.Exit(1)
 	}
 }
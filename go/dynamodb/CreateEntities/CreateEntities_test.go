@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Doug-AWS/code-examples/go/dynamodb/registry"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// mockQueryClient returns the items of a service one page at a time
+type mockQueryClient struct {
+	mu      sync.Mutex
+	queries int
+	items   map[string][]map[string]types.AttributeValue
+}
+
+func (m *mockQueryClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	m.mu.Lock()
+	m.queries++
+	m.mu.Unlock()
+
+	if params.IndexName == nil || *params.IndexName != "service-index" || params.KeyConditionExpression == nil {
+		return nil, &types.ResourceNotFoundException{}
+	}
+
+	service := ""
+	for _, v := range params.ExpressionAttributeValues {
+		service = v.(*types.AttributeValueMemberS).Value
+	}
+
+	page := 0
+	if params.ExclusiveStartKey != nil {
+		page, _ = strconv.Atoi(params.ExclusiveStartKey["page"].(*types.AttributeValueMemberN).Value)
+	}
+
+	items := m.items[service]
+	resp := &dynamodb.QueryOutput{}
+
+	if page < len(items) {
+		resp.Items = items[page : page+1]
+	}
+
+	if page+1 < len(items) {
+		resp.LastEvaluatedKey = map[string]types.AttributeValue{"page": &types.AttributeValueMemberN{Value: strconv.Itoa(page + 1)}}
+	}
+
+	return resp, nil
+}
+
+func item(path, action, sdk, service string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"path":        &types.AttributeValueMemberS{Value: path},
+		"action":      &types.AttributeValueMemberS{Value: action},
+		"sdk":         &types.AttributeValueMemberS{Value: sdk},
+		"service":     &types.AttributeValueMemberS{Value: service},
+		"target":      &types.AttributeValueMemberS{Value: "guide"},
+		"description": &types.AttributeValueMemberS{Value: "Uses " + action + " in " + sdk},
+	}
+}
+
+func init() {
+	var err error
+
+	globalRegistry, err = registry.Parse([]byte(`
+services:
+  - {id: s3, entity: S3}
+  - {id: sns, entity: SNS, name: Amazon SNS}
+  - {id: sqs, entity: SQS}
+sdks:
+  - {id: go, entity: Golong, name: AWS SDK for Go V2}
+  - {id: java, entity: JavaV2long}
+`))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestGenerateEntities(t *testing.T) {
+
+	client := &mockQueryClient{items: map[string][]map[string]types.AttributeValue{
+		"sns": {
+			item("https://docs.aws.amazon.com/sns/java", "section", "java", "sns"),
+			item("https://example.com/sns/publish", "Publish", "java", "sns"),
+			item("https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns", "section", "go", "sns"),
+			item("https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns/publish", "Publish", "go", "sns"),
+		},
+		"s3": {
+			item("https://aws.github.io/aws-sdk-go-v2/docs/code-examples/s3", "section", "go", "s3"),
+		},
+	}}
+
+	services := globalRegistry.ServiceIDs()
+
+	tmpl, _, err := LoadTemplate("docbook", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := GenerateEntities(context.Background(), false, client, "CodeExamplesEntries", "service-index", services, tmpl, 3)
+
+	if client.queries != 6 {
+		t.Errorf("Expected a query for each page of each service, got %d", client.queries)
+	}
+
+	for i, r := range results {
+		if r.Service != services[i] || r.Err != nil {
+			t.Errorf("Got %s and %v for %s", r.Service, r.Err, services[i])
+		}
+	}
+
+	if results[1].Entries != 4 || results[2].Entries != 0 || results[2].Entities != "" {
+		t.Errorf("Got %d sns entries and %d sqs entries", results[1].Entries, results[2].Entries)
+	}
+
+	sns := results[1].Entities
+
+	// Actions are in order, and so are the SDKs within an action
+	order := []string{"sns-Publish-code-examples", "Publish in go", "Publish in java", "sns-code-examples", "section in go", "section in java"}
+
+	last := -1
+	for _, s := range order {
+		i := strings.Index(sns, s)
+		if i <= last {
+			t.Fatalf("Expected %q after the text before it in:\n%s", s, sns)
+		}
+
+		last = i
+	}
+
+	again := GenerateEntities(context.Background(), false, client, "CodeExamplesEntries", "service-index", services, tmpl, 1)
+	if again[1].Entities != sns {
+		t.Error("Expected the same entities from one worker as from three")
+	}
+}
+
+func render(t *testing.T, format string, file string) string {
+	tmpl, _, err := LoadTemplate(format, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []Entry{
+		{Path: "https://example.com/sns/publish", Action: "Publish", SDK: "java", Service: "sns", Target: "guide", Description: "Publishes a message"},
+		{Path: "https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns", Action: "section", SDK: "go", Service: "sns", Target: "guide", Description: "Topics | <subscriptions>"},
+	}
+
+	var b strings.Builder
+
+	err = createServiceEntities(false, "sns", entries, tmpl, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b.String()
+}
+
+func TestTemplates(t *testing.T) {
+	docbook := `<!ENTITY sns-Publish-code-examples '<table class="table">
+   <title>&SNS; Publish code examples in AWS SDK developer guides</title>
+   <tgroup cols="1">
+     <tbody>
+       <row>
+         <entry>
+           <para><ulink url="https://example.com/sns/publish">Publishes a message</ulink></para>
+         </entry>
+       </row>
+     </tbody>
+   </tgroup>
+ </table>'>
+
+<!ENTITY sns-code-examples '<table class="table">
+   <title>&SNS; code examples in AWS SDK developer guides</title>
+   <tgroup cols="1">
+     <tbody>
+       <row>
+         <entry>
+           <para><ulink url="https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns">Topics | &lt;subscriptions&gt;</ulink></para>
+         </entry>
+       </row>
+     </tbody>
+   </tgroup>
+ </table>'>
+
+`
+
+	if got := render(t, "docbook", ""); got != docbook {
+		t.Errorf("Got DocBook:\n%s", got)
+	}
+
+	markdown := render(t, "markdown", "")
+	if !strings.HasPrefix(markdown, "# Amazon SNS code examples\n") || !strings.Contains(markdown, "| section | AWS SDK for Go V2 | [Topics \\| <subscriptions>](https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns) |\n") || !strings.Contains(markdown, "| Publish | java |") {
+		t.Errorf("Got Markdown:\n%s", markdown)
+	}
+
+	fragment := render(t, "html", "")
+	if !strings.Contains(fragment, `<li><a href="https://example.com/sns/publish">Publishes a message</a> (java)</li>`) || strings.Contains(fragment, "<subscriptions>") {
+		t.Errorf("Got HTML:\n%s", fragment)
+	}
+
+	var data ServiceData
+
+	err := json.Unmarshal([]byte(render(t, "json", "")), &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.Entity != "SNS" || len(data.Groups) != 2 || !data.Groups[1].Section || data.Groups[1].Entries[0].SDK != "go" {
+		t.Errorf("Got JSON %v", data)
+	}
+
+	file := filepath.Join(t.TempDir(), "list.md.tmpl")
+
+	err = ioutil.WriteFile(file, []byte("{{range .Groups}}{{.Name}}: {{len .Entries}}\n{{end}}"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ext, err := LoadTemplate("", file)
+	if err != nil || ext != ".md" {
+		t.Errorf("Got %s and %v for %s", ext, err, file)
+	}
+
+	if got := render(t, "", file); got != "sns-Publish-code-examples: 1\nsns-code-examples: 1\n" {
+		t.Errorf("Got %q from the template file", got)
+	}
+
+	_, _, err = LoadTemplate("pdf", "")
+	if err == nil || !strings.Contains(err.Error(), "docbook, html, json, markdown") {
+		t.Errorf("Got %v for an unknown format", err)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	if UnifiedDiff("a", "b", "same\n", "same\n") != "" {
+		t.Error("Expected no diff for the same text")
+	}
+
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n"
+	generated := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n17\n"
+
+	want := `--- sns.ent
++++ sns.ent (generated)
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -14,3 +14,4 @@
+ 14
+ 15
+ 16
++17
+`
+
+	if got := UnifiedDiff("sns.ent", "sns.ent (generated)", old, generated); got != want {
+		t.Errorf("Got diff:\n%s", got)
+	}
+
+	if got := UnifiedDiff("sns.ent", "sns.ent (generated)", "", "1\n"); got != "--- sns.ent\n+++ sns.ent (generated)\n@@ -0,0 +1,1 @@\n+1\n" {
+		t.Errorf("Got diff for a new file:\n%s", got)
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "sns.ent")
+
+	err := ioutil.WriteFile(name, []byte("sns\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readFile(name)
+	if err != nil || got != "sns\n" {
+		t.Errorf("Got %q and %v", got, err)
+	}
+
+	got, err = readFile(name + ".missing")
+	if err != nil || got != "" {
+		t.Errorf("Got %q and %v for a file that doesn't exist", got, err)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// How many unchanged lines a diff shows around each change
+const diffContext = 3
+
+// diffLine is a line of a diff: ' ' for a line in both, '-' for one only in the old text, '+' for one only in the new
+type diffLine struct {
+	kind byte
+	text string
+}
+
+// splitLines splits text into lines, without an empty line after the last newline
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines returns the edits that turn a into b, keeping the longest common subsequence of lines
+func diffLines(a, b []string) []diffLine {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	lines := []diffLine{}
+	i, j := 0, 0
+
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+
+	return lines
+}
+
+// hunkRange returns the start and length of a hunk in the form a unified diff uses
+func hunkRange(start, length int) string {
+	// An empty range starts at the line before it
+	if length == 0 {
+		start--
+	}
+
+	return strconv.Itoa(start) + "," + strconv.Itoa(length)
+}
+
+// UnifiedDiff returns the unified diff that turns oldText, named fromName, into newText, named toName,
+// or an empty string if they're the same
+func UnifiedDiff(fromName, toName, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+
+	lines := diffLines(splitLines(oldText), splitLines(newText))
+
+	var b strings.Builder
+
+	b.WriteString("--- " + fromName + "\n")
+	b.WriteString("+++ " + toName + "\n")
+
+	// oldLine and newLine are the line numbers, from 1, of lines[i] in each text
+	oldLine, newLine := 1, 1
+
+	for i := 0; i < len(lines); {
+		if lines[i].kind == ' ' {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+
+		// A hunk starts diffContext lines before the change and ends when there are
+		// more than twice that many unchanged lines before the next one
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+
+		end := i
+		for end < len(lines) {
+			if lines[end].kind != ' ' {
+				end++
+				continue
+			}
+
+			next := end
+			for next < len(lines) && lines[next].kind == ' ' {
+				next++
+			}
+
+			if next == len(lines) || next-end > 2*diffContext {
+				end += diffContext
+				if end > len(lines) {
+					end = len(lines)
+				}
+
+				break
+			}
+
+			end = next
+		}
+
+		oldStart, newStart := oldLine-(i-start), newLine-(i-start)
+		oldCount, newCount := 0, 0
+
+		for _, l := range lines[start:end] {
+			if l.kind != '+' {
+				oldCount++
+			}
+
+			if l.kind != '-' {
+				newCount++
+			}
+		}
+
+		b.WriteString("@@ -" + hunkRange(oldStart, oldCount) + " +" + hunkRange(newStart, newCount) + " @@\n")
+
+		for _, l := range lines[start:end] {
+			b.WriteString(string(l.kind) + l.text + "\n")
+		}
+
+		for _, l := range lines[i:end] {
+			if l.kind != '+' {
+				oldLine++
+			}
+
+			if l.kind != '-' {
+				newLine++
+			}
+		}
+
+		i = end
+	}
+
+	return b.String()
+}
@@ -3,9 +3,15 @@ module mymain
 go 1.15
 
 require (
+	github.com/Doug-AWS/code-examples/go/dynamodb/registry v0.0.0
+	github.com/Doug-AWS/code-examples/go/utilities/atomicfile v0.0.0
 	github.com/aws/aws-sdk-go-v2 v1.2.0
 	github.com/aws/aws-sdk-go-v2/config v1.1.1
 	github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue v1.0.2
 	github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression v1.0.2
 	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.1.1
 )
+
+replace github.com/Doug-AWS/code-examples/go/dynamodb/registry => ../registry
+
+replace github.com/Doug-AWS/code-examples/go/utilities/atomicfile => ../../utilities/atomicfile
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"html"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Format is a built-in template and the extension of the files it writes
+type Format struct {
+	Ext  string
+	Text string
+}
+
+/*
+   The entity for sns-code-examples (action == section);
+   I'm only showing go, but you get the drift:
+
+   <!ENTITY sns-code-examples '<table class="table">
+      <title>&SNS; code examples in AWS SDK developer guides</title>
+      <tgroup cols="1">
+        <tbody>
+          <row>
+            <entry>
+              <para><ulink url="https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns/">&Golong;</ulink></para>
+            </entry>
+          </row>
+        </tbody>
+      </tgroup>
+    </table>'>
+*/
+
+// docbookTemplate writes a DocBook entity like the one above for each action
+const docbookTemplate = `{{range .Groups -}}
+<!ENTITY {{.Name}} '<table class="table">
+   <title>&{{$.Entity}}; {{if not .Section}}{{.Action}} {{end}}code examples in AWS SDK developer guides</title>
+   <tgroup cols="1">
+     <tbody>
+{{- range .Entries}}
+       <row>
+         <entry>
+           <para><ulink url="{{.Path}}">{{escape .Description}}</ulink></para>
+         </entry>
+       </row>
+{{- end}}
+     </tbody>
+   </tgroup>
+ </table>'>
+
+{{end}}`
+
+const markdownTemplate = `# {{.Name}} code examples
+
+| Action | SDK | Example |
+| --- | --- | --- |
+{{range .Groups}}{{$action := .Action}}{{range .Entries -}}
+| {{$action}} | {{sdkName .SDK}} | [{{markdown .Description}}]({{.Path}}) |
+{{end}}{{end}}`
+
+const htmlTemplate = `<section id="{{.Service}}-code-examples">
+  <h2>{{escape .Name}} code examples</h2>
+{{- range .Groups}}
+  <h3 id="{{.Name}}">{{if .Section}}General{{else}}{{.Action}}{{end}}</h3>
+  <ul>
+{{- range .Entries}}
+    <li><a href="{{escape .Path}}">{{escape .Description}}</a> ({{escape (sdkName .SDK)}})</li>
+{{- end}}
+  </ul>
+{{- end}}
+</section>
+`
+
+const jsonTemplate = `{{json .}}
+`
+
+// formats are the built-in templates
+var formats = map[string]Format{
+	"docbook":  {Ext: ".ent", Text: docbookTemplate},
+	"markdown": {Ext: ".md", Text: markdownTemplate},
+	"html":     {Ext: ".html", Text: htmlTemplate},
+	"json":     {Ext: ".json", Text: jsonTemplate},
+}
+
+// templateFuncs are the functions every template can use:
+//
+//	escape:   escapes <, >, &, ', and " for DocBook and HTML
+//	markdown: escapes the characters that would break a Markdown table cell or link text
+//	json:     the value as indented JSON
+//	sdkName:  the name of the SDK in the registry, such as AWS SDK for Go V2
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"escape": html.EscapeString,
+		"markdown": strings.NewReplacer(
+			`\`, `\\`,
+			"|", `\|`,
+			"[", `\[`,
+			"]", `\]`,
+			"\n", " ",
+		).Replace,
+		"json": func(v interface{}) (string, error) {
+			b, err := json.MarshalIndent(v, "", "  ")
+			return string(b), err
+		},
+		"sdkName": func(sdk string) string {
+			e, err := globalRegistry.SDK(sdk)
+			if err != nil || e.Name == "" {
+				return sdk
+			}
+
+			return e.Name
+		},
+	}
+}
+
+// formatNames returns the names of the built-in templates in alphabetical order
+func formatNames() []string {
+	names := []string{}
+	for name := range formats {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// LoadTemplate returns the built-in template for format or, if file isn't empty, the template in file,
+// and the extension of the files to write.
+// The extension of a template file comes from its name without .tmpl,
+// so markdown.md.tmpl writes SERVICE.md; a file without one writes SERVICE.txt.
+func LoadTemplate(format string, file string) (*template.Template, string, error) {
+	if file != "" {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, "", err
+		}
+
+		name := filepath.Base(file)
+
+		tmpl, err := template.New(name).Funcs(templateFuncs()).Option("missingkey=error").Parse(string(b))
+		if err != nil {
+			return nil, "", err
+		}
+
+		ext := filepath.Ext(strings.TrimSuffix(name, ".tmpl"))
+		if ext == "" {
+			ext = ".txt"
+		}
+
+		return tmpl, ext, nil
+	}
+
+	f, ok := formats[format]
+	if !ok {
+		return nil, "", errors.New("The format must be one of " + strings.Join(formatNames(), ", ") + ", not " + format)
+	}
+
+	tmpl, err := template.New(format).Funcs(templateFuncs()).Option("missingkey=error").Parse(f.Text)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return tmpl, f.Ext, nil
+}
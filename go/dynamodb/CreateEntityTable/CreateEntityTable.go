@@ -1,55 +1,797 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"gopkg.in/yaml.v2"
 )
 
-// Config stores the values from config.json
-type Config struct {
-	Table        string `json:"TableName"`
-	PartitionKey string `json:"PartitionKeyName"`
-	SortKey      string `json:"SortKeyName"`
+// DynamoDBTableAPI defines the interface for the DynamoDB functions used to create and update the table
+type DynamoDBTableAPI interface {
+	DescribeTable(ctx context.Context,
+		params *dynamodb.DescribeTableInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+
+	CreateTable(ctx context.Context,
+		params *dynamodb.CreateTableInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+
+	UpdateTable(ctx context.Context,
+		params *dynamodb.UpdateTableInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error)
+
+	DescribeTimeToLive(ctx context.Context,
+		params *dynamodb.DescribeTimeToLiveInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error)
+
+	UpdateTimeToLive(ctx context.Context,
+		params *dynamodb.UpdateTimeToLiveInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error)
+}
+
+// Key is a key attribute; the type is S, N, or B and defaults to S
+type Key struct {
+	Name string `yaml:"name" json:"name"`
+	Type string `yaml:"type" json:"type"`
+}
+
+// Throughput is the provisioned capacity of a table or index
+type Throughput struct {
+	Read  int64 `yaml:"read" json:"read"`
+	Write int64 `yaml:"write" json:"write"`
+}
+
+// Index is a global secondary index.
+// The projection is ALL, KEYS_ONLY, or INCLUDE and defaults to ALL.
+type Index struct {
+	Name             string      `yaml:"name" json:"name"`
+	PartitionKey     Key         `yaml:"partitionKey" json:"partitionKey"`
+	SortKey          *Key        `yaml:"sortKey" json:"sortKey"`
+	Projection       string      `yaml:"projection" json:"projection"`
+	NonKeyAttributes []string    `yaml:"nonKeyAttributes" json:"nonKeyAttributes"`
+	Throughput       *Throughput `yaml:"throughput" json:"throughput"`
+}
+
+// TTL names the attribute that holds the time each item expires
+type TTL struct {
+	Attribute string `yaml:"attribute" json:"attribute"`
+	Enabled   bool   `yaml:"enabled" json:"enabled"`
+}
+
+// Stream is the table's stream; the view type defaults to NEW_AND_OLD_IMAGES
+type Stream struct {
+	Enabled  bool   `yaml:"enabled" json:"enabled"`
+	ViewType string `yaml:"viewType" json:"viewType"`
+}
+
+// Schema is the table described in the schema file:
+//
+//	tableName: CodeExamplesEntries
+//	billingMode: PAY_PER_REQUEST
+//	partitionKey:
+//	  name: path
+//	sortKey:
+//	  name: action
+//	ttl:
+//	  attribute: expires
+//	  enabled: true
+//	stream:
+//	  enabled: true
+//	  viewType: NEW_AND_OLD_IMAGES
+//
+// A PROVISIONED table also needs throughput for the table and every index.
+// Leaving out ttl or stream leaves them as they are.
+type Schema struct {
+	TableName              string      `yaml:"tableName" json:"tableName"`
+	BillingMode            string      `yaml:"billingMode" json:"billingMode"`
+	Throughput             *Throughput `yaml:"throughput" json:"throughput"`
+	PartitionKey           Key         `yaml:"partitionKey" json:"partitionKey"`
+	SortKey                *Key        `yaml:"sortKey" json:"sortKey"`
+	GlobalSecondaryIndexes []Index     `yaml:"globalSecondaryIndexes" json:"globalSecondaryIndexes"`
+	TTL                    *TTL        `yaml:"ttl" json:"ttl"`
+	Stream                 *Stream     `yaml:"stream" json:"stream"`
 }
 
-var configFileName = "config.json"
+// Change is one call that brings the table closer to the schema.
+// Exactly one of the inputs is set.
+type Change struct {
+	Description string
+	Create      *dynamodb.CreateTableInput
+	Update      *dynamodb.UpdateTableInput
+	TTL         *dynamodb.UpdateTimeToLiveInput
+}
 
-var globalConfig Config
+// How often and how many times Apply checks whether the table is ACTIVE after a change
+var pollInterval = 5 * time.Second
+var maxPolls = 360
 
-func populateConfiguration() error {
-	content, err := ioutil.ReadFile(configFileName)
+// LoadSchema reads a YAML or, if the file ends in .json, a JSON schema and fills in the defaults.
+// Unknown fields are an error so typos don't go unnoticed.
+func LoadSchema(path string) (*Schema, error) {
+	b, err := ioutil.ReadFile(path)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	var schema Schema
+
+	if filepath.Ext(path) == ".json" {
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		err = d.Decode(&schema)
+	} else {
+		err = yaml.UnmarshalStrict(b, &schema)
+	}
+
+	if err != nil {
+		return nil, errors.New("Could not parse " + path + ": " + err.Error())
+	}
+
+	err = schema.validate()
+	if err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+
+	return &schema, nil
+}
+
+func validKey(k *Key, what string) error {
+	if k.Name == "" {
+		return errors.New("The " + what + " needs a name")
+	}
+
+	if k.Type == "" {
+		k.Type = string(types.ScalarAttributeTypeS)
+	}
+
+	switch types.ScalarAttributeType(k.Type) {
+	case types.ScalarAttributeTypeS, types.ScalarAttributeTypeN, types.ScalarAttributeTypeB:
+		return nil
+	}
+
+	return errors.New("The " + what + " " + k.Name + " has type " + k.Type + "; it must be S, N, or B")
+}
+
+// validate checks the schema and fills in the defaults
+func (s *Schema) validate() error {
+	if s.TableName == "" {
+		return errors.New("The schema needs a tableName")
+	}
+
+	if s.BillingMode == "" {
+		s.BillingMode = string(types.BillingModePayPerRequest)
+	}
+
+	provisioned := s.BillingMode == string(types.BillingModeProvisioned)
+
+	if !provisioned && s.BillingMode != string(types.BillingModePayPerRequest) {
+		return errors.New("The billingMode must be PAY_PER_REQUEST or PROVISIONED, not " + s.BillingMode)
+	}
+
+	if provisioned != (s.Throughput != nil) {
+		return errors.New("A table needs throughput if and only if its billingMode is PROVISIONED")
 	}
 
-	text := string(content)
+	// Every attribute must have the same type wherever it's a key
+	attrs := map[string]string{}
+
+	addKey := func(k *Key, what string) error {
+		err := validKey(k, what)
+		if err != nil {
+			return err
+		}
+
+		t, ok := attrs[k.Name]
+		if ok && t != k.Type {
+			return errors.New("The attribute " + k.Name + " is a key of type " + t + " and " + k.Type)
+		}
+
+		attrs[k.Name] = k.Type
 
-	err = json.Unmarshal([]byte(text), &globalConfig)
+		return nil
+	}
+
+	err := addKey(&s.PartitionKey, "partitionKey")
 	if err != nil {
 		return err
 	}
 
-	if globalConfig.Table == "" || globalConfig.PartitionKey == "" || globalConfig.SortKey == "" {
-		msg := "You musts supply a value for TableName, PartitionKeyName, SortKeyName in " + configFileName
-		return errors.New(msg)
+	if s.SortKey != nil {
+		err = addKey(s.SortKey, "sortKey")
+		if err != nil {
+			return err
+		}
+	}
+
+	seen := map[string]bool{}
+
+	for i := range s.GlobalSecondaryIndexes {
+		index := &s.GlobalSecondaryIndexes[i]
+
+		if index.Name == "" {
+			return errors.New("Every index needs a name")
+		}
+
+		if seen[index.Name] {
+			return errors.New("The index " + index.Name + " is in the schema more than once")
+		}
+
+		seen[index.Name] = true
+
+		err = addKey(&index.PartitionKey, "partitionKey of "+index.Name)
+		if err != nil {
+			return err
+		}
+
+		if index.SortKey != nil {
+			err = addKey(index.SortKey, "sortKey of "+index.Name)
+			if err != nil {
+				return err
+			}
+		}
+
+		if index.Projection == "" {
+			index.Projection = string(types.ProjectionTypeAll)
+		}
+
+		switch types.ProjectionType(index.Projection) {
+		case types.ProjectionTypeAll, types.ProjectionTypeKeysOnly:
+			if len(index.NonKeyAttributes) > 0 {
+				return errors.New("The index " + index.Name + " can only have nonKeyAttributes with the INCLUDE projection")
+			}
+		case types.ProjectionTypeInclude:
+		default:
+			return errors.New("The index " + index.Name + " has projection " + index.Projection + "; it must be ALL, KEYS_ONLY, or INCLUDE")
+		}
+
+		if provisioned != (index.Throughput != nil) {
+			return errors.New("The index " + index.Name + " needs throughput if and only if the billingMode is PROVISIONED")
+		}
+	}
+
+	if s.TTL != nil && s.TTL.Attribute == "" {
+		return errors.New("The ttl needs an attribute")
+	}
+
+	if s.Stream != nil {
+		if s.Stream.ViewType == "" {
+			s.Stream.ViewType = string(types.StreamViewTypeNewAndOldImages)
+		}
+
+		switch types.StreamViewType(s.Stream.ViewType) {
+		case types.StreamViewTypeNewImage, types.StreamViewTypeOldImage, types.StreamViewTypeNewAndOldImages, types.StreamViewTypeKeysOnly:
+		default:
+			return errors.New("The stream viewType must be NEW_IMAGE, OLD_IMAGE, NEW_AND_OLD_IMAGES, or KEYS_ONLY, not " + s.Stream.ViewType)
+		}
 	}
 
 	return nil
 }
 
-func main() {
-	err := populateConfiguration()
+func keySchema(partitionKey Key, sortKey *Key) []types.KeySchemaElement {
+	elements := []types.KeySchemaElement{{
+		AttributeName: aws.String(partitionKey.Name),
+		KeyType:       types.KeyTypeHash,
+	}}
+
+	if sortKey != nil {
+		elements = append(elements, types.KeySchemaElement{
+			AttributeName: aws.String(sortKey.Name),
+			KeyType:       types.KeyTypeRange,
+		})
+	}
+
+	return elements
+}
+
+// attributeDefinitions returns the definitions of the keys of the table and the indexes;
+// DynamoDB wants exactly the attributes that are keys
+func attributeDefinitions(keys ...*Key) []types.AttributeDefinition {
+	defs := []types.AttributeDefinition{}
+	seen := map[string]bool{}
+
+	for _, k := range keys {
+		if k == nil || seen[k.Name] {
+			continue
+		}
+
+		seen[k.Name] = true
+
+		defs = append(defs, types.AttributeDefinition{
+			AttributeName: aws.String(k.Name),
+			AttributeType: types.ScalarAttributeType(k.Type),
+		})
+	}
+
+	return defs
+}
+
+func throughput(t *Throughput) *types.ProvisionedThroughput {
+	if t == nil {
+		return nil
+	}
+
+	return &types.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(t.Read),
+		WriteCapacityUnits: aws.Int64(t.Write),
+	}
+}
+
+func projection(index Index) *types.Projection {
+	p := &types.Projection{ProjectionType: types.ProjectionType(index.Projection)}
+
+	if len(index.NonKeyAttributes) > 0 {
+		p.NonKeyAttributes = index.NonKeyAttributes
+	}
+
+	return p
+}
+
+func streamSpecification(s *Stream) *types.StreamSpecification {
+	if s == nil || !s.Enabled {
+		return &types.StreamSpecification{StreamEnabled: aws.Bool(false)}
+	}
+
+	return &types.StreamSpecification{
+		StreamEnabled:  aws.Bool(true),
+		StreamViewType: types.StreamViewType(s.ViewType),
+	}
+}
+
+// createTable returns the input that creates the table with its indexes and stream
+func createTable(s *Schema) *dynamodb.CreateTableInput {
+	keys := []*Key{&s.PartitionKey, s.SortKey}
+
+	input := &dynamodb.CreateTableInput{
+		TableName:             aws.String(s.TableName),
+		KeySchema:             keySchema(s.PartitionKey, s.SortKey),
+		BillingMode:           types.BillingMode(s.BillingMode),
+		ProvisionedThroughput: throughput(s.Throughput),
+	}
+
+	for i := range s.GlobalSecondaryIndexes {
+		index := &s.GlobalSecondaryIndexes[i]
+		keys = append(keys, &index.PartitionKey, index.SortKey)
+
+		input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, types.GlobalSecondaryIndex{
+			IndexName:             aws.String(index.Name),
+			KeySchema:             keySchema(index.PartitionKey, index.SortKey),
+			Projection:            projection(*index),
+			ProvisionedThroughput: throughput(index.Throughput),
+		})
+	}
+
+	input.AttributeDefinitions = attributeDefinitions(keys...)
+
+	if s.Stream != nil && s.Stream.Enabled {
+		input.StreamSpecification = streamSpecification(s.Stream)
+	}
+
+	return input
+}
+
+func sameKeySchema(want, got []types.KeySchemaElement) bool {
+	if len(want) != len(got) {
+		return false
+	}
+
+	for i := range want {
+		if aws.ToString(want[i].AttributeName) != aws.ToString(got[i].AttributeName) || want[i].KeyType != got[i].KeyType {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sameProjection(want, got *types.Projection) bool {
+	if got == nil {
+		return false
+	}
+
+	if want.ProjectionType != got.ProjectionType {
+		return false
+	}
+
+	a := append([]string{}, want.NonKeyAttributes...)
+	b := append([]string{}, got.NonKeyAttributes...)
+	sort.Strings(a)
+	sort.Strings(b)
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sameThroughput(want *Throughput, got *types.ProvisionedThroughputDescription) bool {
+	if want == nil {
+		return true
+	}
+
+	if got == nil {
+		return false
+	}
+
+	return want.Read == aws.ToInt64(got.ReadCapacityUnits) && want.Write == aws.ToInt64(got.WriteCapacityUnits)
+}
+
+// attributeTypes returns the type of each attribute the table defines
+func attributeTypes(table *types.TableDescription) map[string]string {
+	attrs := map[string]string{}
+
+	for _, def := range table.AttributeDefinitions {
+		attrs[aws.ToString(def.AttributeName)] = string(def.AttributeType)
+	}
+
+	return attrs
+}
+
+func keyString(elements []types.KeySchemaElement, attrs map[string]string) string {
+	s := ""
+
+	for _, e := range elements {
+		if s != "" {
+			s += ", "
+		}
+
+		s += aws.ToString(e.AttributeName) + " " + attrs[aws.ToString(e.AttributeName)] + " " + string(e.KeyType)
+	}
+
+	return s
+}
+
+func indexKeyString(index Index) string {
+	if index.SortKey == nil {
+		return index.PartitionKey.Name
+	}
+
+	return index.PartitionKey.Name + " and " + index.SortKey.Name
+}
+
+// Plan returns the changes that make the table match the schema, in the order they must be made.
+// The table is nil if it doesn't exist.
+// DynamoDB only allows one index to be created or deleted in each UpdateTable call,
+// so each of those is its own change.
+func Plan(s *Schema, table *types.TableDescription, ttl *types.TimeToLiveDescription) ([]Change, error) {
+	changes := []Change{}
+
+	if table == nil {
+		changes = append(changes, Change{
+			Description: "Create table " + s.TableName + " (" + s.BillingMode + ") with " + strconv.Itoa(len(s.GlobalSecondaryIndexes)) + " global secondary indexes",
+			Create:      createTable(s),
+		})
+
+		if s.TTL != nil && s.TTL.Enabled {
+			changes = append(changes, ttlChange(s, true))
+		}
+
+		return changes, nil
+	}
+
+	attrs := attributeTypes(table)
+
+	// DynamoDB can't change the key of a table, only recreate it
+	sameKey := sameKeySchema(keySchema(s.PartitionKey, s.SortKey), table.KeySchema) && attrs[s.PartitionKey.Name] == s.PartitionKey.Type
+	if s.SortKey != nil && attrs[s.SortKey.Name] != s.SortKey.Type {
+		sameKey = false
+	}
+
+	if !sameKey {
+		return nil, errors.New("The key of " + s.TableName + " is " + keyString(table.KeySchema, attrs) + "; changing a table's key means recreating the table")
+	}
+
+	// A table that never changed its billing mode doesn't have a summary and is PROVISIONED
+	billingMode := string(types.BillingModeProvisioned)
+	if table.BillingModeSummary != nil {
+		billingMode = string(table.BillingModeSummary.BillingMode)
+	}
+
+	indexes := map[string]types.GlobalSecondaryIndexDescription{}
+	for _, index := range table.GlobalSecondaryIndexes {
+		indexes[aws.ToString(index.IndexName)] = index
+	}
+
+	wanted := map[string]Index{}
+	for _, index := range s.GlobalSecondaryIndexes {
+		wanted[index.Name] = index
+	}
+
+	// Indexes whose key or projection changed are deleted and created again
+	deletes := []string{}
+	creates := []Index{}
+	updates := []types.GlobalSecondaryIndexUpdate{}
+
+	for _, index := range table.GlobalSecondaryIndexes {
+		name := aws.ToString(index.IndexName)
+
+		w, ok := wanted[name]
+		if !ok || !sameKeySchema(keySchema(w.PartitionKey, w.SortKey), index.KeySchema) || !sameProjection(projection(w), index.Projection) {
+			deletes = append(deletes, name)
+		}
+	}
+
+	sort.Strings(deletes)
+
+	deleted := map[string]bool{}
+	for _, name := range deletes {
+		deleted[name] = true
+	}
+
+	for _, w := range s.GlobalSecondaryIndexes {
+		index, ok := indexes[w.Name]
+		if !ok || deleted[w.Name] {
+			creates = append(creates, w)
+			continue
+		}
+
+		// Switching to PROVISIONED sets the throughput of every index along with the table's
+		if w.Throughput != nil && (billingMode != s.BillingMode || !sameThroughput(w.Throughput, index.ProvisionedThroughput)) {
+			updates = append(updates, types.GlobalSecondaryIndexUpdate{
+				Update: &types.UpdateGlobalSecondaryIndexAction{
+					IndexName:             aws.String(w.Name),
+					ProvisionedThroughput: throughput(w.Throughput),
+				},
+			})
+		}
+	}
+
+	for _, name := range deletes {
+		changes = append(changes, Change{
+			Description: "Delete index " + name,
+			Update: &dynamodb.UpdateTableInput{
+				TableName: aws.String(s.TableName),
+				GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{
+					Delete: &types.DeleteGlobalSecondaryIndexAction{IndexName: aws.String(name)},
+				}},
+			},
+		})
+	}
+
+	if billingMode != s.BillingMode || !sameThroughput(s.Throughput, table.ProvisionedThroughput) || len(updates) > 0 {
+		description := "Change billing mode from " + billingMode + " to " + s.BillingMode
+		if billingMode == s.BillingMode {
+			description = "Change provisioned throughput"
+		}
+
+		changes = append(changes, Change{
+			Description: description,
+			Update: &dynamodb.UpdateTableInput{
+				TableName:                   aws.String(s.TableName),
+				BillingMode:                 types.BillingMode(s.BillingMode),
+				ProvisionedThroughput:       throughput(s.Throughput),
+				GlobalSecondaryIndexUpdates: updates,
+			},
+		})
+	}
+
+	if s.Stream != nil {
+		enabled := table.StreamSpecification != nil && aws.ToBool(table.StreamSpecification.StreamEnabled)
+
+		viewType := ""
+		if enabled {
+			viewType = string(table.StreamSpecification.StreamViewType)
+		}
+
+		// The view type of a stream can't change, so the stream is disabled and enabled again
+		if enabled && (!s.Stream.Enabled || viewType != s.Stream.ViewType) {
+			changes = append(changes, Change{
+				Description: "Disable the " + viewType + " stream",
+				Update: &dynamodb.UpdateTableInput{
+					TableName:           aws.String(s.TableName),
+					StreamSpecification: streamSpecification(nil),
+				},
+			})
+
+			enabled = false
+		}
+
+		if !enabled && s.Stream.Enabled {
+			changes = append(changes, Change{
+				Description: "Enable a " + s.Stream.ViewType + " stream",
+				Update: &dynamodb.UpdateTableInput{
+					TableName:           aws.String(s.TableName),
+					StreamSpecification: streamSpecification(s.Stream),
+				},
+			})
+		}
+	}
+
+	for i := range creates {
+		index := creates[i]
+
+		changes = append(changes, Change{
+			Description: "Create index " + index.Name + " on " + indexKeyString(index),
+			Update: &dynamodb.UpdateTableInput{
+				TableName:            aws.String(s.TableName),
+				AttributeDefinitions: attributeDefinitions(&index.PartitionKey, index.SortKey),
+				GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{
+					Create: &types.CreateGlobalSecondaryIndexAction{
+						IndexName:             aws.String(index.Name),
+						KeySchema:             keySchema(index.PartitionKey, index.SortKey),
+						Projection:            projection(index),
+						ProvisionedThroughput: throughput(index.Throughput),
+					},
+				}},
+			},
+		})
+	}
+
+	if s.TTL != nil {
+		enabled := false
+		attribute := ""
+
+		if ttl != nil && (ttl.TimeToLiveStatus == types.TimeToLiveStatusEnabled || ttl.TimeToLiveStatus == types.TimeToLiveStatusEnabling) {
+			enabled = true
+			attribute = aws.ToString(ttl.AttributeName)
+		}
+
+		// TTL has to be disabled before it can use a different attribute
+		if enabled && (!s.TTL.Enabled || attribute != s.TTL.Attribute) {
+			disable := ttlChange(s, false)
+			disable.TTL.TimeToLiveSpecification.AttributeName = aws.String(attribute)
+			disable.Description = "Disable TTL on " + attribute
+			changes = append(changes, disable)
+
+			enabled = false
+		}
+
+		if !enabled && s.TTL.Enabled {
+			changes = append(changes, ttlChange(s, true))
+		}
+	}
+
+	return changes, nil
+}
+
+func ttlChange(s *Schema, enabled bool) Change {
+	description := "Enable TTL on " + s.TTL.Attribute
+	if !enabled {
+		description = "Disable TTL on " + s.TTL.Attribute
+	}
+
+	return Change{
+		Description: description,
+		TTL: &dynamodb.UpdateTimeToLiveInput{
+			TableName: aws.String(s.TableName),
+			TimeToLiveSpecification: &types.TimeToLiveSpecification{
+				AttributeName: aws.String(s.TTL.Attribute),
+				Enabled:       aws.Bool(enabled),
+			},
+		},
+	}
+}
+
+// Describe returns the table and its TTL, or nil if the table doesn't exist
+func Describe(c context.Context, api DynamoDBTableAPI, name string) (*types.TableDescription, *types.TimeToLiveDescription, error) {
+	resp, err := api.DescribeTable(c, &dynamodb.DescribeTableInput{
+		TableName: aws.String(name),
+	})
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, nil, nil
+		}
+
+		return nil, nil, err
+	}
+
+	ttl, err := api.DescribeTimeToLive(c, &dynamodb.DescribeTimeToLiveInput{
+		TableName: aws.String(name),
+	})
 	if err != nil {
-		fmt.Println("Could not get values from " + configFileName)
+		return nil, nil, err
+	}
+
+	return resp.Table, ttl.TimeToLiveDescription, nil
+}
+
+// WaitForActive waits until the table and all of its indexes are ACTIVE,
+// since DynamoDB rejects an UpdateTable call while an earlier one is still in progress
+func WaitForActive(c context.Context, api DynamoDBTableAPI, name string) error {
+	for i := 0; i < maxPolls; i++ {
+		resp, err := api.DescribeTable(c, &dynamodb.DescribeTableInput{
+			TableName: aws.String(name),
+		})
+		if err != nil {
+			return err
+		}
+
+		active := resp.Table.TableStatus == types.TableStatusActive
+
+		for _, index := range resp.Table.GlobalSecondaryIndexes {
+			if index.IndexStatus != types.IndexStatusActive {
+				active = false
+			}
+		}
+
+		if active {
+			return nil
+		}
+
+		time.Sleep(pollInterval)
+	}
+
+	return errors.New("Timed out waiting for " + name + " to become ACTIVE")
+}
+
+// Apply makes the changes one at a time, waiting for the table to be ACTIVE after each
+func Apply(c context.Context, api DynamoDBTableAPI, changes []Change) error {
+	for _, change := range changes {
+		fmt.Println(change.Description)
+
+		var name *string
+		var err error
+
+		switch {
+		case change.Create != nil:
+			name = change.Create.TableName
+			_, err = api.CreateTable(c, change.Create)
+		case change.Update != nil:
+			name = change.Update.TableName
+			_, err = api.UpdateTable(c, change.Update)
+		case change.TTL != nil:
+			name = change.TTL.TableName
+			_, err = api.UpdateTimeToLive(c, change.TTL)
+		}
+
+		if err != nil {
+			return errors.New(change.Description + " failed: " + err.Error())
+		}
+
+		err = WaitForActive(c, api, aws.ToString(name))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func usage() {
+	fmt.Println("Usage: CreateEntityTable [-s SCHEMA] plan | apply")
+	fmt.Println("  plan  shows the changes that make the table match the schema")
+	fmt.Println("  apply makes them")
+}
+
+func main() {
+	schemaFile := flag.String("s", "table.yaml", "The YAML or JSON file describing the table")
+	flag.Parse()
+
+	command := flag.Arg(0)
+	if flag.NArg() != 1 || (command != "plan" && command != "apply") {
+		usage()
 		return
 	}
+
+	schema, err := LoadSchema(*schemaFile)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
 	// Using the SDK's default configuration, loading additional config
 	// and credentials values from the environment variables, shared
 	// credentials, and shared configuration files
@@ -58,54 +800,40 @@ func main() {
 		panic("unable to load SDK config, " + err.Error())
 	}
 
-	// Using the Config value, create the DynamoDB client
-	// Create a new DynamoDB Service Client
 	client := dynamodb.NewFromConfig(cfg)
 
-	// Create attribute definitions
-	var attrs []types.AttributeDefinition
-
-	partitionKeyAttr := types.AttributeDefinition{
-		AttributeName: &globalConfig.PartitionKey,
-		AttributeType: types.ScalarAttributeTypeS,
+	table, ttl, err := Describe(context.TODO(), client, schema.TableName)
+	if err != nil {
+		fmt.Println("Could not describe " + schema.TableName + ": " + err.Error())
+		os.Exit(1)
 	}
 
-	sortKeyAttr := types.AttributeDefinition{
-		AttributeName: &globalConfig.SortKey,
-		AttributeType: types.ScalarAttributeTypeS,
+	changes, err := Plan(schema, table, ttl)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
 	}
 
-	attrs = append(attrs, partitionKeyAttr)
-	attrs = append(attrs, sortKeyAttr)
-
-	// Create key schema elements
-	var keySchemaElements []types.KeySchemaElement
-
-	partitionKeyElement := types.KeySchemaElement{
-		AttributeName: &globalConfig.PartitionKey,
-		KeyType:       "HASH",
+	if len(changes) == 0 {
+		fmt.Println(schema.TableName + " matches " + *schemaFile)
+		return
 	}
 
-	sortKeyElement := types.KeySchemaElement{
-		AttributeName: &globalConfig.SortKey,
-		KeyType:       "RANGE",
-	}
+	if command == "plan" {
+		fmt.Println(strconv.Itoa(len(changes)) + " changes to make " + schema.TableName + " match " + *schemaFile + ":")
 
-	keySchemaElements = append(keySchemaElements, partitionKeyElement)
-	keySchemaElements = append(keySchemaElements, sortKeyElement)
+		for _, change := range changes {
+			fmt.Println("  " + change.Description)
+		}
 
-	// Create table
-	input := &dynamodb.CreateTableInput{
-		TableName:            &globalConfig.Table,
-		AttributeDefinitions: attrs,
-		KeySchema:            keySchemaElements,
-		BillingMode:          types.BillingModePayPerRequest,
+		return
 	}
 
-	_, err = client.CreateTable(context.TODO(), input)
+	err = Apply(context.TODO(), client, changes)
 	if err != nil {
-		panic("failed to describe table, " + err.Error())
+		fmt.Println(err.Error())
+		os.Exit(1)
 	}
 
-	fmt.Println("Created " + globalConfig.Table + " with partition key " + globalConfig.PartitionKey + " and sort key " + globalConfig.SortKey)
+	fmt.Println("Made " + strconv.Itoa(len(changes)) + " changes to " + schema.TableName)
 }
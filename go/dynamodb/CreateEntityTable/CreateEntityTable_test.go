@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// mockDynamoDBClient records the calls it gets.
+// The table doesn't exist until it's created, and is ACTIVE on every other DescribeTable call.
+type mockDynamoDBClient struct {
+	table *types.TableDescription
+	polls int
+	calls []string
+}
+
+func (m *mockDynamoDBClient) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	if m.table == nil {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("Requested resource not found")}
+	}
+
+	m.polls++
+
+	status := types.TableStatusUpdating
+	if m.polls%2 == 0 {
+		status = types.TableStatusActive
+	}
+
+	table := *m.table
+	table.TableStatus = status
+
+	return &dynamodb.DescribeTableOutput{Table: &table}, nil
+}
+
+func (m *mockDynamoDBClient) CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	m.calls = append(m.calls, "CreateTable")
+	m.table = &types.TableDescription{TableName: params.TableName}
+
+	return &dynamodb.CreateTableOutput{}, nil
+}
+
+func (m *mockDynamoDBClient) UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error) {
+	m.calls = append(m.calls, "UpdateTable")
+	return &dynamodb.UpdateTableOutput{}, nil
+}
+
+func (m *mockDynamoDBClient) DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	return &dynamodb.DescribeTimeToLiveOutput{
+		TimeToLiveDescription: &types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusDisabled},
+	}, nil
+}
+
+func (m *mockDynamoDBClient) UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	m.calls = append(m.calls, "UpdateTimeToLive")
+	return &dynamodb.UpdateTimeToLiveOutput{}, nil
+}
+
+func loadSchema(t *testing.T, name, contents string) (*Schema, error) {
+	dir, err := ioutil.TempDir("", "CreateEntityTable")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, name)
+
+	err = ioutil.WriteFile(path, []byte(contents), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return LoadSchema(path)
+}
+
+// entityTable is CodeExamplesEntries as DynamoDB describes it
+func entityTable() *types.TableDescription {
+	return &types.TableDescription{
+		TableName: aws.String("CodeExamplesEntries"),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("path"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("action"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("sdk"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("path"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("action"), KeyType: types.KeyTypeRange},
+		},
+		BillingModeSummary: &types.BillingModeSummary{BillingMode: types.BillingModePayPerRequest},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndexDescription{{
+			IndexName:   aws.String("sdk-index"),
+			IndexStatus: types.IndexStatusActive,
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("sdk"), KeyType: types.KeyTypeHash},
+			},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeKeysOnly},
+		}},
+		StreamSpecification: &types.StreamSpecification{
+			StreamEnabled:  aws.Bool(true),
+			StreamViewType: types.StreamViewTypeKeysOnly,
+		},
+	}
+}
+
+const entitySchema = `tableName: CodeExamplesEntries
+partitionKey:
+  name: path
+sortKey:
+  name: action
+globalSecondaryIndexes:
+  - name: service-index
+    partitionKey:
+      name: service
+    sortKey:
+      name: action
+ttl:
+  attribute: expires
+  enabled: true
+stream:
+  enabled: true
+`
+
+func TestLoadSchema(t *testing.T) {
+	schema, err := loadSchema(t, "table.yaml", entitySchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if schema.BillingMode != "PAY_PER_REQUEST" || schema.PartitionKey.Type != "S" {
+		t.Errorf("Expected the billing mode and key type defaults, got %s and %s", schema.BillingMode, schema.PartitionKey.Type)
+	}
+
+	if schema.GlobalSecondaryIndexes[0].Projection != "ALL" || schema.Stream.ViewType != "NEW_AND_OLD_IMAGES" {
+		t.Error("Expected the projection and stream view type defaults")
+	}
+
+	_, err = loadSchema(t, "table.json", `{"tableName": "t", "partitionKey": {"name": "path"}, "billingMode": "PROVISIONED"}`)
+	if err == nil {
+		t.Error("Expected an error for a PROVISIONED table without throughput")
+	}
+
+	_, err = loadSchema(t, "table.json", `{"tableName": "t", "partitionKey": {"name": "path"}, "rangeKey": {"name": "action"}}`)
+	if err == nil {
+		t.Error("Expected an error for an unknown field")
+	}
+
+	_, err = loadSchema(t, "table.yaml", "tableName: t\npartitionKey:\n  name: path\nsortKey:\n  name: path\n  type: N\n")
+	if err == nil {
+		t.Error("Expected an error for a key with two types")
+	}
+}
+
+func TestPlanCreate(t *testing.T) {
+	schema, err := loadSchema(t, "table.yaml", entitySchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := Plan(schema, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 2 || changes[0].Create == nil || changes[1].TTL == nil {
+		t.Fatalf("Expected CreateTable and UpdateTimeToLive, got %v", changes)
+	}
+
+	in := changes[0].Create
+
+	if len(in.AttributeDefinitions) != 3 {
+		t.Errorf("Expected path, action, and service to be defined once each, got %d definitions", len(in.AttributeDefinitions))
+	}
+
+	if len(in.GlobalSecondaryIndexes) != 1 || in.StreamSpecification == nil || in.StreamSpecification.StreamViewType != types.StreamViewTypeNewAndOldImages {
+		t.Error("Expected the table to be created with its index and stream")
+	}
+
+	client := &mockDynamoDBClient{}
+	pollInterval = 0
+
+	err = Apply(context.Background(), client, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(client.calls) != 2 || client.calls[0] != "CreateTable" || client.calls[1] != "UpdateTimeToLive" {
+		t.Errorf("Got calls %v", client.calls)
+	}
+}
+
+func TestPlanUpdate(t *testing.T) {
+	schema, err := loadSchema(t, "table.yaml", entitySchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := Plan(schema, entityTable(), &types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusDisabled})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"Delete index sdk-index",
+		"Disable the KEYS_ONLY stream",
+		"Enable a NEW_AND_OLD_IMAGES stream",
+		"Create index service-index on service and action",
+		"Enable TTL on expires",
+	}
+
+	if len(changes) != len(want) {
+		t.Fatalf("Got %d changes, want %d: %v", len(changes), len(want), changes)
+	}
+
+	for i := range want {
+		if changes[i].Description != want[i] {
+			t.Errorf("Change %d is %q, want %q", i, changes[i].Description, want[i])
+		}
+	}
+
+	create := changes[3].Update
+	if len(create.GlobalSecondaryIndexUpdates) != 1 || len(create.AttributeDefinitions) != 2 {
+		t.Error("Expected one index created with the definitions of its keys")
+	}
+
+	// Once it's done, there's nothing left to do
+	table := entityTable()
+	table.GlobalSecondaryIndexes = []types.GlobalSecondaryIndexDescription{{
+		IndexName: aws.String("service-index"),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("service"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("action"), KeyType: types.KeyTypeRange},
+		},
+		Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+	}}
+	table.StreamSpecification.StreamViewType = types.StreamViewTypeNewAndOldImages
+
+	changes, err = Plan(schema, table, &types.TimeToLiveDescription{
+		TimeToLiveStatus: types.TimeToLiveStatusEnabled,
+		AttributeName:    aws.String("expires"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 0 {
+		t.Errorf("Expected no changes, got %v", changes)
+	}
+}
+
+func TestPlanBillingMode(t *testing.T) {
+	schema, err := loadSchema(t, "table.yaml", `tableName: CodeExamplesEntries
+billingMode: PROVISIONED
+throughput:
+  read: 5
+  write: 5
+partitionKey:
+  name: path
+sortKey:
+  name: action
+globalSecondaryIndexes:
+  - name: sdk-index
+    partitionKey:
+      name: sdk
+    projection: KEYS_ONLY
+    throughput:
+      read: 1
+      write: 1
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := Plan(schema, entityTable(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 1 {
+		t.Fatalf("Expected only the billing mode to change, got %v", changes)
+	}
+
+	in := changes[0].Update
+
+	if in.BillingMode != types.BillingModeProvisioned || aws.ToInt64(in.ProvisionedThroughput.ReadCapacityUnits) != 5 {
+		t.Error("Expected the table to switch to 5 read and write units")
+	}
+
+	if len(in.GlobalSecondaryIndexUpdates) != 1 || in.GlobalSecondaryIndexUpdates[0].Update == nil {
+		t.Error("Expected the throughput of the index to be set in the same call")
+	}
+}
+
+func TestPlanKeyChange(t *testing.T) {
+	schema, err := loadSchema(t, "table.yaml", "tableName: CodeExamplesEntries\npartitionKey:\n  name: path\nsortKey:\n  name: sdk\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Plan(schema, entityTable(), nil)
+	if err == nil {
+		t.Error("Expected an error changing the sort key")
+	}
+}
@@ -3,7 +3,8 @@ module mymain
 go 1.15
 
 require (
-	//github.com/aws/aws-sdk-go-v2 v1.2.0 // indirect
+	github.com/aws/aws-sdk-go-v2 v1.2.0
 	github.com/aws/aws-sdk-go-v2/config v1.1.1
 	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.1.1
+	gopkg.in/yaml.v2 v2.2.8
 )
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/Doug-AWS/code-examples/go/dynamodb/entity"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Config holds the info in config.json
+type Config struct {
+	Table string `json:"TableName"`
+}
+
+var configFileName = "config.json"
+
+var globalConfig Config
+
+func populateConfiguration() error {
+	content, err := ioutil.ReadFile(configFileName)
+	if err != nil {
+		return err
+	}
+
+	text := string(content)
+
+	err = json.Unmarshal([]byte(text), &globalConfig)
+	if err != nil {
+		return err
+	}
+
+	if globalConfig.Table == "" {
+		msg := "You musts supply a value for TableName " + configFileName
+		return errors.New(msg)
+	}
+
+	return nil
+}
+
+// DynamoDBScanAPI defines the interface for the Scan function
+type DynamoDBScanAPI interface {
+	Scan(ctx context.Context,
+		params *dynamodb.ScanInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+// newEntity returns the entity in item.
+// An item with any other attribute, or one that isn't a string, is an error
+// rather than being dropped from the export.
+func newEntity(item map[string]types.AttributeValue) (entity.Entity, error) {
+	values := map[string]string{}
+	names := []string{}
+
+	for name, value := range item {
+		s, ok := value.(*types.AttributeValueMemberS)
+		if !ok {
+			names = append(names, name+" (not a string)")
+			continue
+		}
+
+		values[name] = s.Value
+		names = append(names, name)
+	}
+
+	e := entity.Entity{
+		Path:        values["path"],
+		Action:      values["action"],
+		Sdk:         values["sdk"],
+		Service:     values["service"],
+		Target:      values["target"],
+		Description: values["description"],
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		_, ok := values[name]
+		if !ok || !isColumn(name) {
+			return e, errors.New("The item with path " + e.Path + " and action " + e.Action + " has the attribute " + name + ", which an entity can't hold")
+		}
+	}
+
+	return e, nil
+}
+
+func isColumn(name string) bool {
+	for _, c := range entity.Columns {
+		if name == c {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ScanEntities reads every item in the table, following LastEvaluatedKey,
+// and returns them sorted by path, action, SDK, and target so the output is the same every time
+func ScanEntities(c context.Context, api DynamoDBScanAPI, table string) ([]entity.Entity, error) {
+	entities := []entity.Entity{}
+
+	input := &dynamodb.ScanInput{
+		TableName: &table,
+	}
+
+	for {
+		resp, err := api.Scan(c, input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range resp.Items {
+			e, err := newEntity(item)
+			if err != nil {
+				return nil, err
+			}
+
+			entities = append(entities, e)
+		}
+
+		if len(resp.LastEvaluatedKey) == 0 {
+			break
+		}
+
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+
+	sort.Slice(entities, func(i, j int) bool {
+		a, b := entities[i], entities[j]
+
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+
+		if a.Action != b.Action {
+			return a.Action < b.Action
+		}
+
+		if a.Sdk != b.Sdk {
+			return a.Sdk < b.Sdk
+		}
+
+		return a.Target < b.Target
+	})
+
+	return entities, nil
+}
+
+// formatFor returns the format to use: the one given, or the one the output file's extension implies
+func formatFor(format, output string) (string, error) {
+	if format == "" {
+		switch filepath.Ext(output) {
+		case ".jsonl":
+			format = "jsonl"
+		case ".yaml", ".yml":
+			format = "yaml"
+		default:
+			format = "csv"
+		}
+	}
+
+	switch format {
+	case "csv", "jsonl", "yaml":
+		return format, nil
+	}
+
+	return "", errors.New("The format must be csv, jsonl, or yaml, not " + format)
+}
+
+func main() {
+	format := flag.String("f", "", "The format to write: csv, jsonl, or yaml; by default it comes from the extension of -o, or is csv")
+	output := flag.String("o", "", "The file to write the entities to; by default they're written to stdout")
+	delimiter := flag.String("D", "|", "The character that separates the columns of a CSV file; use tab for a TSV file")
+	flag.Parse()
+
+	f, err := formatFor(*format, *output)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	delim, err := entity.ParseDelimiter(*delimiter)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	err = populateConfiguration()
+	if err != nil {
+		fmt.Println("Could not parse " + configFileName)
+		return
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		panic("configuration error, " + err.Error())
+	}
+
+	entities, err := ScanEntities(context.TODO(), dynamodb.NewFromConfig(cfg), globalConfig.Table)
+	if err != nil {
+		fmt.Println("Got an error scanning " + globalConfig.Table + ":")
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	var w io.Writer = os.Stdout
+
+	// Write to a temporary file and rename it, so a failed export doesn't leave half a file
+	var tmp *os.File
+
+	if *output != "" {
+		tmp, err = ioutil.TempFile(filepath.Dir(*output), filepath.Base(*output)+".*")
+		if err != nil {
+			fmt.Println("Could not create " + *output + ": " + err.Error())
+			os.Exit(1)
+		}
+
+		w = tmp
+	}
+
+	switch f {
+	case "csv":
+		err = entity.WriteCSV(w, entities, delim)
+	case "jsonl":
+		err = entity.WriteJSONLines(w, entities)
+	case "yaml":
+		err = entity.WriteYAML(w, entities)
+	}
+
+	if err == nil && tmp != nil {
+		err = tmp.Close()
+		if err == nil {
+			err = os.Rename(tmp.Name(), *output)
+		}
+	}
+
+	if err != nil {
+		// os.Exit doesn't run deferred calls, so remove the temporary file here
+		if tmp != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+
+		fmt.Println("Could not write the entities: " + err.Error())
+		os.Exit(1)
+	}
+
+	if *output != "" {
+		fmt.Println("Exported " + strconv.Itoa(len(entities)) + " entities from " + globalConfig.Table + " to " + *output)
+	}
+}
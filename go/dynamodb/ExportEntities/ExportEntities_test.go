@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Doug-AWS/code-examples/go/dynamodb/entity"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// mockScanClient returns its pages of items one at a time
+type mockScanClient struct {
+	pages [][]map[string]types.AttributeValue
+}
+
+func (m *mockScanClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	page := 0
+	if params.ExclusiveStartKey != nil {
+		page = len(params.ExclusiveStartKey["page"].(*types.AttributeValueMemberS).Value)
+	}
+
+	resp := &dynamodb.ScanOutput{Items: m.pages[page]}
+
+	if page+1 < len(m.pages) {
+		next := ""
+		for i := 0; i <= page; i++ {
+			next += "x"
+		}
+
+		resp.LastEvaluatedKey = map[string]types.AttributeValue{"page": &types.AttributeValueMemberS{Value: next}}
+	}
+
+	return resp, nil
+}
+
+func item(path, action, service, description string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"path":        &types.AttributeValueMemberS{Value: path},
+		"action":      &types.AttributeValueMemberS{Value: action},
+		"sdk":         &types.AttributeValueMemberS{Value: "go"},
+		"service":     &types.AttributeValueMemberS{Value: service},
+		"target":      &types.AttributeValueMemberS{Value: "guide"},
+		"description": &types.AttributeValueMemberS{Value: description},
+	}
+}
+
+func scanEntities(t *testing.T) []entity.Entity {
+	client := &mockScanClient{pages: [][]map[string]types.AttributeValue{
+		{
+			item("https://example.com/s3/copyobject", "CopyObject", "s3", `Copies an "object" | to a bucket`),
+			item("https://example.com/sns", "section", "sns", "SNS"),
+		},
+		{
+			item("https://example.com/s3/copyobject", "CreateBucket", "s3", `Copies an "object" | to a bucket`),
+			item("https://example.com/s3/copyobject", "GetCallerIdentity", "sts", `Copies an "object" | to a bucket`),
+		},
+	}}
+
+	entities, err := ScanEntities(context.Background(), client, "CodeExamplesEntries")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return entities
+}
+
+func TestScanEntities(t *testing.T) {
+	entities := scanEntities(t)
+
+	if len(entities) != 4 {
+		t.Fatalf("Expected the entities from both pages, got %v", entities)
+	}
+
+	want := []string{"CopyObject", "CreateBucket", "GetCallerIdentity", "section"}
+
+	for i := range want {
+		if entities[i].Action != want[i] {
+			t.Errorf("Expected entity %d to be %s, got %s", i, want[i], entities[i].Action)
+		}
+	}
+
+	bad := item("https://example.com/sns", "section", "sns", "SNS")
+	bad["expires"] = &types.AttributeValueMemberN{Value: "1640995200"}
+
+	_, err := ScanEntities(context.Background(), &mockScanClient{pages: [][]map[string]types.AttributeValue{{bad}}}, "CodeExamplesEntries")
+	if err == nil {
+		t.Error("Expected an error for an attribute the export can't hold")
+	}
+}
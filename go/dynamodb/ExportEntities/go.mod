@@ -0,0 +1,11 @@
+module mymain
+
+go 1.15
+
+require (
+	github.com/Doug-AWS/code-examples/go/dynamodb/entity v0.0.0
+	github.com/aws/aws-sdk-go-v2/config v1.1.1
+	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.1.1
+)
+
+replace github.com/Doug-AWS/code-examples/go/dynamodb/entity => ../entity
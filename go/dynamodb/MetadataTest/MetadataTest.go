@@ -3,28 +3,12 @@ package main
 import (
 	"fmt"
 
-	"gopkg.in/yaml.v2"
+	"github.com/Doug-AWS/code-examples/go/utilities/metadata"
 )
 
-type Service struct {
-	Service string   `yaml:"service"`
-	Actions []string `yaml:"actions"`
-}
-
-type File struct {
-	Description string    `yaml:"description"`
-	Path        string    `yaml:"path"`
-	Services    []Service `yaml:"services"`
-}
-
-// Metadata caches the info in metadata.yaml
-type Metadata struct {
-	Files []File `yaml:"files"`
-}
-
 func main() {
 	// Create some metadata
-	svc := Service{
+	svc := metadata.Service{
 		Service: "service1",
 		Actions: []string{
 			"action1",
@@ -32,24 +16,24 @@ func main() {
 		},
 	}
 
-	file := File{
+	file := metadata.File{
 		Description: "Blah, blah, blah",
 		Path:        "c:/",
-		Services: []Service{
+		Services: []metadata.Service{
 			svc,
 		},
 	}
 
 	// Now marshall it into YAML
-	metadata := Metadata{
-		Files: []File{
+	meta := metadata.Metadata{
+		Files: []metadata.File{
 			file,
 		},
 	}
 
-	result, err := yaml.Marshal(metadata)
+	result, err := meta.Marshal()
 	if err != nil {
-		fmt.Println("Got error unmarshalling YAML: " + err.Error())
+		fmt.Println("Got error marshalling YAML: " + err.Error())
 	} else {
 		fmt.Println(string(result))
 	}
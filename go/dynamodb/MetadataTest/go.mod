@@ -2,6 +2,6 @@ module mymain
 
 go 1.15
 
-require (
-	gopkg.in/yaml.v2 v2.2.8
-)
+require github.com/Doug-AWS/code-examples/go/utilities/metadata v0.0.0
+
+replace github.com/Doug-AWS/code-examples/go/utilities/metadata => ../../utilities/metadata
@@ -3,35 +3,44 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
+	"github.com/Doug-AWS/code-examples/go/dynamodb/batch"
+	"github.com/Doug-AWS/code-examples/go/dynamodb/entity"
+	"github.com/Doug-AWS/code-examples/go/dynamodb/registry"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"gopkg.in/yaml.v2"
 )
 
 // Config stores the values from config.json
 type Config struct {
-	Table        string   `json:"TableName"`
-	PartitionKey string   `json:"PartitionKeyName"`
-	SortKey      string   `json:"SortKeyName"`
-	Services     []string `json:"ServiceNames"` // To validate the -s option
-	Sdks         []string `json:"SdkNames"`     // To validate the -k option
-	Targets      []string `json:"TargetNames"`  // To validate the -t option
-	Description  string   `json:"Description"`
+	Table        string            `json:"TableName"`
+	PartitionKey string            `json:"PartitionKeyName"`
+	SortKey      string            `json:"SortKeyName"`
+	Targets      []string          `json:"TargetNames"` // To validate the -t option
+	Description  string            `json:"Description"`
+	Rules        []entity.PathRule `json:"PathRules"` // To validate the paths
 }
 
 var configFileName = "config.json"
 
 var globalConfig Config
 
+// globalRegistry is the services and SDKs an entity can have
+var globalRegistry *registry.Registry
+
 func populateConfiguration() error {
 	content, err := ioutil.ReadFile(configFileName)
 	if err != nil {
@@ -53,45 +62,6 @@ func populateConfiguration() error {
 	return nil
 }
 
-// ValidAwsGuidePrefix defines the valid prefix for path when target is "guide" FOR MOST GUIDES
-const ValidAwsGuidePrefix = "https://docs.aws.amazon.com"
-
-// ValidGoGuidePrefix defines the valid prefix for the go dev guide
-const ValidGoGuidePrefix = "https://aws.github.io/aws-sdk-go-v2/docs/code-examples/"
-
-func isPathValid(path string, sdk string, target string) error {
-	// If target is "guide"
-	// path must start with https://docs.aws.amazon.com
-	msg := ""
-
-	switch sdk {
-	case "go":
-		switch target {
-		case "guide":
-			valid := strings.HasPrefix(path, ValidGoGuidePrefix)
-			if valid {
-				return nil
-			}
-		}
-	default:
-		switch target {
-		case "guide":
-			valid := strings.HasPrefix(path, ValidAwsGuidePrefix)
-			if valid {
-				return nil
-			}
-
-			msg = "Path does not start with " + ValidAwsGuidePrefix
-			return errors.New(msg)
-
-		default:
-			msg = "Valid path prefix is not set for " + target + " target"
-		}
-	}
-
-	return errors.New(msg)
-}
-
 func isValidItem(item string, list []string) bool {
 	// Is service in list of services?
 	for _, l := range list {
@@ -109,160 +79,317 @@ func debugPrint(debug bool, s string) {
 	}
 }
 
-func addItemsToTable(debug bool, filename string) (int, error) {
-	file, err := os.Open(filename)
+// RowError describes a row that was not imported and why.
+// In a YAML file, Line is the number of the entry in files instead,
+// since the YAML parser doesn't say which line an entry is on.
+type RowError struct {
+	Line   int
+	Reason string
+	Record []string
+}
+
+// validateEntity checks the service and SDK against the registry and the target against config.json
+func validateEntity(e entity.Entity) error {
+	if e.Path == "" || e.Action == "" {
+		return errors.New("path and action must not be empty")
+	}
+
+	if !globalRegistry.IsService(e.Service) {
+		return errors.New(e.Service + " is not in the list of services")
+	}
+
+	if !globalRegistry.IsSDK(e.Sdk) {
+		return errors.New(e.Sdk + " is not in the list of SDKs")
+	}
+
+	if !isValidItem(e.Target, globalConfig.Targets) {
+		return errors.New(e.Target + " is not in the list of targets")
+	}
+
+	/*
+		We overload action.
+		If it's "section", we have a link to
+		something like the SNS code examples section in the Java Dev guide.
+		Otherwise, it's an individual topic for the *action operation.
+	*/
+
+	err := entity.CheckPath(globalConfig.Rules, e.Path, e.Sdk, e.Target)
 	if err != nil {
-		msg := "Got an error opening " + filename
-		return 0, errors.New(msg)
+		return errors.New(e.Path + " is not a valid path for target " + e.Target + ": " + err.Error())
 	}
 
-	// Create DynamoDB client
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+	return nil
+}
+
+// collector keeps the valid entities and the rows that aren't
+type collector struct {
+	// What a row is called in the file: line or entry
+	unit      string
+	entities  []entity.Entity
+	rowErrors []RowError
+	// The row each path and action was first seen on
+	seen map[string]int
+}
+
+func newCollector(unit string) *collector {
+	return &collector{unit: unit, entities: []entity.Entity{}, rowErrors: []RowError{}, seen: map[string]int{}}
+}
+
+func (c *collector) fail(line int, reason string, record []string) {
+	c.rowErrors = append(c.rowErrors, RowError{Line: line, Reason: reason, Record: record})
+}
+
+// add adds the entity if it's valid and its path and action haven't been seen yet
+func (c *collector) add(line int, e entity.Entity, record []string) {
+	err := validateEntity(e)
 	if err != nil {
-		msg := "Got the configuration error: " + err.Error()
-		return 0, errors.New(msg)
+		c.fail(line, err.Error(), record)
+		return
 	}
 
-	client := dynamodb.NewFromConfig(cfg)
+	// The path and action are the table's key, so a second row would overwrite the first
+	key := e.Path + "\x00" + e.Action
 
-	scanner := bufio.NewScanner(file)
+	firstLine, ok := c.seen[key]
+	if ok {
+		c.fail(line, "has the same path and action as "+c.unit+" "+strconv.Itoa(firstLine), record)
+		return
+	}
 
-	foundFirstLine := false
+	c.seen[key] = line
 
-	i := 1
-	numItems := 0
+	c.entities = append(c.entities, e)
+}
 
-	for scanner.Scan() {
-		text := scanner.Text()
-		debugPrint(debug, "Line "+strconv.Itoa(i)+": "+text)
-		i++
-
-		// text should contain CSV strings like:
-		//   "https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns/","section","go","sns","guide","This code ..."
-		// so split it by commas, make sure there are 6 parts.
-		parts := strings.Split(text, "|")
-
-		if len(parts) != 6 {
-			fmt.Println(text)
-			fmt.Println("Did not have 6 components")
-			return 0, errors.New("Invalid entry")
+// ReadEntities parses the delimited file in r.
+// The first row is a header naming the columns.
+// Rows that can't be parsed or aren't valid are returned as RowErrors
+// instead of stopping the import; only a bad header is an error.
+func ReadEntities(r io.Reader, delimiter rune) ([]entity.Entity, []RowError, error) {
+	// Skip the byte order mark some spreadsheets write at the start of the file
+	br := bufio.NewReader(r)
+
+	first, _, err := br.ReadRune()
+	if err == nil && first != '\ufeff' {
+		br.UnreadRune()
+	}
+
+	reader := csv.NewReader(br)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil, errors.New("The file is empty")
 		}
 
-		// Each part is enclosed by double-quote (") marks.
-
-		path := parts[0][1 : len(parts[0])-1]
-		action := parts[1][1 : len(parts[1])-1]
-		sdk := parts[2][1 : len(parts[2])-1]
-		service := parts[3][1 : len(parts[3])-1]
-		target := parts[4][1 : len(parts[4])-1]
-		description := parts[5][1 : len(parts[5])-1]
-
-		if !foundFirstLine {
-			if path == "path" {
-				// We have the first item
-				// so make sure the schema of the CSV file is correct
-				if action != "action" || sdk != "sdk" || service != "service" || target != "target" || description != "description" {
-					msg := "The CSV file does not have the correct schema: path, action, sdk, service, target, description"
-					return 0, errors.New(msg)
-				}
+		return nil, nil, errors.New("Could not read the header: " + err.Error())
+	}
+
+	// The header maps each column name to its position
+	index := map[string]int{}
 
-				debugPrint(debug, "CSV file has the correct schema")
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
 
-				// Skip first line
-				foundFirstLine = true
+	// The columns can be in any order and other columns are ignored
+	for _, c := range entity.Columns {
+		_, ok := index[c]
+		if !ok {
+			return nil, nil, errors.New("The header does not have a " + c + " column; it needs " + strings.Join(entity.Columns, ", "))
+		}
+	}
+
+	c := newCollector("line")
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				c.fail(parseErr.StartLine, parseErr.Err.Error(), record)
 				continue
 			}
-		} else {
-			debugPrint(debug, "")
-			debugPrint(debug, "Description: "+description)
-			debugPrint(debug, "Path:        "+path)
-			debugPrint(debug, "Service:     "+service)
-			debugPrint(debug, "SDK:         "+sdk)
-			debugPrint(debug, "Target:      "+target)
-			debugPrint(debug, "Action:      "+action)
-			debugPrint(debug, "")
-
-			isValid := isValidItem(service, globalConfig.Services)
-			if !isValid {
-				msg := service + " is not in the list of services:"
-				return 0, errors.New(msg)
-			}
 
-			isValid = isValidItem(sdk, globalConfig.Sdks)
-			if !isValid {
-				msg := sdk + " is not in the list of SDKs:"
-				return 0, errors.New(msg)
-			}
+			return nil, nil, err
+		}
 
-			isValid = isValidItem(target, globalConfig.Targets)
-			if !isValid {
-				msg := target + " is not in the list of targets"
-				return 0, errors.New(msg)
-			}
+		line, _ := reader.FieldPos(0)
 
-			/*
-				We overload action.
-				If it's "section", we have a link to
-				something like the SNS code examples section in the Java Dev guide.
-				Otherwise, it's an individual topic for the *action operation.
-			*/
-
-			err = isPathValid(path, sdk, target)
-			if err != nil {
-				msg := path + " is not a valid path for target " + target
-				return 0, errors.New(msg)
-			}
+		if len(record) != len(header) {
+			c.fail(line, "has "+strconv.Itoa(len(record))+" columns instead of "+strconv.Itoa(len(header)), record)
+			continue
+		}
 
-			attrs := make(map[string]types.AttributeValue, 6)
+		// The values are kept as they are, so an exported table imports without changes
+		get := func(column string) string {
+			return record[index[column]]
+		}
 
-			attrs["path"] = &types.AttributeValueMemberS{
-				Value: path,
-			}
+		c.add(line, entity.Entity{
+			Path:        get("path"),
+			Action:      get("action"),
+			Sdk:         get("sdk"),
+			Service:     get("service"),
+			Target:      get("target"),
+			Description: get("description"),
+		}, record)
+	}
 
-			attrs["action"] = &types.AttributeValueMemberS{
-				Value: action,
-			}
+	return c.entities, c.rowErrors, nil
+}
 
-			attrs["sdk"] = &types.AttributeValueMemberS{
-				Value: sdk,
-			}
+// ReadEntitiesJSONLines parses a file with a JSON object for each entity on its own line.
+// Blank lines are skipped; a line that isn't an entity is returned as a RowError.
+func ReadEntitiesJSONLines(r io.Reader) ([]entity.Entity, []RowError, error) {
+	c := newCollector("line")
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
 
-			attrs["service"] = &types.AttributeValueMemberS{
-				Value: service,
-			}
+	line := 0
 
-			attrs["target"] = &types.AttributeValueMemberS{
-				Value: target,
-			}
+	for scanner.Scan() {
+		line++
 
-			attrs["description"] = &types.AttributeValueMemberS{
-				Value: description,
-			}
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
 
-			dynamodbInput := &dynamodb.PutItemInput{
-				TableName: &globalConfig.Table,
-				Item:      attrs,
-			}
+		var e entity.Entity
+
+		d := json.NewDecoder(strings.NewReader(text))
+		d.DisallowUnknownFields()
+
+		err := d.Decode(&e)
+		if err != nil {
+			c.fail(line, err.Error(), []string{text})
+			continue
+		}
+
+		c.add(line, e, e.Record())
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, nil, err
+	}
 
-			_, err = client.PutItem(context.TODO(), dynamodbInput)
-			if err != nil {
-				msg := "Got error calling PutItem: " + err.Error()
-				return 0, errors.New(msg)
+	return c.entities, c.rowErrors, nil
+}
+
+// ReadEntitiesYAML parses a file in the shape of metadata.yaml with the SDK and target of each entry
+func ReadEntitiesYAML(r io.Reader) ([]entity.Entity, []RowError, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var metadata entity.Metadata
+
+	err = yaml.UnmarshalStrict(b, &metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := newCollector("entry")
+
+	for i, f := range metadata.Files {
+		for _, s := range f.Services {
+			for _, a := range s.Actions {
+				e := entity.Entity{
+					Path:        f.Path,
+					Action:      a,
+					Sdk:         f.Sdk,
+					Service:     s.Service,
+					Target:      f.Target,
+					Description: f.Description,
+				}
+
+				c.add(i+1, e, e.Record())
 			}
+		}
+	}
+
+	return c.entities, c.rowErrors, nil
+}
+
+// WriteErrorReport writes the rows that weren't imported to w as CSV,
+// with the line number and reason before the original columns
+func WriteErrorReport(w io.Writer, rowErrors []RowError) error {
+	writer := csv.NewWriter(w)
 
-			debugPrint(debug, "Added item to table")
+	err := writer.Write([]string{"line", "error", "record"})
+	if err != nil {
+		return err
+	}
 
-			numItems++
+	for _, e := range rowErrors {
+		err = writer.Write(append([]string{strconv.Itoa(e.Line), e.Reason}, e.Record...))
+		if err != nil {
+			return err
 		}
 	}
 
-	file.Close()
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// entityItem returns the table item for the entity
+func entityItem(debug bool, e entity.Entity) map[string]types.AttributeValue {
+	debugPrint(debug, "")
+	debugPrint(debug, "Description: "+e.Description)
+	debugPrint(debug, "Path:        "+e.Path)
+	debugPrint(debug, "Service:     "+e.Service)
+	debugPrint(debug, "SDK:         "+e.Sdk)
+	debugPrint(debug, "Target:      "+e.Target)
+	debugPrint(debug, "Action:      "+e.Action)
+	debugPrint(debug, "")
+
+	attrs := make(map[string]types.AttributeValue, 6)
+
+	attrs["path"] = &types.AttributeValueMemberS{
+		Value: e.Path,
+	}
+
+	attrs["action"] = &types.AttributeValueMemberS{
+		Value: e.Action,
+	}
+
+	attrs["sdk"] = &types.AttributeValueMemberS{
+		Value: e.Sdk,
+	}
+
+	attrs["service"] = &types.AttributeValueMemberS{
+		Value: e.Service,
+	}
+
+	attrs["target"] = &types.AttributeValueMemberS{
+		Value: e.Target,
+	}
 
-	return numItems, nil
+	attrs["description"] = &types.AttributeValueMemberS{
+		Value: e.Description,
+	}
+
+	return attrs
 }
 
 func main() {
 	csvFile := flag.String("f", "", "The CSV file to get entries from")
+	format := flag.String("format", "", "The format of the file: csv, jsonl, or yaml, as ExportEntities writes them; by default it comes from the file's extension, or is csv")
+	delimiter := flag.String("D", "|", "The character that separates the columns; use tab for a TSV file")
+	reportFile := flag.String("e", "", "The file to write the rows that could not be imported to, as CSV")
+	registryFile := flag.String("registry", registry.DefaultFile, "The YAML file with the services and SDKs")
+	workers := flag.Int("w", 4, "How many batches of items to write at once")
+	rate := flag.Int("rate", 0, "The most items to write each second, to stay under a provisioned table's write capacity; 0 means no limit")
 	debug := flag.Bool("d", false, "Whether to barf out more info")
 
 	flag.Parse()
@@ -274,17 +401,113 @@ func main() {
 		return
 	}
 
-	err := populateConfiguration()
+	delim, err := entity.ParseDelimiter(*delimiter)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	err = populateConfiguration()
 	if err != nil {
 		fmt.Println("Could not parse " + configFileName)
 		return
 	}
 
-	numItems, err := addItemsToTable(*debug, *csvFile)
+	globalRegistry, err = registry.Load(*registryFile)
 	if err != nil {
-		fmt.Println("Got an error adding items to table:")
-		fmt.Println(err.Error())
+		fmt.Println("Could not load the services and SDKs: " + err.Error())
+		return
 	}
 
-	fmt.Println("Added " + strconv.Itoa(numItems) + " items to the table")
+	file, err := os.Open(*csvFile)
+	if err != nil {
+		fmt.Println("Got an error opening " + *csvFile)
+		return
+	}
+
+	if *format == "" {
+		switch filepath.Ext(*csvFile) {
+		case ".jsonl":
+			*format = "jsonl"
+		case ".yaml", ".yml":
+			*format = "yaml"
+		default:
+			*format = "csv"
+		}
+	}
+
+	var entities []entity.Entity
+	var rowErrors []RowError
+
+	unit := "line "
+
+	switch *format {
+	case "csv":
+		entities, rowErrors, err = ReadEntities(file, delim)
+	case "jsonl":
+		entities, rowErrors, err = ReadEntitiesJSONLines(file)
+	case "yaml":
+		entities, rowErrors, err = ReadEntitiesYAML(file)
+		unit = "entry "
+	default:
+		err = errors.New("The format must be csv, jsonl, or yaml, not " + *format)
+	}
+
+	file.Close()
+
+	if err != nil {
+		fmt.Println("Could not read " + *csvFile + ": " + err.Error())
+		return
+	}
+
+	for _, e := range rowErrors {
+		fmt.Println(*csvFile + ": " + unit + strconv.Itoa(e.Line) + ": " + e.Reason)
+	}
+
+	if *reportFile != "" && len(rowErrors) > 0 {
+		report, err := os.Create(*reportFile)
+		if err != nil {
+			fmt.Println("Could not create " + *reportFile + ": " + err.Error())
+			return
+		}
+
+		err = WriteErrorReport(report, rowErrors)
+		report.Close()
+
+		if err != nil {
+			fmt.Println("Could not write " + *reportFile + ": " + err.Error())
+			return
+		}
+
+		fmt.Println("Wrote the rows that were skipped to " + *reportFile)
+	}
+
+	items := []map[string]types.AttributeValue{}
+	for _, e := range entities {
+		items = append(items, entityItem(*debug, e))
+	}
+
+	// Create DynamoDB client
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		fmt.Println("Got the configuration error: " + err.Error())
+		return
+	}
+
+	writer := batch.NewWriter(dynamodb.NewFromConfig(cfg), globalConfig.Table)
+	writer.Workers = *workers
+	writer.ItemsPerSecond = *rate
+	writer.Progress = os.Stdout
+
+	stats, errs := writer.Write(context.TODO(), items)
+
+	for _, err := range errs {
+		fmt.Println("Got an error adding items to table: " + err.Error())
+	}
+
+	fmt.Println("Wrote " + strconv.Itoa(stats.Written) + " items, skipped " + strconv.Itoa(len(rowErrors)) + " rows, and failed to write " + strconv.Itoa(stats.Failed) + " items")
+
+	if stats.Failed > 0 {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Doug-AWS/code-examples/go/dynamodb/entity"
+	"github.com/Doug-AWS/code-examples/go/dynamodb/registry"
+)
+
+func init() {
+	globalConfig = Config{
+		Table:   "CodeExamplesEntries",
+		Targets: []string{"guide", "catalog"},
+		Rules: []entity.PathRule{
+			{Sdk: "go", Target: "guide", Prefixes: []string{"https://aws.github.io/aws-sdk-go-v2/docs/code-examples/"}},
+			{Sdk: "*", Target: "guide", Prefixes: []string{"https://docs.aws.amazon.com"}},
+			{Sdk: "*", Target: "catalog", Prefixes: []string{"https://"}},
+		},
+	}
+
+	var err error
+
+	globalRegistry, err = registry.Parse([]byte(`
+services:
+  - {id: sns, entity: SNS}
+sdks:
+  - {id: go, entity: Golong}
+  - {id: java, entity: JavaV2long}
+`))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestReadEntities(t *testing.T) {
+	// Columns in a different order, an extra column, a quoted delimiter, embedded quotes,
+	// a blank line, a bad row in the middle, and a trailing newline
+	input := "\ufeff\"action\"|\"path\"|\"sdk\"|\"service\"|\"target\"|\"description\"|\"notes\"\n" +
+		"\"section\"|\"https://docs.aws.amazon.com/sns\"|\"java\"|\"sns\"|\"guide\"|\"Topics | subscriptions\"|\"\"\n" +
+		"\n" +
+		"\"CreateTopic\"|\"https://docs.aws.amazon.com/sns\"|\"java\"|\"ec2\"|\"guide\"|\"Creates a topic\"|\"\"\n" +
+		"\"CreateTopic\"|\"https://docs.aws.amazon.com/sns\"|\"java\"|\"sns\"|\"guide\"|\"Creates a \"\"topic\"\"\"|\"\"\n" +
+		"\"ListTopics\"|\"https://docs.aws.amazon.com/sns\"|\"java\"|\"sns\"\n" +
+		"\"DeleteTopic\"|\"https://docs.aws.amazon.com/sns\"|\"java\"|\"sns\"|\"guide\"|\"Deletes \"a\" topic\"|\"\"\n" +
+		"\"section\"|\"https://docs.aws.amazon.com/sns\"|\"java\"|\"sns\"|\"guide\"|\"Again\"|\"\"\n"
+
+	entities, rowErrors, err := ReadEntities(strings.NewReader(input), '|')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entities) != 2 {
+		t.Fatalf("Expected 2 entities, got %d: %v", len(entities), entities)
+	}
+
+	if entities[0].Description != "Topics | subscriptions" || entities[0].Action != "section" {
+		t.Errorf("Got %v", entities[0])
+	}
+
+	if entities[1].Description != `Creates a "topic"` {
+		t.Errorf("Got description %s", entities[1].Description)
+	}
+
+	lines := []int{4, 6, 7, 8}
+
+	if len(rowErrors) != len(lines) {
+		t.Fatalf("Expected %d bad rows, got %v", len(lines), rowErrors)
+	}
+
+	for i, line := range lines {
+		if rowErrors[i].Line != line {
+			t.Errorf("Expected bad row %d on line %d, got line %d: %s", i, line, rowErrors[i].Line, rowErrors[i].Reason)
+		}
+	}
+
+	var report bytes.Buffer
+
+	err = WriteErrorReport(&report, rowErrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(report.String(), "line,error,record\n4,ec2 is not in the list of services,CreateTopic,") {
+		t.Errorf("Got report %s", report.String())
+	}
+}
+
+func TestReadEntitiesTSV(t *testing.T) {
+	delimiter, err := entity.ParseDelimiter("tab")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := "path\taction\tsdk\tservice\ttarget\tdescription\n" +
+		"https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns/\tsection\tgo\tsns\tguide\tSNS, with a comma\n"
+
+	entities, rowErrors, err := ReadEntities(strings.NewReader(input), delimiter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entities) != 1 || len(rowErrors) != 0 || entities[0].Description != "SNS, with a comma" {
+		t.Errorf("Got entities %v and bad rows %v", entities, rowErrors)
+	}
+
+	_, _, err = ReadEntities(strings.NewReader("path\taction\tsdk\n"), delimiter)
+	if err == nil {
+		t.Error("Expected an error for a header without all of the columns")
+	}
+}
+
+func TestReadEntitiesExported(t *testing.T) {
+	// What ExportEntities writes for the same two entities in each format
+	jsonLines := `{"path":"https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns/","action":"CreateTopic","sdk":"go","service":"sns","target":"guide","description":"Creates a \"topic\" | and more"}
+{"path":"https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns/","action":"section","sdk":"go","service":"sns","target":"guide","description":"Creates a \"topic\" | and more"}
+
+{"path":"https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns/","action":"ListTopics","sdk":"go","service":"sns","target":"guide","desc":"Lists topics"}
+`
+
+	yamlFile := `files:
+- path: https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns/
+  description: Creates a "topic" | and more
+  sdk: go
+  target: guide
+  services:
+  - service: sns
+    actions:
+    - CreateTopic
+    - section
+- path: https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns/
+  description: Another
+  sdk: go
+  target: guide
+  services:
+  - service: sns
+    actions:
+    - section
+`
+
+	fromJSON, rowErrors, err := ReadEntitiesJSONLines(strings.NewReader(jsonLines))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rowErrors) != 1 || rowErrors[0].Line != 4 {
+		t.Errorf("Expected the unknown field on line 4 to be reported, got %v", rowErrors)
+	}
+
+	fromYAML, rowErrors, err := ReadEntitiesYAML(strings.NewReader(yamlFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rowErrors) != 1 || rowErrors[0].Line != 2 || rowErrors[0].Reason != "has the same path and action as entry 1" {
+		t.Errorf("Expected the second section in entry 2 to be reported, got %v", rowErrors)
+	}
+
+	if len(fromJSON) != 2 || len(fromYAML) != 2 {
+		t.Fatalf("Expected two entities from each, got %v and %v", fromJSON, fromYAML)
+	}
+
+	for i := range fromJSON {
+		if fromJSON[i] != fromYAML[i] {
+			t.Errorf("Got %v from JSON and %v from YAML", fromJSON[i], fromYAML[i])
+		}
+	}
+
+	if fromJSON[0].Description != `Creates a "topic" | and more` {
+		t.Errorf("Got description %s", fromJSON[0].Description)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	// Rows ExportEntities can write: both targets, values with spaces around them,
+	// and descriptions with quotes, delimiters, tabs, and newlines
+	entities := []entity.Entity{
+		{Path: "https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns/", Action: "CreateTopic", Sdk: "go", Service: "sns", Target: "guide", Description: " Creates a \"topic\" | and more "},
+		{Path: "https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns/", Action: "section", Sdk: "go", Service: "sns", Target: "catalog", Description: "Creates a topic"},
+		{Path: "https://docs.aws.amazon.com/sns", Action: "section", Sdk: "java", Service: "sns", Target: "guide", Description: "Topics\tand\nsubscriptions"},
+		{Path: "https://github.com/awsdocs/aws-doc-sdk-examples/tree/main/javav2/example_code/sns", Action: "ListTopics", Sdk: "java", Service: "sns", Target: "catalog", Description: "Lists topics"},
+	}
+
+	formats := map[string]func(*bytes.Buffer) ([]entity.Entity, []RowError, error){
+		"csv": func(b *bytes.Buffer) ([]entity.Entity, []RowError, error) {
+			err := entity.WriteCSV(b, entities, '|')
+			if err != nil {
+				return nil, nil, err
+			}
+
+			return ReadEntities(b, '|')
+		},
+		"tsv": func(b *bytes.Buffer) ([]entity.Entity, []RowError, error) {
+			err := entity.WriteCSV(b, entities, '\t')
+			if err != nil {
+				return nil, nil, err
+			}
+
+			return ReadEntities(b, '\t')
+		},
+		"jsonl": func(b *bytes.Buffer) ([]entity.Entity, []RowError, error) {
+			err := entity.WriteJSONLines(b, entities)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			return ReadEntitiesJSONLines(b)
+		},
+		"yaml": func(b *bytes.Buffer) ([]entity.Entity, []RowError, error) {
+			err := entity.WriteYAML(b, entities)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			return ReadEntitiesYAML(b)
+		},
+	}
+
+	for format, roundTrip := range formats {
+		var b bytes.Buffer
+
+		got, rowErrors, err := roundTrip(&b)
+		if err != nil {
+			t.Errorf("Got an error for %s: %v", format, err)
+			continue
+		}
+
+		if len(rowErrors) != 0 {
+			t.Errorf("Expected every %s row to be imported, got %v", format, rowErrors)
+		}
+
+		if len(got) != len(entities) {
+			t.Errorf("Expected %d entities from %s, got %v", len(entities), format, got)
+			continue
+		}
+
+		for i := range entities {
+			if got[i] != entities[i] {
+				t.Errorf("Expected %q from %s, got %q", entities[i], format, got[i])
+			}
+		}
+	}
+}
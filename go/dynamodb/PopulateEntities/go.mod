@@ -1,8 +1,30 @@
 module mymain
 
-go 1.15
+go 1.17
 
 require (
+	github.com/Doug-AWS/code-examples/go/dynamodb/batch v0.0.0
+	github.com/Doug-AWS/code-examples/go/dynamodb/entity v0.0.0
+	github.com/Doug-AWS/code-examples/go/dynamodb/registry v0.0.0
 	github.com/aws/aws-sdk-go-v2/config v1.1.1
 	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.1.1
+	gopkg.in/yaml.v2 v2.2.8
 )
+
+require (
+	github.com/aws/aws-sdk-go-v2 v1.2.0 // indirect
+	github.com/aws/aws-sdk-go-v2/credentials v1.1.1 // indirect
+	github.com/aws/aws-sdk-go-v2/feature/ec2/imds v1.0.2 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/accept-encoding v1.0.1 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/presigned-url v1.0.2 // indirect
+	github.com/aws/aws-sdk-go-v2/service/sso v1.1.1 // indirect
+	github.com/aws/aws-sdk-go-v2/service/sts v1.1.1 // indirect
+	github.com/aws/smithy-go v1.1.0 // indirect
+	github.com/jmespath/go-jmespath v0.4.0 // indirect
+)
+
+replace github.com/Doug-AWS/code-examples/go/dynamodb/batch => ../batch
+
+replace github.com/Doug-AWS/code-examples/go/dynamodb/entity => ../entity
+
+replace github.com/Doug-AWS/code-examples/go/dynamodb/registry => ../registry
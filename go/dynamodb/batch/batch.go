@@ -0,0 +1,222 @@
+// Package batch writes items to a DynamoDB table in concurrent, throttled batches,
+// retrying the items DynamoDB doesn't process.
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoDBBatchWriteItemAPI defines the interface for the BatchWriteItem function
+type DynamoDBBatchWriteItemAPI interface {
+	BatchWriteItem(ctx context.Context,
+		params *dynamodb.BatchWriteItemInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+}
+
+// maxBatchSize is the most items BatchWriteItem takes in one call
+const maxBatchSize = 25
+
+// Stats counts the items that were and weren't written
+type Stats struct {
+	Written int
+	Failed  int
+}
+
+// Writer writes items to a table in batches with a pool of workers.
+// Items DynamoDB doesn't process are retried with exponential backoff.
+type Writer struct {
+	API   DynamoDBBatchWriteItemAPI
+	Table string
+	// How many batches are written at once
+	Workers int
+	// The most items written each second, so a provisioned table isn't throttled; 0 means no limit
+	ItemsPerSecond int
+	// How many times unprocessed items are retried, waiting BaseDelay, then twice that, and so on, up to MaxDelay
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	// Where progress is written; nil means nowhere
+	Progress io.Writer
+
+	mu    sync.Mutex
+	next  time.Time
+	stats Stats
+	total int
+}
+
+// NewWriter returns a Writer with four workers and up to eight retries
+func NewWriter(api DynamoDBBatchWriteItemAPI, table string) *Writer {
+	return &Writer{
+		API:        api,
+		Table:      table,
+		Workers:    4,
+		MaxRetries: 8,
+		BaseDelay:  100 * time.Millisecond,
+		MaxDelay:   10 * time.Second,
+	}
+}
+
+// throttle waits until n more items can be written without going over ItemsPerSecond
+func (w *Writer) throttle(n int) {
+	if w.ItemsPerSecond <= 0 {
+		return
+	}
+
+	w.mu.Lock()
+
+	now := time.Now()
+	if w.next.Before(now) {
+		w.next = now
+	}
+
+	wait := w.next.Sub(now)
+	w.next = w.next.Add(time.Duration(n) * time.Second / time.Duration(w.ItemsPerSecond))
+
+	w.mu.Unlock()
+
+	time.Sleep(wait)
+}
+
+// backoff returns how long to wait before retry number attempt, with jitter so the workers don't retry together
+func (w *Writer) backoff(attempt int) time.Duration {
+	d := w.BaseDelay << uint(attempt)
+	if d > w.MaxDelay || d < w.BaseDelay {
+		d = w.MaxDelay
+	}
+
+	if d <= 0 {
+		return 0
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// isThrottled returns whether err means the table is busy and the batch can be retried
+func isThrottled(err error) bool {
+	var throughput *types.ProvisionedThroughputExceededException
+	var limit *types.RequestLimitExceeded
+
+	return errors.As(err, &throughput) || errors.As(err, &limit)
+}
+
+// writeBatch writes one batch and returns how many items were written
+func (w *Writer) writeBatch(c context.Context, requests []types.WriteRequest) (int, error) {
+	written := 0
+
+	for attempt := 0; ; attempt++ {
+		w.throttle(len(requests))
+
+		resp, err := w.API.BatchWriteItem(c, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{w.Table: requests},
+		})
+
+		if err != nil && !isThrottled(err) {
+			return written, err
+		}
+
+		if err == nil {
+			unprocessed := resp.UnprocessedItems[w.Table]
+			written += len(requests) - len(unprocessed)
+			requests = unprocessed
+
+			if len(requests) == 0 {
+				return written, nil
+			}
+		}
+
+		if attempt == w.MaxRetries {
+			return written, errors.New(strconv.Itoa(len(requests)) + " items were still not written after " + strconv.Itoa(w.MaxRetries) + " retries")
+		}
+
+		select {
+		case <-c.Done():
+			return written, c.Err()
+		case <-time.After(w.backoff(attempt)):
+		}
+	}
+}
+
+func (w *Writer) report(written, failed int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.stats.Written += written
+	w.stats.Failed += failed
+
+	if w.Progress != nil {
+		fmt.Fprintf(w.Progress, "\rWrote %d of %d items (%d failed)", w.stats.Written, w.total, w.stats.Failed)
+	}
+}
+
+// Write writes the items and returns how many were written and failed,
+// and the error for each batch that failed
+func (w *Writer) Write(c context.Context, items []map[string]types.AttributeValue) (Stats, []error) {
+	w.stats = Stats{}
+	w.total = len(items)
+
+	batches := make(chan []types.WriteRequest)
+	errs := []error{}
+
+	workers := w.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for batch := range batches {
+				written, err := w.writeBatch(c, batch)
+
+				w.report(written, len(batch)-written)
+
+				if err != nil {
+					w.mu.Lock()
+					errs = append(errs, err)
+					w.mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for start := 0; start < len(items); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(items) {
+			end = len(items)
+		}
+
+		batch := make([]types.WriteRequest, 0, end-start)
+
+		for _, item := range items[start:end] {
+			batch = append(batch, types.WriteRequest{
+				PutRequest: &types.PutRequest{Item: item},
+			})
+		}
+
+		batches <- batch
+	}
+
+	close(batches)
+	wg.Wait()
+
+	if w.Progress != nil && len(items) > 0 {
+		fmt.Fprintln(w.Progress)
+	}
+
+	return w.stats, errs
+}
@@ -0,0 +1,117 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// mockBatchClient keeps the paths of the items it wrote.
+// The first call is throttled, every other call leaves the last two items unprocessed,
+// and any batch with a path of "bad" fails.
+type mockBatchClient struct {
+	mu      sync.Mutex
+	calls   int
+	largest int
+	paths   map[string]bool
+}
+
+func (m *mockBatchClient) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls++
+
+	if m.calls == 1 {
+		return nil, &types.ProvisionedThroughputExceededException{Message: aws.String("Slow down")}
+	}
+
+	requests := params.RequestItems["CodeExamplesEntries"]
+	if len(requests) > m.largest {
+		m.largest = len(requests)
+	}
+
+	for _, r := range requests {
+		if r.PutRequest.Item["path"].(*types.AttributeValueMemberS).Value == "bad" {
+			return nil, errors.New("ValidationException")
+		}
+	}
+
+	processed := requests
+	resp := &dynamodb.BatchWriteItemOutput{}
+
+	if m.calls%2 == 0 && len(requests) > 2 {
+		processed = requests[:len(requests)-2]
+		resp.UnprocessedItems = map[string][]types.WriteRequest{
+			"CodeExamplesEntries": requests[len(requests)-2:],
+		}
+	}
+
+	for _, r := range processed {
+		m.paths[r.PutRequest.Item["path"].(*types.AttributeValueMemberS).Value] = true
+	}
+
+	return resp, nil
+}
+
+func item(path string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"path":   &types.AttributeValueMemberS{Value: path},
+		"action": &types.AttributeValueMemberS{Value: "section"},
+	}
+}
+
+func TestWriter(t *testing.T) {
+	items := []map[string]types.AttributeValue{}
+
+	for i := 0; i < 60; i++ {
+		items = append(items, item(strconv.Itoa(i)))
+	}
+
+	client := &mockBatchClient{paths: map[string]bool{}}
+
+	writer := NewWriter(client, "CodeExamplesEntries")
+	writer.BaseDelay = 0
+
+	stats, errs := writer.Write(context.Background(), items)
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	if stats.Written != 60 || stats.Failed != 0 || len(client.paths) != 60 {
+		t.Errorf("Expected all 60 items written, got %v and %d paths", stats, len(client.paths))
+	}
+
+	if client.largest != maxBatchSize {
+		t.Errorf("Expected batches of %d items, got %d", maxBatchSize, client.largest)
+	}
+
+	// One bad item fails its batch, but the others are still written
+	items[30] = item("bad")
+	client = &mockBatchClient{paths: map[string]bool{}, calls: 1}
+
+	writer = NewWriter(client, "CodeExamplesEntries")
+	writer.BaseDelay = 0
+	writer.ItemsPerSecond = 100000
+
+	var progress bytes.Buffer
+	writer.Progress = &progress
+
+	stats, errs = writer.Write(context.Background(), items)
+
+	if len(errs) != 1 || stats.Written != 35 || stats.Failed != 25 {
+		t.Errorf("Expected one failed batch, got %v and %v", stats, errs)
+	}
+
+	if !strings.Contains(progress.String(), "Wrote 35 of 60 items (25 failed)") {
+		t.Errorf("Got progress %q", progress.String())
+	}
+}
@@ -0,0 +1,8 @@
+module github.com/Doug-AWS/code-examples/go/dynamodb/batch
+
+go 1.15
+
+require (
+	github.com/aws/aws-sdk-go-v2 v1.2.0
+	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.1.1
+)
@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"errors"
+	"strconv"
+	"unicode/utf8"
+)
+
+// ParseDelimiter returns the delimiter of a CSV file named by s, which is a single character,
+// or "tab" or \t for tab-separated files
+func ParseDelimiter(s string) (rune, error) {
+	if s == "tab" || s == `\t` {
+		return '\t', nil
+	}
+
+	r := []rune(s)
+	if len(r) != 1 || r[0] == '"' || r[0] == '\r' || r[0] == '\n' || r[0] == utf8.RuneError {
+		return 0, errors.New("The delimiter must be a single character other than a quote or newline, not " + strconv.Quote(s))
+	}
+
+	return r[0], nil
+}
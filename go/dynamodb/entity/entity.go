@@ -0,0 +1,81 @@
+// Package entity is the rows of the entity table, in the formats ExportEntities writes
+// and PopulateEntities reads, so a table can be exported and imported again without losing anything.
+package entity
+
+import (
+	"errors"
+	"strings"
+)
+
+// Entity is one item in the table
+type Entity struct {
+	Path        string `json:"path"`
+	Action      string `json:"action"`
+	Sdk         string `json:"sdk"`
+	Service     string `json:"service"`
+	Target      string `json:"target"`
+	Description string `json:"description"`
+}
+
+// Columns are the attributes of an entity, in the order they're written to a CSV file
+var Columns = []string{"path", "action", "sdk", "service", "target", "description"}
+
+// Record returns the values of the entity in the order of Columns
+func (e Entity) Record() []string {
+	return []string{e.Path, e.Action, e.Sdk, e.Service, e.Target, e.Description}
+}
+
+// Service is a service and its actions in a YAML file
+type Service struct {
+	Service string   `yaml:"service"`
+	Actions []string `yaml:"actions"`
+}
+
+// File is the entities that share a path, description, SDK, and target in a YAML file.
+// It's the shape of an entry in metadata.yaml, with the full path and the SDK and target added
+// so PopulateEntities can import it without losing anything.
+type File struct {
+	Path        string    `yaml:"path"`
+	Description string    `yaml:"description"`
+	Sdk         string    `yaml:"sdk"`
+	Target      string    `yaml:"target"`
+	Services    []Service `yaml:"services"`
+}
+
+// Metadata is the contents of a YAML file
+type Metadata struct {
+	Files []File `yaml:"files"`
+}
+
+// PathRule lists the prefixes a path must start with for an SDK and target.
+// An SDK or target of * matches any.
+type PathRule struct {
+	Sdk      string   `json:"sdk"`
+	Target   string   `json:"target"`
+	Prefixes []string `json:"prefixes"`
+}
+
+func matches(rule string, value string) bool {
+	return rule == "*" || rule == value
+}
+
+// CheckPath checks the path against the first rule for the SDK and target
+func CheckPath(rules []PathRule, path string, sdk string, target string) error {
+	for _, r := range rules {
+		if !matches(r.Sdk, sdk) || !matches(r.Target, target) {
+			continue
+		}
+
+		for _, p := range r.Prefixes {
+			if strings.HasPrefix(path, p) {
+				return nil
+			}
+		}
+
+		msg := "Path does not start with " + strings.Join(r.Prefixes, " or ")
+		return errors.New(msg)
+	}
+
+	msg := "Valid path prefix is not set for the " + sdk + " SDK and " + target + " target"
+	return errors.New(msg)
+}
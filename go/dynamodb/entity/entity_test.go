@@ -0,0 +1,92 @@
+package entity
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCheckPath(t *testing.T) {
+	rules := []PathRule{
+		{Sdk: "go", Target: "guide", Prefixes: []string{"https://aws.github.io/aws-sdk-go-v2/docs/code-examples/"}},
+		{Sdk: "*", Target: "guide", Prefixes: []string{"https://docs.aws.amazon.com"}},
+	}
+
+	tests := []struct {
+		path, sdk, target string
+		valid             bool
+	}{
+		{"https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns/", "go", "guide", true},
+		{"https://docs.aws.amazon.com/sns", "go", "guide", false},
+		{"https://docs.aws.amazon.com/sns", "java", "guide", true},
+		{"https://example.com/sns", "java", "guide", false},
+		{"https://docs.aws.amazon.com/sns", "java", "catalog", false},
+	}
+
+	for _, test := range tests {
+		err := CheckPath(rules, test.path, test.sdk, test.target)
+		if (err == nil) != test.valid {
+			t.Errorf("Got %v for %s with the %s SDK and %s target", err, test.path, test.sdk, test.target)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	entities := []Entity{
+		{"https://example.com/s3/copyobject", "CopyObject", "go", "s3", "guide", `Copies an "object" | to a bucket`},
+		{"https://example.com/s3/copyobject", "CreateBucket", "go", "s3", "guide", `Copies an "object" | to a bucket`},
+		{"https://example.com/s3/copyobject", "GetCallerIdentity", "go", "sts", "guide", `Copies an "object" | to a bucket`},
+		{"https://example.com/sns", "section", "go", "sns", "guide", "SNS"},
+	}
+
+	var b bytes.Buffer
+
+	err := WriteCSV(&b, entities[3:], '|')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b.String() != "path|action|sdk|service|target|description\nhttps://example.com/sns|section|go|sns|guide|SNS\n" {
+		t.Errorf("Got CSV %q", b.String())
+	}
+
+	b.Reset()
+
+	err = WriteJSONLines(&b, entities[3:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b.String() != `{"path":"https://example.com/sns","action":"section","sdk":"go","service":"sns","target":"guide","description":"SNS"}`+"\n" {
+		t.Errorf("Got JSON lines %q", b.String())
+	}
+
+	metadata := GroupFiles(entities)
+
+	if len(metadata.Files) != 2 {
+		t.Fatalf("Expected the entities with the same path and description in one file, got %v", metadata.Files)
+	}
+
+	services := metadata.Files[0].Services
+
+	if len(services) != 2 || services[0].Service != "s3" || len(services[0].Actions) != 2 || services[1].Service != "sts" {
+		t.Errorf("Got services %v", services)
+	}
+}
+
+func TestParseDelimiter(t *testing.T) {
+	tests := map[string]rune{"tab": '\t', `\t`: '\t', "|": '|', ",": ',', "§": '§'}
+
+	for s, want := range tests {
+		got, err := ParseDelimiter(s)
+		if err != nil || got != want {
+			t.Errorf("Got %q and %v for %q", got, err, s)
+		}
+	}
+
+	for _, s := range []string{"", `"`, "\n", "||", "\xff"} {
+		_, err := ParseDelimiter(s)
+		if err == nil {
+			t.Errorf("Expected an error for %q", s)
+		}
+	}
+}
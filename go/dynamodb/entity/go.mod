@@ -0,0 +1,5 @@
+module github.com/Doug-AWS/code-examples/go/dynamodb/entity
+
+go 1.15
+
+require gopkg.in/yaml.v2 v2.2.8
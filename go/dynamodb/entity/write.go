@@ -0,0 +1,95 @@
+package entity
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+
+	"gopkg.in/yaml.v2"
+)
+
+// WriteCSV writes the entities with a header, the way PopulateEntities reads them
+func WriteCSV(w io.Writer, entities []Entity, delimiter rune) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = delimiter
+
+	err := writer.Write(Columns)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entities {
+		err = writer.Write(e.Record())
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// WriteJSONLines writes each entity as a JSON object on its own line
+func WriteJSONLines(w io.Writer, entities []Entity) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+
+	for _, e := range entities {
+		err := encoder.Encode(e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GroupFiles groups the entities by path, description, SDK, and target,
+// and then by service, keeping the order of the entities
+func GroupFiles(entities []Entity) Metadata {
+	type fileKey struct {
+		path, description, sdk, target string
+	}
+
+	metadata := Metadata{Files: []File{}}
+	files := map[fileKey]int{}
+
+	for _, e := range entities {
+		key := fileKey{e.Path, e.Description, e.Sdk, e.Target}
+
+		i, ok := files[key]
+		if !ok {
+			i = len(metadata.Files)
+			files[key] = i
+			metadata.Files = append(metadata.Files, File{Path: e.Path, Description: e.Description, Sdk: e.Sdk, Target: e.Target})
+		}
+
+		f := &metadata.Files[i]
+
+		j := 0
+		for j < len(f.Services) && f.Services[j].Service != e.Service {
+			j++
+		}
+
+		if j == len(f.Services) {
+			f.Services = append(f.Services, Service{Service: e.Service})
+		}
+
+		f.Services[j].Actions = append(f.Services[j].Actions, e.Action)
+	}
+
+	return metadata
+}
+
+// WriteYAML writes the entities in the shape of metadata.yaml
+func WriteYAML(w io.Writer, entities []Entity) error {
+	b, err := yaml.Marshal(GroupFiles(entities))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+
+	return err
+}
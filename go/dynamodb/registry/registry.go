@@ -0,0 +1,213 @@
+// Package registry maps the service and SDK names in the entity table
+// to their DocBook entity names, display names, and documentation URLs.
+// The mappings come from a YAML file, so adding a service doesn't mean changing any code:
+//
+//	services:
+//	  - id: s3
+//	    entity: S3
+//	    name: Amazon S3
+//	    docUrl: https://docs.aws.amazon.com/AmazonS3/latest/API/API_{action}.html
+//	sdks:
+//	  - id: go
+//	    entity: Golong
+//	    name: AWS SDK for Go V2
+//	    docUrl: https://aws.github.io/aws-sdk-go-v2/docs/code-examples/{service}/
+package registry
+
+import (
+	"errors"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultFile is where the tools in go/dynamodb find the registry, relative to their own directory
+const DefaultFile = "../registry/registry.yaml"
+
+// Entry describes a service or SDK
+type Entry struct {
+	// The name in the entity table, such as s3 or go
+	ID string `yaml:"id"`
+	// The DocBook entity for the name, such as S3 for &S3;
+	Entity string `yaml:"entity"`
+	// The name to show people, such as Amazon S3
+	Name string `yaml:"name"`
+	// The documentation URL, where {service}, {sdk}, and {action} are replaced by URL
+	DocURL string `yaml:"docUrl"`
+}
+
+// URL returns the documentation URL with {service}, {sdk}, and {action} replaced
+func (e Entry) URL(service, sdk, action string) string {
+	return strings.NewReplacer("{service}", service, "{sdk}", sdk, "{action}", action).Replace(e.DocURL)
+}
+
+// Registry is the services and SDKs in the registry file
+type Registry struct {
+	Services []Entry `yaml:"services"`
+	SDKs     []Entry `yaml:"sdks"`
+
+	services map[string]Entry
+	sdks     map[string]Entry
+}
+
+// Load reads and validates the registry file
+func Load(path string) (*Registry, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := Parse(b)
+	if err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+
+	return r, nil
+}
+
+// Parse reads and validates a registry.
+// Unknown fields, entries without an ID, and IDs or entity names used twice are errors.
+// Every service needs an entity name; an SDK that doesn't have one can't be used in entities.
+func Parse(b []byte) (*Registry, error) {
+	var r Registry
+
+	err := yaml.UnmarshalStrict(b, &r)
+	if err != nil {
+		return nil, err
+	}
+
+	r.services, err = index("services", r.Services, true)
+	if err != nil {
+		return nil, err
+	}
+
+	r.sdks, err = index("sdks", r.SDKs, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// index returns the entries by ID, checking that no ID or entity name is used twice
+func index(list string, entries []Entry, needEntity bool) (map[string]Entry, error) {
+	ids := map[string]int{}
+	entities := map[string]int{}
+
+	where := func(i int) string {
+		return list + "[" + strconv.Itoa(i) + "]"
+	}
+
+	for i, e := range entries {
+		if e.ID == "" {
+			return nil, errors.New(where(i) + " has no id")
+		}
+
+		j, ok := ids[e.ID]
+		if ok {
+			return nil, errors.New(where(i) + " has the same id, " + e.ID + ", as " + where(j))
+		}
+
+		ids[e.ID] = i
+
+		if e.Entity == "" {
+			if needEntity {
+				return nil, errors.New(where(i) + " (" + e.ID + ") has no entity")
+			}
+
+			continue
+		}
+
+		j, ok = entities[e.Entity]
+		if ok {
+			return nil, errors.New(where(i) + " (" + e.ID + ") has the same entity, " + e.Entity + ", as " + where(j) + " (" + entries[j].ID + ")")
+		}
+
+		entities[e.Entity] = i
+	}
+
+	m := map[string]Entry{}
+	for _, e := range entries {
+		m[e.ID] = e
+	}
+
+	return m, nil
+}
+
+// Service returns the service with the ID
+func (r *Registry) Service(id string) (Entry, error) {
+	e, ok := r.services[id]
+	if !ok {
+		return Entry{}, errors.New("Unidentified service: " + id)
+	}
+
+	return e, nil
+}
+
+// SDK returns the SDK with the ID
+func (r *Registry) SDK(id string) (Entry, error) {
+	e, ok := r.sdks[id]
+	if !ok {
+		return Entry{}, errors.New("Unidentified SDK: " + id)
+	}
+
+	return e, nil
+}
+
+// ServiceEntityName returns the DocBook entity name of the service, such as S3
+func (r *Registry) ServiceEntityName(id string) (string, error) {
+	e, err := r.Service(id)
+	if err != nil {
+		return "", err
+	}
+
+	return e.Entity, nil
+}
+
+// SDKEntityName returns the DocBook entity name of the SDK, such as Golong
+func (r *Registry) SDKEntityName(id string) (string, error) {
+	e, err := r.SDK(id)
+	if err != nil {
+		return "", err
+	}
+
+	if e.Entity == "" {
+		return "", errors.New("The " + id + " SDK has no entity")
+	}
+
+	return e.Entity, nil
+}
+
+// IsService returns whether the service is in the registry
+func (r *Registry) IsService(id string) bool {
+	_, ok := r.services[id]
+	return ok
+}
+
+// IsSDK returns whether the SDK is in the registry
+func (r *Registry) IsSDK(id string) bool {
+	_, ok := r.sdks[id]
+	return ok
+}
+
+// ServiceIDs returns the IDs of the services in the order of the file
+func (r *Registry) ServiceIDs() []string {
+	ids := []string{}
+	for _, e := range r.Services {
+		ids = append(ids, e.ID)
+	}
+
+	return ids
+}
+
+// SDKIDs returns the IDs of the SDKs in the order of the file
+func (r *Registry) SDKIDs() []string {
+	ids := []string{}
+	for _, e := range r.SDKs {
+		ids = append(ids, e.ID)
+	}
+
+	return ids
+}
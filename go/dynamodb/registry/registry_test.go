@@ -0,0 +1,86 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	r, err := Load("registry.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, err := r.ServiceEntityName("s3")
+	if err != nil || name != "S3" {
+		t.Errorf("Got %s and %v for s3", name, err)
+	}
+
+	name, err = r.SDKEntityName("go")
+	if err != nil || name != "Golong" {
+		t.Errorf("Got %s and %v for go", name, err)
+	}
+
+	_, err = r.SDKEntityName("py")
+	if err == nil {
+		t.Error("Expected an error for an SDK without an entity")
+	}
+
+	_, err = r.ServiceEntityName("lambda")
+	if err == nil || err.Error() != "Unidentified service: lambda" {
+		t.Errorf("Got %v for an unknown service", err)
+	}
+
+	if len(r.ServiceIDs()) != 12 || r.ServiceIDs()[0] != "cloudwatch" {
+		t.Errorf("Got services %v", r.ServiceIDs())
+	}
+
+	if !r.IsSDK("cpp") || r.IsSDK("cobol") {
+		t.Error("Expected cpp and not cobol to be an SDK")
+	}
+
+	e, err := r.SDK("go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	url := e.URL("sns", "go", "section")
+	if url != "https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns/" {
+		t.Errorf("Got URL %s", url)
+	}
+}
+
+func TestParseDuplicates(t *testing.T) {
+	tests := map[string]string{
+		"the same id, s3, as services[0]": `
+services:
+  - {id: s3, entity: S3}
+  - {id: s3, entity: S3v2}
+`,
+		"has the same entity, S3, as services[0] (s3)": `
+services:
+  - {id: s3, entity: S3}
+  - {id: s3control, entity: S3}
+`,
+		"sdks[1] has no id": `
+sdks:
+  - {id: go}
+  - {entity: Golong}
+`,
+		"(sns) has no entity": `
+services:
+  - {id: sns, name: Amazon SNS}
+`,
+		"field url not found": `
+services:
+  - {id: sns, entity: SNS, url: https://docs.aws.amazon.com/sns/}
+`,
+	}
+
+	for want, input := range tests {
+		_, err := Parse([]byte(input))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected an error with %q, got %v", want, err)
+		}
+	}
+}
@@ -3,66 +3,466 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
 	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
+// The provided.al2 runtime runs the executable called bootstrap in the ZIP file
+const bootstrap = "bootstrap"
+
+// Each GOARCH we build for and the matching Lambda architecture
+var architectures = map[string]types.Architecture{
+	"arm64": types.ArchitectureArm64,
+	"amd64": types.ArchitectureX8664,
+}
+
+// How often and how many times to check whether an update is complete
+var (
+	pollInterval = time.Second
+	maxPolls     = 60
+)
+
+// LambdaDeployAPI defines the interface for the Lambda functions used to create or update a function
+type LambdaDeployAPI interface {
+	GetFunction(ctx context.Context,
+		params *lambda.GetFunctionInput,
+		optFns ...func(*lambda.Options)) (*lambda.GetFunctionOutput, error)
+
+	GetFunctionConfiguration(ctx context.Context,
+		params *lambda.GetFunctionConfigurationInput,
+		optFns ...func(*lambda.Options)) (*lambda.GetFunctionConfigurationOutput, error)
+
+	CreateFunction(ctx context.Context,
+		params *lambda.CreateFunctionInput,
+		optFns ...func(*lambda.Options)) (*lambda.CreateFunctionOutput, error)
+
+	UpdateFunctionCode(ctx context.Context,
+		params *lambda.UpdateFunctionCodeInput,
+		optFns ...func(*lambda.Options)) (*lambda.UpdateFunctionCodeOutput, error)
+
+	UpdateFunctionConfiguration(ctx context.Context,
+		params *lambda.UpdateFunctionConfigurationInput,
+		optFns ...func(*lambda.Options)) (*lambda.UpdateFunctionConfigurationOutput, error)
+}
+
+// S3PutObjectAPI defines the interface for the PutObject function
+type S3PutObjectAPI interface {
+	PutObject(ctx context.Context,
+		params *s3.PutObjectInput,
+		optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// Function is the configuration to create the function with or update it to.
+// A zero MemorySize or Timeout, or a nil Environment, uses the Lambda default
+// when creating the function and leaves the current value when updating it.
+type Function struct {
+	Name         string
+	Role         string
+	Architecture types.Architecture
+	MemorySize   int32
+	Timeout      int32
+	Environment  map[string]string
+}
+
+// Code is where the function code is: either a ZIP file in an S3 bucket or the ZIP file itself
+type Code struct {
+	S3Bucket string
+	S3Key    string
+	ZipFile  []byte
+}
+
+// DeployResult describes the deployed function
+type DeployResult struct {
+	FunctionArn string
+	CodeSha256  string
+	Created     bool
+}
+
+// envFlag collects repeated -e KEY=VALUE flags
+type envFlag map[string]string
+
+func (e envFlag) String() string {
+	pairs := []string{}
+	for k, v := range e {
+		pairs = append(pairs, k+"="+v)
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (e envFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return errors.New("Environment variable " + value + " is not KEY=VALUE")
+	}
+
+	e[parts[0]] = parts[1]
+
+	return nil
+}
+
+// BuildBootstrap cross-compiles the handler package in dir for linux/goarch
+// and returns the path to the bootstrap binary in outDir.
+// The lambda.norpc tag leaves out the go1.x RPC server, which provided.al2 doesn't use.
+func BuildBootstrap(dir, goarch, outDir string) (string, error) {
+	_, ok := architectures[goarch]
+	if !ok {
+		return "", errors.New("Unsupported architecture " + goarch + "; use arm64 or amd64")
+	}
+
+	binary := filepath.Join(outDir, bootstrap)
+
+	cmd := exec.Command("go", "build", "-tags", "lambda.norpc", "-trimpath", "-ldflags", "-s -w", "-o", binary, ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH="+goarch, "CGO_ENABLED=0")
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", errors.New("Could not build " + dir + ": " + err.Error() + "\n" + string(output))
+	}
+
+	return binary, nil
+}
+
+// ZipBootstrap returns a ZIP file containing contents as an executable called bootstrap.
+// The timestamp is fixed, so the same binary always gives the same ZIP file and code SHA.
+func ZipBootstrap(contents []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w := zip.NewWriter(&buf)
+
+	header := &zip.FileHeader{
+		Name:     bootstrap,
+		Method:   zip.Deflate,
+		Modified: time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	header.SetMode(0755)
+
+	f, err := w.CreateHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = f.Write(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// CodeSha256 returns the hash of a ZIP file the way Lambda reports it
+func CodeSha256(zipFile []byte) string {
+	sum := sha256.Sum256(zipFile)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// UploadZip uploads zipFile to bucket as key
+func UploadZip(c context.Context, api S3PutObjectAPI, bucket, key string, zipFile []byte) error {
+	_, err := api.PutObject(c, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(zipFile),
+	})
+
+	return err
+}
+
+// optionalInt32 returns nil for zero so Lambda uses the default or keeps the current value
+func optionalInt32(n int32) *int32 {
+	if n == 0 {
+		return nil
+	}
+
+	return aws.Int32(n)
+}
+
+// environment returns nil for no variables so Lambda keeps the current ones
+func environment(vars map[string]string) *types.Environment {
+	if vars == nil {
+		return nil
+	}
+
+	return &types.Environment{Variables: vars}
+}
+
+// WaitForUpdate waits until the function is active and isn't being updated
+func WaitForUpdate(c context.Context, api LambdaDeployAPI, name string) error {
+	for i := 0; i < maxPolls; i++ {
+		resp, err := api.GetFunctionConfiguration(c, &lambda.GetFunctionConfigurationInput{
+			FunctionName: aws.String(name),
+		})
+		if err != nil {
+			return err
+		}
+
+		if resp.State == types.StateFailed {
+			return errors.New("Function " + name + " failed: " + aws.ToString(resp.StateReason))
+		}
+
+		if resp.LastUpdateStatus == types.LastUpdateStatusFailed {
+			return errors.New("Update of " + name + " failed: " + aws.ToString(resp.LastUpdateStatusReason))
+		}
+
+		if resp.State != types.StatePending && resp.LastUpdateStatus != types.LastUpdateStatusInProgress {
+			return nil
+		}
+
+		time.Sleep(pollInterval)
+	}
+
+	return errors.New("Timed out waiting for " + name + " to be updated")
+}
+
+// DeployFunction creates the function if it doesn't exist,
+// otherwise it updates its configuration and then its code
+func DeployFunction(c context.Context, api LambdaDeployAPI, f Function, code Code) (*DeployResult, error) {
+	_, err := api.GetFunction(c, &lambda.GetFunctionInput{
+		FunctionName: aws.String(f.Name),
+	})
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if !errors.As(err, &notFound) {
+			return nil, err
+		}
+
+		return createFunction(c, api, f, code)
+	}
+
+	// Update the configuration first, so a go1.x function
+	// is on provided.al2 before it gets an arm64 bootstrap
+	configArgs := &lambda.UpdateFunctionConfigurationInput{
+		FunctionName: aws.String(f.Name),
+		Runtime:      types.RuntimeProvidedal2,
+		Handler:      aws.String(bootstrap),
+		MemorySize:   optionalInt32(f.MemorySize),
+		Timeout:      optionalInt32(f.Timeout),
+		Environment:  environment(f.Environment),
+	}
+
+	if f.Role != "" {
+		configArgs.Role = aws.String(f.Role)
+	}
+
+	_, err = api.UpdateFunctionConfiguration(c, configArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = WaitForUpdate(c, api, f.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	codeArgs := &lambda.UpdateFunctionCodeInput{
+		FunctionName:  aws.String(f.Name),
+		Architectures: []types.Architecture{f.Architecture},
+		ZipFile:       code.ZipFile,
+	}
+
+	if code.S3Bucket != "" {
+		codeArgs.S3Bucket = aws.String(code.S3Bucket)
+		codeArgs.S3Key = aws.String(code.S3Key)
+	}
+
+	resp, err := api.UpdateFunctionCode(c, codeArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = WaitForUpdate(c, api, f.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeployResult{
+		FunctionArn: aws.ToString(resp.FunctionArn),
+		CodeSha256:  aws.ToString(resp.CodeSha256),
+	}, nil
+}
+
+func createFunction(c context.Context, api LambdaDeployAPI, f Function, code Code) (*DeployResult, error) {
+	if f.Role == "" {
+		return nil, errors.New("You must supply a role ARN (-r ROLE-ARN) to create " + f.Name)
+	}
+
+	createCode := &types.FunctionCode{
+		ZipFile: code.ZipFile,
+	}
+
+	if code.S3Bucket != "" {
+		createCode.S3Bucket = aws.String(code.S3Bucket)
+		createCode.S3Key = aws.String(code.S3Key)
+	}
+
+	createArgs := &lambda.CreateFunctionInput{
+		Code:          createCode,
+		FunctionName:  aws.String(f.Name),
+		Handler:       aws.String(bootstrap),
+		Role:          aws.String(f.Role),
+		Runtime:       types.RuntimeProvidedal2,
+		Architectures: []types.Architecture{f.Architecture},
+		MemorySize:    optionalInt32(f.MemorySize),
+		Timeout:       optionalInt32(f.Timeout),
+		Environment:   environment(f.Environment),
+	}
+
+	resp, err := api.CreateFunction(c, createArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = WaitForUpdate(c, api, f.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeployResult{
+		FunctionArn: aws.ToString(resp.FunctionArn),
+		CodeSha256:  aws.ToString(resp.CodeSha256),
+		Created:     true,
+	}, nil
+}
+
 func main() {
-	zipFile := flag.String("z", "", "The name of the ZIP file (without the .zip extension)")
-	bucket := flag.String("b", "", "the name of bucket to which the ZIP file is uploaded")
+	handlerDir := flag.String("d", "", "The directory of the Go handler package")
 	function := flag.String("f", "", "The name of the Lambda function")
-	packageClass := flag.String("p", "", "The name of the package.class handling the call")
-	roleARN := flag.String("r", "", "The ARN of the role that calls the function")
+	roleARN := flag.String("r", "", "The ARN of the role that the function assumes (required to create the function)")
+	bucket := flag.String("b", "", "The bucket to upload the ZIP file to (default: send the ZIP file with the request)")
+	zipFile := flag.String("z", "", "The name of the ZIP file, without the .zip extension (default: the function name)")
+	arch := flag.String("a", "arm64", "The architecture to build for: arm64 or amd64")
+	memory := flag.Int("m", 0, "The memory in MB (default: 128 when creating, unchanged when updating)")
+	timeout := flag.Int("t", 0, "The timeout in seconds (default: 3 when creating, unchanged when updating)")
+	dryRun := flag.Bool("dry-run", false, "Build the ZIP file and write it locally instead of deploying it")
+
+	env := envFlag{}
+	flag.Var(env, "e", "A KEY=VALUE environment variable for the function (repeatable; replaces all of the function's variables)")
 
 	flag.Parse()
 
-	if *zipFile == "" || *bucket == "" || *function == "" || *packageClass == "" || *roleARN == "" {
-		fmt.Println("You must supply a zip file name, bucket name, function name, handler, and role ARN:")
-		fmt.Println("-z ZIPFILE -b BUCKET -f FUNCTION -p PACKAGE.CLASS -r ROLE-ARN")
+	if *handlerDir == "" || *function == "" {
+		fmt.Println("You must supply a handler directory and function name:")
+		fmt.Println("-d DIRECTORY -f FUNCTION [-r ROLE-ARN] [-b BUCKET] [-z ZIPFILE] [-a arm64|amd64] [-m MEMORY] [-t TIMEOUT] [-e KEY=VALUE]... [-dry-run]")
 		return
 	}
 
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+	architecture, ok := architectures[*arch]
+	if !ok {
+		fmt.Println("Unsupported architecture " + *arch + "; use arm64 or amd64")
+		return
+	}
+
+	if *zipFile == "" {
+		*zipFile = *function
+	}
+
+	tmpDir, err := ioutil.TempDir("", "CreateLambdaFunction")
 	if err != nil {
-		fmt.Println("Got an error loading the configuration")
+		fmt.Println("Could not create a temporary directory: " + err.Error())
 		return
 	}
 
-	client := lambda.NewFromConfig(cfg)
+	defer os.RemoveAll(tmpDir)
 
-	contents, err := ioutil.ReadFile(*zipFile + ".zip")
+	binary, err := BuildBootstrap(*handlerDir, *arch, tmpDir)
 	if err != nil {
-		fmt.Println("Could not read " + *zipFile + ".zip")
+		fmt.Println(err.Error())
 		return
 	}
 
-	createCode := &types.FunctionCode{
-		S3Bucket:        bucket,
-		S3Key:           zipFile,
-		S3ObjectVersion: aws.String(""),
-		ZipFile:         contents,
+	contents, err := ioutil.ReadFile(binary)
+	if err != nil {
+		fmt.Println("Could not read " + binary)
+		return
 	}
 
-	createArgs := &lambda.CreateFunctionInput{
-		Code:         createCode,
-		FunctionName: function,
-		Handler:      packageClass,
-		Role:         roleARN,
-		Runtime:      types.RuntimeGo1x,
+	zipped, err := ZipBootstrap(contents)
+	if err != nil {
+		fmt.Println("Could not create the ZIP file: " + err.Error())
+		return
+	}
+
+	if *dryRun {
+		err = ioutil.WriteFile(*zipFile+".zip", zipped, 0644)
+		if err != nil {
+			fmt.Println("Could not write " + *zipFile + ".zip")
+			return
+		}
+
+		fmt.Println("Wrote " + *zipFile + ".zip (" + strconv.Itoa(len(zipped)) + " bytes) for linux/" + *arch)
+		fmt.Println("CodeSha256: " + CodeSha256(zipped))
+		return
 	}
 
-	result, err := client.CreateFunction(context.Background(), createArgs)
+	cfg, err := config.LoadDefaultConfig(context.TODO())
 	if err != nil {
-		fmt.Println("Cannot create function: " + err.Error())
+		fmt.Println("Got an error loading the configuration")
 		return
 	}
 
-	fmt.Println(result)
+	code := Code{ZipFile: zipped}
+
+	if *bucket != "" {
+		key := *zipFile + ".zip"
+
+		err = UploadZip(context.TODO(), s3.NewFromConfig(cfg), *bucket, key, zipped)
+		if err != nil {
+			fmt.Println("Could not upload " + key + " to " + *bucket + ": " + err.Error())
+			return
+		}
+
+		fmt.Println("Uploaded " + key + " to " + *bucket)
+
+		code = Code{S3Bucket: *bucket, S3Key: key}
+	}
+
+	f := Function{
+		Name:         *function,
+		Role:         *roleARN,
+		Architecture: architecture,
+		MemorySize:   int32(*memory),
+		Timeout:      int32(*timeout),
+	}
+
+	if len(env) > 0 {
+		f.Environment = env
+	}
+
+	result, err := DeployFunction(context.TODO(), lambda.NewFromConfig(cfg), f, code)
+	if err != nil {
+		fmt.Println("Cannot deploy function: " + err.Error())
+		return
+	}
+
+	if result.Created {
+		fmt.Println("Created " + result.FunctionArn)
+	} else {
+		fmt.Println("Updated " + result.FunctionArn)
+	}
+
+	fmt.Println("CodeSha256: " + result.CodeSha256)
 }
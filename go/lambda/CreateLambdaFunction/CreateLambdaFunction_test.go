@@ -0,0 +1,245 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// mockLambdaClient records the calls it gets.
+// The function exists if exists is true; each update finishes after one poll.
+type mockLambdaClient struct {
+	exists bool
+	polls  int
+	calls  []string
+	create *lambda.CreateFunctionInput
+	code   *lambda.UpdateFunctionCodeInput
+	config *lambda.UpdateFunctionConfigurationInput
+}
+
+func (m *mockLambdaClient) GetFunction(ctx context.Context, params *lambda.GetFunctionInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionOutput, error) {
+	m.calls = append(m.calls, "GetFunction")
+
+	if !m.exists {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("Function not found")}
+	}
+
+	return &lambda.GetFunctionOutput{}, nil
+}
+
+func (m *mockLambdaClient) GetFunctionConfiguration(ctx context.Context, params *lambda.GetFunctionConfigurationInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionConfigurationOutput, error) {
+	m.polls++
+
+	if m.polls%2 == 1 {
+		return &lambda.GetFunctionConfigurationOutput{
+			State:            types.StateActive,
+			LastUpdateStatus: types.LastUpdateStatusInProgress,
+		}, nil
+	}
+
+	return &lambda.GetFunctionConfigurationOutput{
+		State:            types.StateActive,
+		LastUpdateStatus: types.LastUpdateStatusSuccessful,
+	}, nil
+}
+
+func (m *mockLambdaClient) CreateFunction(ctx context.Context, params *lambda.CreateFunctionInput, optFns ...func(*lambda.Options)) (*lambda.CreateFunctionOutput, error) {
+	m.calls = append(m.calls, "CreateFunction")
+	m.create = params
+
+	return &lambda.CreateFunctionOutput{
+		FunctionArn: aws.String("arn:aws:lambda:us-west-2:123456789012:function:" + *params.FunctionName),
+	}, nil
+}
+
+func (m *mockLambdaClient) UpdateFunctionCode(ctx context.Context, params *lambda.UpdateFunctionCodeInput, optFns ...func(*lambda.Options)) (*lambda.UpdateFunctionCodeOutput, error) {
+	m.calls = append(m.calls, "UpdateFunctionCode")
+	m.code = params
+
+	return &lambda.UpdateFunctionCodeOutput{
+		FunctionArn: aws.String("arn:aws:lambda:us-west-2:123456789012:function:" + *params.FunctionName),
+	}, nil
+}
+
+func (m *mockLambdaClient) UpdateFunctionConfiguration(ctx context.Context, params *lambda.UpdateFunctionConfigurationInput, optFns ...func(*lambda.Options)) (*lambda.UpdateFunctionConfigurationOutput, error) {
+	m.calls = append(m.calls, "UpdateFunctionConfiguration")
+	m.config = params
+
+	return &lambda.UpdateFunctionConfigurationOutput{}, nil
+}
+
+func TestZipBootstrap(t *testing.T) {
+	zipped, err := ZipBootstrap([]byte("binary"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(zipped), int64(len(zipped)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.File) != 1 || r.File[0].Name != "bootstrap" {
+		t.Fatalf("Expected only bootstrap in the ZIP file, got %d files", len(r.File))
+	}
+
+	if r.File[0].Mode().Perm()&0111 == 0 {
+		t.Errorf("Expected bootstrap to be executable, got mode %v", r.File[0].Mode())
+	}
+
+	f, err := r.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	contents, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(contents) != "binary" {
+		t.Errorf("Got contents %q", contents)
+	}
+
+	again, err := ZipBootstrap([]byte("binary"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if CodeSha256(again) != CodeSha256(zipped) {
+		t.Error("Expected the same binary to give the same code SHA")
+	}
+}
+
+func TestEnvFlag(t *testing.T) {
+	env := envFlag{}
+
+	err := env.Set("TABLE=entities")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Set("FILTER=a=b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if env["TABLE"] != "entities" || env["FILTER"] != "a=b" {
+		t.Errorf("Got %v", env)
+	}
+
+	err = env.Set("TABLE")
+	if err == nil {
+		t.Error("Expected an error for a variable without a value")
+	}
+}
+
+func TestDeployFunctionCreates(t *testing.T) {
+	pollInterval = 0
+
+	client := &mockLambdaClient{}
+
+	f := Function{
+		Name:         "HandleS3Event",
+		Role:         "arn:aws:iam::123456789012:role/lambda",
+		Architecture: types.ArchitectureArm64,
+		MemorySize:   256,
+		Environment:  map[string]string{"TABLE": "entities"},
+	}
+
+	result, err := DeployFunction(context.Background(), client, f, Code{S3Bucket: "bucket", S3Key: "HandleS3Event.zip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.Created {
+		t.Error("Expected the function to be created")
+	}
+
+	in := client.create
+	if in == nil {
+		t.Fatal("CreateFunction wasn't called")
+	}
+
+	if in.Runtime != types.RuntimeProvidedal2 || aws.ToString(in.Handler) != "bootstrap" {
+		t.Errorf("Got runtime %s and handler %s", in.Runtime, aws.ToString(in.Handler))
+	}
+
+	if len(in.Architectures) != 1 || in.Architectures[0] != types.ArchitectureArm64 {
+		t.Errorf("Got architectures %v", in.Architectures)
+	}
+
+	if aws.ToString(in.Code.S3Key) != "HandleS3Event.zip" || in.Code.ZipFile != nil {
+		t.Error("Expected the code to come from the bucket")
+	}
+
+	if in.MemorySize == nil || *in.MemorySize != 256 || in.Timeout != nil {
+		t.Error("Expected memory size 256 and the default timeout")
+	}
+
+	if in.Environment == nil || in.Environment.Variables["TABLE"] != "entities" {
+		t.Error("Expected the TABLE environment variable")
+	}
+
+	f.Role = ""
+
+	_, err = DeployFunction(context.Background(), &mockLambdaClient{}, f, Code{ZipFile: []byte("zip")})
+	if err == nil {
+		t.Error("Expected an error creating a function without a role")
+	}
+}
+
+func TestDeployFunctionUpdates(t *testing.T) {
+	pollInterval = 0
+
+	client := &mockLambdaClient{exists: true}
+
+	f := Function{
+		Name:         "HandleS3Event",
+		Architecture: types.ArchitectureX8664,
+		Timeout:      10,
+	}
+
+	result, err := DeployFunction(context.Background(), client, f, Code{ZipFile: []byte("zip")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Created {
+		t.Error("Expected the function to be updated")
+	}
+
+	want := []string{"GetFunction", "UpdateFunctionConfiguration", "UpdateFunctionCode"}
+
+	if len(client.calls) != len(want) {
+		t.Fatalf("Got calls %v, want %v", client.calls, want)
+	}
+
+	for i := range want {
+		if client.calls[i] != want[i] {
+			t.Fatalf("Got calls %v, want %v", client.calls, want)
+		}
+	}
+
+	if client.config.Role != nil || client.config.MemorySize != nil || client.config.Environment != nil {
+		t.Error("Expected the role, memory size, and environment to be left alone")
+	}
+
+	if client.config.Timeout == nil || *client.config.Timeout != 10 {
+		t.Error("Expected timeout 10")
+	}
+
+	if string(client.code.ZipFile) != "zip" || client.code.Architectures[0] != types.ArchitectureX8664 {
+		t.Error("Expected the x86_64 ZIP file")
+	}
+}
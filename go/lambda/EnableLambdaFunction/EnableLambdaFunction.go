@@ -4,20 +4,522 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
+// LambdaTriggerAPI defines the interface for the Lambda functions used to wire up and remove triggers
+type LambdaTriggerAPI interface {
+	GetFunction(ctx context.Context,
+		params *lambda.GetFunctionInput,
+		optFns ...func(*lambda.Options)) (*lambda.GetFunctionOutput, error)
+
+	AddPermission(ctx context.Context,
+		params *lambda.AddPermissionInput,
+		optFns ...func(*lambda.Options)) (*lambda.AddPermissionOutput, error)
+
+	RemovePermission(ctx context.Context,
+		params *lambda.RemovePermissionInput,
+		optFns ...func(*lambda.Options)) (*lambda.RemovePermissionOutput, error)
+
+	ListEventSourceMappings(ctx context.Context,
+		params *lambda.ListEventSourceMappingsInput,
+		optFns ...func(*lambda.Options)) (*lambda.ListEventSourceMappingsOutput, error)
+
+	CreateEventSourceMapping(ctx context.Context,
+		params *lambda.CreateEventSourceMappingInput,
+		optFns ...func(*lambda.Options)) (*lambda.CreateEventSourceMappingOutput, error)
+
+	UpdateEventSourceMapping(ctx context.Context,
+		params *lambda.UpdateEventSourceMappingInput,
+		optFns ...func(*lambda.Options)) (*lambda.UpdateEventSourceMappingOutput, error)
+
+	DeleteEventSourceMapping(ctx context.Context,
+		params *lambda.DeleteEventSourceMappingInput,
+		optFns ...func(*lambda.Options)) (*lambda.DeleteEventSourceMappingOutput, error)
+}
+
+// S3NotificationAPI defines the interface for the bucket notification functions
+type S3NotificationAPI interface {
+	GetBucketNotificationConfiguration(ctx context.Context,
+		params *s3.GetBucketNotificationConfigurationInput,
+		optFns ...func(*s3.Options)) (*s3.GetBucketNotificationConfigurationOutput, error)
+
+	PutBucketNotificationConfiguration(ctx context.Context,
+		params *s3.PutBucketNotificationConfigurationInput,
+		optFns ...func(*s3.Options)) (*s3.PutBucketNotificationConfigurationOutput, error)
+}
+
+// SNSSubscriptionAPI defines the interface for the topic subscription functions
+type SNSSubscriptionAPI interface {
+	Subscribe(ctx context.Context,
+		params *sns.SubscribeInput,
+		optFns ...func(*sns.Options)) (*sns.SubscribeOutput, error)
+
+	ListSubscriptionsByTopic(ctx context.Context,
+		params *sns.ListSubscriptionsByTopicInput,
+		optFns ...func(*sns.Options)) (*sns.ListSubscriptionsByTopicOutput, error)
+
+	Unsubscribe(ctx context.Context,
+		params *sns.UnsubscribeInput,
+		optFns ...func(*sns.Options)) (*sns.UnsubscribeOutput, error)
+}
+
+// DynamoDBDescribeTableAPI defines the interface for the DescribeTable function
+type DynamoDBDescribeTableAPI interface {
+	DescribeTable(ctx context.Context,
+		params *dynamodb.DescribeTableInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+}
+
+// Trigger is the source that invokes the function
+type Trigger struct {
+	Service     string // dynamodb, s3, sns, or sqs
+	SourceArn   string // the ARN of the table, bucket, topic, or queue
+	FunctionArn string
+	Account     string
+	// S3 events, such as s3:ObjectCreated:*
+	Events []string
+	// Batch size of an SQS or DynamoDB event source mapping; 0 uses the Lambda default
+	BatchSize int32
+	// Where a new DynamoDB event source mapping starts reading the stream
+	StartingPosition types.EventSourcePosition
+}
+
+// StatementIds can only contain these characters
+var statementChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// SourceArn returns the ARN of resource in service
+func SourceArn(service, region, accountID, resource string) (string, error) {
+	switch service {
+	case "dynamodb":
+		// A DynamoDB table ARN looks like:
+		//     arn:aws:dynamodb:REGION:ACCOUNT-ID:table/TABLE-NAME
+		return "arn:aws:dynamodb:" + region + ":" + accountID + ":table/" + resource, nil
+	case "s3":
+		// A bucket ARN looks like:
+		//     arn:aws:s3:::BUCKET-NAME
+		return "arn:aws:s3:::" + resource, nil
+	case "sns":
+		// An SNS topic ARN looks like:
+		//     arn:aws:sns:REGION:ACCOUNT-ID:TOPIC-NAME
+		return "arn:aws:sns:" + region + ":" + accountID + ":" + resource, nil
+	case "sqs":
+		// An SQS queue ARN looks like:
+		//    arn:aws:sqs:REGION:ACCOUNT-ID:QUEUE-NAME
+		return "arn:aws:sqs:" + region + ":" + accountID + ":" + resource, nil
+	}
+
+	return "", errors.New("Cannot create triggers for service " + service)
+}
+
+// StatementID returns the ID of the policy statement that lets sourceArn invoke the function.
+// It's the same every time for the same source and different for each source,
+// so rerunning doesn't conflict and enabling a second source doesn't replace the first.
+func StatementID(service, sourceArn string) string {
+	sum := sha256.Sum256([]byte(sourceArn))
+
+	// The name is only for people reading the policy; the hash makes the ID unique
+	name := sourceArn[strings.LastIndexAny(sourceArn, ":/")+1:]
+	name = statementChars.ReplaceAllString(name, "_")
+
+	if len(name) > 64 {
+		name = name[:64]
+	}
+
+	return "lambda_" + service + "_" + name + "_" + hex.EncodeToString(sum[:6])
+}
+
+// AddInvokePermission lets the trigger's service invoke the function.
+// It does nothing if the permission already exists.
+func AddInvokePermission(c context.Context, api LambdaTriggerAPI, t Trigger) error {
+	input := &lambda.AddPermissionInput{
+		Action:       aws.String("lambda:InvokeFunction"),
+		FunctionName: aws.String(t.FunctionArn),
+		Principal:    aws.String(t.Service + ".amazonaws.com"),
+		SourceArn:    aws.String(t.SourceArn),
+		StatementId:  aws.String(StatementID(t.Service, t.SourceArn)),
+	}
+
+	// Bucket ARNs don't include the account, so make sure it's our bucket
+	if t.Service == "s3" {
+		input.SourceAccount = aws.String(t.Account)
+	}
+
+	_, err := api.AddPermission(c, input)
+
+	var conflict *types.ResourceConflictException
+	if errors.As(err, &conflict) {
+		return nil
+	}
+
+	return err
+}
+
+// RemoveInvokePermission removes the permission AddInvokePermission adds.
+// It does nothing if there isn't one.
+func RemoveInvokePermission(c context.Context, api LambdaTriggerAPI, t Trigger) error {
+	_, err := api.RemovePermission(c, &lambda.RemovePermissionInput{
+		FunctionName: aws.String(t.FunctionArn),
+		StatementId:  aws.String(StatementID(t.Service, t.SourceArn)),
+	})
+
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return nil
+	}
+
+	return err
+}
+
+// bucketName returns the bucket in a bucket ARN
+func bucketName(bucketArn string) string {
+	return strings.TrimPrefix(bucketArn, "arn:aws:s3:::")
+}
+
+// notificationID is the ID of the bucket notification that invokes the function
+func notificationID(t Trigger) string {
+	return StatementID("s3", t.SourceArn+"|"+t.FunctionArn)
+}
+
+// withoutNotification returns the bucket notification configuration without the one for t
+func withoutNotification(resp *s3.GetBucketNotificationConfigurationOutput, t Trigger) *s3types.NotificationConfiguration {
+	cfg := &s3types.NotificationConfiguration{
+		QueueConfigurations: resp.QueueConfigurations,
+		TopicConfigurations: resp.TopicConfigurations,
+	}
+
+	for _, lc := range resp.LambdaFunctionConfigurations {
+		if aws.ToString(lc.Id) == notificationID(t) {
+			continue
+		}
+
+		cfg.LambdaFunctionConfigurations = append(cfg.LambdaFunctionConfigurations, lc)
+	}
+
+	return cfg
+}
+
+// EnableS3 sends the events from the bucket to the function,
+// replacing the notification from an earlier run and keeping every other one
+func EnableS3(c context.Context, api S3NotificationAPI, t Trigger) error {
+	bucket := bucketName(t.SourceArn)
+
+	resp, err := api.GetBucketNotificationConfiguration(c, &s3.GetBucketNotificationConfigurationInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		return err
+	}
+
+	cfg := withoutNotification(resp, t)
+
+	events := []s3types.Event{}
+	for _, e := range t.Events {
+		events = append(events, s3types.Event(e))
+	}
+
+	cfg.LambdaFunctionConfigurations = append(cfg.LambdaFunctionConfigurations, s3types.LambdaFunctionConfiguration{
+		Id:                aws.String(notificationID(t)),
+		LambdaFunctionArn: aws.String(t.FunctionArn),
+		Events:            events,
+	})
+
+	_, err = api.PutBucketNotificationConfiguration(c, &s3.PutBucketNotificationConfigurationInput{
+		Bucket:                    aws.String(bucket),
+		NotificationConfiguration: cfg,
+	})
+
+	return err
+}
+
+// DisableS3 removes the notification EnableS3 adds
+func DisableS3(c context.Context, api S3NotificationAPI, t Trigger) error {
+	bucket := bucketName(t.SourceArn)
+
+	resp, err := api.GetBucketNotificationConfiguration(c, &s3.GetBucketNotificationConfigurationInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		return err
+	}
+
+	cfg := withoutNotification(resp, t)
+	if len(cfg.LambdaFunctionConfigurations) == len(resp.LambdaFunctionConfigurations) {
+		return nil
+	}
+
+	_, err = api.PutBucketNotificationConfiguration(c, &s3.PutBucketNotificationConfigurationInput{
+		Bucket:                    aws.String(bucket),
+		NotificationConfiguration: cfg,
+	})
+
+	return err
+}
+
+// EnableSNS subscribes the function to the topic.
+// Amazon SNS returns the existing subscription if there is one.
+func EnableSNS(c context.Context, api SNSSubscriptionAPI, t Trigger) (string, error) {
+	resp, err := api.Subscribe(c, &sns.SubscribeInput{
+		TopicArn:              aws.String(t.SourceArn),
+		Protocol:              aws.String("lambda"),
+		Endpoint:              aws.String(t.FunctionArn),
+		ReturnSubscriptionArn: true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.ToString(resp.SubscriptionArn), nil
+}
+
+// DisableSNS unsubscribes the function from the topic
+func DisableSNS(c context.Context, api SNSSubscriptionAPI, t Trigger) error {
+	input := &sns.ListSubscriptionsByTopicInput{
+		TopicArn: aws.String(t.SourceArn),
+	}
+
+	for {
+		resp, err := api.ListSubscriptionsByTopic(c, input)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range resp.Subscriptions {
+			if aws.ToString(s.Protocol) != "lambda" || aws.ToString(s.Endpoint) != t.FunctionArn {
+				continue
+			}
+
+			_, err = api.Unsubscribe(c, &sns.UnsubscribeInput{
+				SubscriptionArn: s.SubscriptionArn,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if aws.ToString(resp.NextToken) == "" {
+			return nil
+		}
+
+		input.NextToken = resp.NextToken
+	}
+}
+
+// StreamArn returns the ARN of the table's stream
+func StreamArn(c context.Context, api DynamoDBDescribeTableAPI, tableArn string) (string, error) {
+	table := tableArn[strings.LastIndex(tableArn, "/")+1:]
+
+	resp, err := api.DescribeTable(c, &dynamodb.DescribeTableInput{
+		TableName: aws.String(table),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if resp.Table == nil || aws.ToString(resp.Table.LatestStreamArn) == "" {
+		return "", errors.New("Table " + table + " doesn't have a stream; enable one with NEW_AND_OLD_IMAGES")
+	}
+
+	return aws.ToString(resp.Table.LatestStreamArn), nil
+}
+
+// eventSourceMappings returns the mappings from the trigger's source to the function
+func eventSourceMappings(c context.Context, api LambdaTriggerAPI, t Trigger) ([]types.EventSourceMappingConfiguration, error) {
+	mappings := []types.EventSourceMappingConfiguration{}
+
+	input := &lambda.ListEventSourceMappingsInput{
+		EventSourceArn: aws.String(t.SourceArn),
+		FunctionName:   aws.String(t.FunctionArn),
+	}
+
+	for {
+		resp, err := api.ListEventSourceMappings(c, input)
+		if err != nil {
+			return nil, err
+		}
+
+		mappings = append(mappings, resp.EventSourceMappings...)
+
+		if aws.ToString(resp.NextMarker) == "" {
+			return mappings, nil
+		}
+
+		input.Marker = resp.NextMarker
+	}
+}
+
+// optionalInt32 returns nil for zero so Lambda uses the default
+func optionalInt32(n int32) *int32 {
+	if n == 0 {
+		return nil
+	}
+
+	return aws.Int32(n)
+}
+
+// EnableMapping creates an event source mapping from the trigger's queue or stream to the function,
+// or updates the one that exists. The handlers report batch item failures,
+// so the mapping only retries the messages or records that failed.
+func EnableMapping(c context.Context, api LambdaTriggerAPI, t Trigger) (string, error) {
+	mappings, err := eventSourceMappings(c, api, t)
+	if err != nil {
+		return "", err
+	}
+
+	responseTypes := []types.FunctionResponseType{types.FunctionResponseTypeReportBatchItemFailures}
+
+	if len(mappings) > 0 {
+		resp, err := api.UpdateEventSourceMapping(c, &lambda.UpdateEventSourceMappingInput{
+			UUID:                  mappings[0].UUID,
+			FunctionName:          aws.String(t.FunctionArn),
+			BatchSize:             optionalInt32(t.BatchSize),
+			Enabled:               aws.Bool(true),
+			FunctionResponseTypes: responseTypes,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		return aws.ToString(resp.UUID), nil
+	}
+
+	input := &lambda.CreateEventSourceMappingInput{
+		EventSourceArn:        aws.String(t.SourceArn),
+		FunctionName:          aws.String(t.FunctionArn),
+		BatchSize:             optionalInt32(t.BatchSize),
+		Enabled:               aws.Bool(true),
+		FunctionResponseTypes: responseTypes,
+	}
+
+	// Queues don't have a starting position
+	if t.Service == "dynamodb" {
+		input.StartingPosition = t.StartingPosition
+	}
+
+	resp, err := api.CreateEventSourceMapping(c, input)
+	if err != nil {
+		return "", err
+	}
+
+	return aws.ToString(resp.UUID), nil
+}
+
+// DisableMapping deletes every event source mapping from the trigger's queue or stream to the function
+func DisableMapping(c context.Context, api LambdaTriggerAPI, t Trigger) error {
+	mappings, err := eventSourceMappings(c, api, t)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range mappings {
+		_, err = api.DeleteEventSourceMapping(c, &lambda.DeleteEventSourceMappingInput{
+			UUID: m.UUID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Clients are the service clients main needs
+type Clients struct {
+	Lambda   LambdaTriggerAPI
+	S3       S3NotificationAPI
+	SNS      SNSSubscriptionAPI
+	DynamoDB DynamoDBDescribeTableAPI
+}
+
+// Enable creates or updates the trigger and returns a description of it
+func Enable(c context.Context, clients Clients, t Trigger) (string, error) {
+	switch t.Service {
+	case "s3":
+		// Amazon S3 checks that it can invoke the function when we add the notification
+		err := AddInvokePermission(c, clients.Lambda, t)
+		if err != nil {
+			return "", err
+		}
+
+		err = EnableS3(c, clients.S3, t)
+		if err != nil {
+			return "", err
+		}
+
+		return "Bucket notification " + notificationID(t), nil
+	case "sns":
+		err := AddInvokePermission(c, clients.Lambda, t)
+		if err != nil {
+			return "", err
+		}
+
+		arn, err := EnableSNS(c, clients.SNS, t)
+		if err != nil {
+			return "", err
+		}
+
+		return "Subscription " + arn, nil
+	case "dynamodb", "sqs":
+		// Lambda reads from queues and streams with the function's role, so there's no permission to add
+		uuid, err := EnableMapping(c, clients.Lambda, t)
+		if err != nil {
+			return "", err
+		}
+
+		return "Event source mapping " + uuid, nil
+	}
+
+	return "", errors.New("Cannot create triggers for service " + t.Service)
+}
+
+// Disable removes the trigger and the permission Enable adds
+func Disable(c context.Context, clients Clients, t Trigger) error {
+	switch t.Service {
+	case "s3":
+		err := DisableS3(c, clients.S3, t)
+		if err != nil {
+			return err
+		}
+
+		return RemoveInvokePermission(c, clients.Lambda, t)
+	case "sns":
+		err := DisableSNS(c, clients.SNS, t)
+		if err != nil {
+			return err
+		}
+
+		return RemoveInvokePermission(c, clients.Lambda, t)
+	case "dynamodb", "sqs":
+		return DisableMapping(c, clients.Lambda, t)
+	}
+
+	return errors.New("Cannot remove triggers for service " + t.Service)
+}
+
 func main() {
-	// Args for AddPermissionInput
-	service := flag.String("s", "", "The service that sends notifications to Lambda")
+	service := flag.String("s", "", "The service that sends notifications to Lambda: dynamodb, s3, sns, or sqs")
 	function := flag.String("f", "", "The name of the Lambda function that's called")
-	resource := flag.String("r", "", "The name of the resource that sends a notification to Lambda")
+	resource := flag.String("r", "", "The name of the table, bucket, topic, or queue that sends a notification to Lambda")
+	events := flag.String("e", "s3:ObjectCreated:*", "Comma-separated S3 events that invoke the function")
+	batchSize := flag.Int("b", 0, "The batch size of an SQS or DynamoDB event source mapping (default: the Lambda default)")
+	position := flag.String("p", "LATEST", "Where a new DynamoDB event source mapping starts reading: LATEST or TRIM_HORIZON")
+	remove := flag.Bool("remove", false, "Remove the trigger and permission instead of creating them")
 	// -s SERVICE, so when SERVICE == s3                               -> Principal: "s3.amazonaws.com"
 	// -f FUNCTION                                                     -> FunctionName: "function"
 	// -r RESOURCE, so when SERVICE == "s3" AND RESOURCE == "mybucket" -> SourceArn: "arn:aws:s3:::mybucket"
@@ -29,108 +531,79 @@ func main() {
 		return
 	}
 
-	// Create Lambda service client
 	cfg, err := config.LoadDefaultConfig(context.TODO())
 	if err != nil {
+		fmt.Println("Got an error loading the configuration")
 		return
 	}
 
-	client := lambda.NewFromConfig(cfg)
-
-	permArgs := &lambda.AddPermissionInput{}
-
-	/* For service, this app only supports:
-	    	   DynamoDB (table)
-		       S3 (bucket)
-		       SNS (topic)
-		       SQS (queue)
-	*/
+	clients := Clients{
+		Lambda:   lambda.NewFromConfig(cfg),
+		S3:       s3.NewFromConfig(cfg),
+		SNS:      sns.NewFromConfig(cfg),
+		DynamoDB: dynamodb.NewFromConfig(cfg),
+	}
 
 	// Get default region and account ID (to build ARNs):
-	region := cfg.Region
-
-	stsClient := sts.NewFromConfig(cfg)
-
-	input := &sts.GetCallerIdentityInput{}
-
-	resp, err := stsClient.GetCallerIdentity(context.Background(), input)
+	resp, err := sts.NewFromConfig(cfg).GetCallerIdentity(context.TODO(), &sts.GetCallerIdentityInput{})
 	if err != nil {
-		fmt.Println("Got error snarfing caller identity:")
+		fmt.Println("Got error getting caller identity:")
 		fmt.Println(err.Error())
 		return
 	}
 
-	accountID := *resp.Account
-	stsClient = nil
+	accountID := aws.ToString(resp.Account)
 
-	switch *service {
-	case "dynamodb":
-		// A DynamoDB table ARN looks like:
-		//     arn:aws:dynamodb:REGION:ACCOUNT-ID:table/TABLE-NAME
-		tableARN := "arn:aws:dynamodb:" + region + ":" + accountID + ":table/" + *resource
-
-		permArgs = &lambda.AddPermissionInput{
-			Action:       aws.String("lambda:InvokeFunction"),
-			FunctionName: function,
-			Principal:    aws.String(*service + ".amazonaws.com"),
-			SourceArn:    aws.String(tableARN),
-			StatementId:  aws.String("lambda_dynamodb_notification"),
-		}
-
-		break
-	case "s3":
-		// A bucket ARN looks like:
-		//     arn:aws:s3:::BUCKET-NAME
-		bucketARN := "arn:aws:s3:::" + *resource
+	sourceArn, err := SourceArn(*service, cfg.Region, accountID, *resource)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
 
-		permArgs = &lambda.AddPermissionInput{
-			Action:       aws.String("lambda:InvokeFunction"),
-			FunctionName: function,
-			Principal:    aws.String(*service + ".amazonaws.com"),
-			SourceArn:    aws.String(bucketARN),
-			StatementId:  aws.String("lambda_s3_notification"),
-		}
+	fn, err := clients.Lambda.GetFunction(context.TODO(), &lambda.GetFunctionInput{
+		FunctionName: function,
+	})
+	if err != nil {
+		fmt.Println("Cannot get function " + *function + ": " + err.Error())
+		return
+	}
 
-		break
-	case "sns":
-		// An SNS topic ARN looks like:
-		//     arn:aws:sns:REGION:ACCOUNT-ID:TOPIC-NAME
-		topicARN := "arn:aws:sns:" + region + ":" + accountID + ":" + *resource
+	t := Trigger{
+		Service:          *service,
+		SourceArn:        sourceArn,
+		FunctionArn:      aws.ToString(fn.Configuration.FunctionArn),
+		Account:          accountID,
+		Events:           strings.Split(*events, ","),
+		BatchSize:        int32(*batchSize),
+		StartingPosition: types.EventSourcePosition(*position),
+	}
 
-		permArgs = &lambda.AddPermissionInput{
-			Action:       aws.String("lambda:InvokeFunction"),
-			FunctionName: function,
-			Principal:    aws.String(*service + ".amazonaws.com"),
-			SourceArn:    aws.String(topicARN),
-			StatementId:  aws.String("lambda_sns_notification"),
+	// Event source mappings read from the table's stream, not the table
+	if t.Service == "dynamodb" {
+		t.SourceArn, err = StreamArn(context.TODO(), clients.DynamoDB, sourceArn)
+		if err != nil {
+			fmt.Println("Cannot get the stream of " + *resource + ": " + err.Error())
+			return
 		}
+	}
 
-		break
-
-	case "sqs":
-		// An SQS queue ARN looks like:
-		//    arn:aws:sqs:REGION:ACCOUNT-ID:QUEUE-NAME
-		queueARN := "arn:aws:sqs:" + region + ":" + accountID + ":" + *resource
-
-		permArgs = &lambda.AddPermissionInput{
-			Action:       aws.String("lambda:InvokeFunction"),
-			FunctionName: function,
-			Principal:    aws.String(*service + ".amazonaws.com"),
-			SourceArn:    aws.String(queueARN),
-			StatementId:  aws.String("lambda_sqs_notification"),
+	if *remove {
+		err = Disable(context.TODO(), clients, t)
+		if err != nil {
+			fmt.Println("Cannot remove the trigger: " + err.Error())
+			return
 		}
 
-		break
-	default:
-		fmt.Println("Cannot create permissions for service " + *service)
+		fmt.Println("Removed the " + *service + " trigger from " + *resource + " to " + *function)
 		return
 	}
 
-	result, err := client.AddPermission(context.Background(), permArgs)
+	description, err := Enable(context.TODO(), clients, t)
 	if err != nil {
-		fmt.Println("Cannot configure function for notifications")
+		fmt.Println("Cannot configure function for notifications: " + err.Error())
 		return
 	}
 
-	fmt.Println(result)
+	fmt.Println("Enabled the " + *service + " trigger from " + *resource + " to " + *function)
+	fmt.Println(description)
 }
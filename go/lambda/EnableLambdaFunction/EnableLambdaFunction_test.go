@@ -0,0 +1,290 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// mockLambdaClient keeps the policy statements and event source mappings in memory
+// and behaves like Lambda when a statement already exists or doesn't exist
+type mockLambdaClient struct {
+	statements map[string]bool
+	mappings   []types.EventSourceMappingConfiguration
+	created    int
+	updated    int
+}
+
+func newMockLambdaClient() *mockLambdaClient {
+	return &mockLambdaClient{statements: map[string]bool{}}
+}
+
+func (m *mockLambdaClient) GetFunction(ctx context.Context, params *lambda.GetFunctionInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionOutput, error) {
+	return &lambda.GetFunctionOutput{}, nil
+}
+
+func (m *mockLambdaClient) AddPermission(ctx context.Context, params *lambda.AddPermissionInput, optFns ...func(*lambda.Options)) (*lambda.AddPermissionOutput, error) {
+	if m.statements[*params.StatementId] {
+		return nil, &types.ResourceConflictException{Message: aws.String("The statement id provided already exists")}
+	}
+
+	m.statements[*params.StatementId] = true
+
+	return &lambda.AddPermissionOutput{}, nil
+}
+
+func (m *mockLambdaClient) RemovePermission(ctx context.Context, params *lambda.RemovePermissionInput, optFns ...func(*lambda.Options)) (*lambda.RemovePermissionOutput, error) {
+	if !m.statements[*params.StatementId] {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("The statement id isn't found")}
+	}
+
+	delete(m.statements, *params.StatementId)
+
+	return &lambda.RemovePermissionOutput{}, nil
+}
+
+func (m *mockLambdaClient) ListEventSourceMappings(ctx context.Context, params *lambda.ListEventSourceMappingsInput, optFns ...func(*lambda.Options)) (*lambda.ListEventSourceMappingsOutput, error) {
+	resp := &lambda.ListEventSourceMappingsOutput{}
+
+	for _, mapping := range m.mappings {
+		if *mapping.EventSourceArn == *params.EventSourceArn && *mapping.FunctionArn == *params.FunctionName {
+			resp.EventSourceMappings = append(resp.EventSourceMappings, mapping)
+		}
+	}
+
+	return resp, nil
+}
+
+func (m *mockLambdaClient) CreateEventSourceMapping(ctx context.Context, params *lambda.CreateEventSourceMappingInput, optFns ...func(*lambda.Options)) (*lambda.CreateEventSourceMappingOutput, error) {
+	m.created++
+
+	mapping := types.EventSourceMappingConfiguration{
+		UUID:                  aws.String("uuid-" + strconv.Itoa(m.created)),
+		EventSourceArn:        params.EventSourceArn,
+		FunctionArn:           params.FunctionName,
+		BatchSize:             params.BatchSize,
+		FunctionResponseTypes: params.FunctionResponseTypes,
+		StartingPosition:      params.StartingPosition,
+		State:                 aws.String("Creating"),
+	}
+
+	m.mappings = append(m.mappings, mapping)
+
+	return &lambda.CreateEventSourceMappingOutput{
+		UUID:                  mapping.UUID,
+		EventSourceArn:        mapping.EventSourceArn,
+		FunctionArn:           mapping.FunctionArn,
+		BatchSize:             mapping.BatchSize,
+		FunctionResponseTypes: mapping.FunctionResponseTypes,
+		StartingPosition:      mapping.StartingPosition,
+		State:                 mapping.State,
+	}, nil
+}
+
+func (m *mockLambdaClient) UpdateEventSourceMapping(ctx context.Context, params *lambda.UpdateEventSourceMappingInput, optFns ...func(*lambda.Options)) (*lambda.UpdateEventSourceMappingOutput, error) {
+	m.updated++
+
+	for i, mapping := range m.mappings {
+		if *mapping.UUID == *params.UUID {
+			m.mappings[i].BatchSize = params.BatchSize
+			m.mappings[i].FunctionResponseTypes = params.FunctionResponseTypes
+			m.mappings[i].State = aws.String("Updating")
+
+			return &lambda.UpdateEventSourceMappingOutput{
+				UUID:                  m.mappings[i].UUID,
+				EventSourceArn:        m.mappings[i].EventSourceArn,
+				FunctionArn:           m.mappings[i].FunctionArn,
+				BatchSize:             m.mappings[i].BatchSize,
+				FunctionResponseTypes: m.mappings[i].FunctionResponseTypes,
+				StartingPosition:      m.mappings[i].StartingPosition,
+				State:                 m.mappings[i].State,
+			}, nil
+		}
+	}
+
+	return nil, &types.ResourceNotFoundException{}
+}
+
+func (m *mockLambdaClient) DeleteEventSourceMapping(ctx context.Context, params *lambda.DeleteEventSourceMappingInput, optFns ...func(*lambda.Options)) (*lambda.DeleteEventSourceMappingOutput, error) {
+	kept := []types.EventSourceMappingConfiguration{}
+
+	for _, mapping := range m.mappings {
+		if *mapping.UUID != *params.UUID {
+			kept = append(kept, mapping)
+		}
+	}
+
+	m.mappings = kept
+
+	return &lambda.DeleteEventSourceMappingOutput{}, nil
+}
+
+// mockS3Client keeps the notification configuration of one bucket
+type mockS3Client struct {
+	cfg s3types.NotificationConfiguration
+}
+
+func (m *mockS3Client) GetBucketNotificationConfiguration(ctx context.Context, params *s3.GetBucketNotificationConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketNotificationConfigurationOutput, error) {
+	return &s3.GetBucketNotificationConfigurationOutput{
+		LambdaFunctionConfigurations: m.cfg.LambdaFunctionConfigurations,
+		QueueConfigurations:          m.cfg.QueueConfigurations,
+		TopicConfigurations:          m.cfg.TopicConfigurations,
+	}, nil
+}
+
+func (m *mockS3Client) PutBucketNotificationConfiguration(ctx context.Context, params *s3.PutBucketNotificationConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutBucketNotificationConfigurationOutput, error) {
+	m.cfg = *params.NotificationConfiguration
+	return &s3.PutBucketNotificationConfigurationOutput{}, nil
+}
+
+func TestStatementID(t *testing.T) {
+	valid := regexp.MustCompile(`^[a-zA-Z0-9_-]{1,100}$`)
+
+	sources := []string{
+		"arn:aws:s3:::my.bucket",
+		"arn:aws:s3:::my_bucket",
+		"arn:aws:sns:us-west-2:123456789012:topic",
+		"arn:aws:sns:us-east-1:123456789012:topic",
+	}
+
+	seen := map[string]bool{}
+
+	for _, source := range sources {
+		id := StatementID("s3", source)
+
+		if !valid.MatchString(id) {
+			t.Errorf("Invalid statement ID %s", id)
+		}
+
+		if seen[id] {
+			t.Errorf("Got statement ID %s twice", id)
+		}
+
+		seen[id] = true
+
+		if StatementID("s3", source) != id {
+			t.Errorf("Expected the same statement ID for %s every time", source)
+		}
+	}
+}
+
+func TestEnableS3(t *testing.T) {
+	lambdaClient := newMockLambdaClient()
+
+	other := s3types.LambdaFunctionConfiguration{
+		Id:                aws.String("other"),
+		LambdaFunctionArn: aws.String("arn:aws:lambda:us-west-2:123456789012:function:other"),
+	}
+
+	s3Client := &mockS3Client{
+		cfg: s3types.NotificationConfiguration{
+			LambdaFunctionConfigurations: []s3types.LambdaFunctionConfiguration{other},
+			QueueConfigurations:          []s3types.QueueConfiguration{{Id: aws.String("queue")}},
+		},
+	}
+
+	clients := Clients{Lambda: lambdaClient, S3: s3Client}
+
+	trigger := Trigger{
+		Service:     "s3",
+		SourceArn:   "arn:aws:s3:::mybucket",
+		FunctionArn: "arn:aws:lambda:us-west-2:123456789012:function:HandleS3Event",
+		Account:     "123456789012",
+		Events:      []string{"s3:ObjectCreated:*"},
+	}
+
+	// Running twice must not conflict or add a second notification
+	for i := 0; i < 2; i++ {
+		_, err := Enable(context.Background(), clients, trigger)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(lambdaClient.statements) != 1 {
+		t.Errorf("Expected one permission, got %d", len(lambdaClient.statements))
+	}
+
+	if len(s3Client.cfg.LambdaFunctionConfigurations) != 2 || len(s3Client.cfg.QueueConfigurations) != 1 {
+		t.Fatalf("Expected our notification plus the existing ones, got %v", s3Client.cfg)
+	}
+
+	err := Disable(context.Background(), clients, trigger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lambdaClient.statements) != 0 {
+		t.Error("Expected the permission to be removed")
+	}
+
+	if len(s3Client.cfg.LambdaFunctionConfigurations) != 1 || *s3Client.cfg.LambdaFunctionConfigurations[0].Id != "other" {
+		t.Errorf("Expected only the other notification to remain, got %v", s3Client.cfg.LambdaFunctionConfigurations)
+	}
+
+	// Removing again does nothing
+	err = Disable(context.Background(), clients, trigger)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnableMapping(t *testing.T) {
+	lambdaClient := newMockLambdaClient()
+	clients := Clients{Lambda: lambdaClient}
+
+	trigger := Trigger{
+		Service:          "dynamodb",
+		SourceArn:        "arn:aws:dynamodb:us-west-2:123456789012:table/entities/stream/2021-12-27T00:00:00.000",
+		FunctionArn:      "arn:aws:lambda:us-west-2:123456789012:function:HandleDynamoDBEvent",
+		StartingPosition: types.EventSourcePositionLatest,
+	}
+
+	_, err := Enable(context.Background(), clients, trigger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trigger.BatchSize = 50
+
+	_, err = Enable(context.Background(), clients, trigger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lambdaClient.created != 1 || lambdaClient.updated != 1 || len(lambdaClient.mappings) != 1 {
+		t.Fatalf("Expected one mapping created then updated, got %d created, %d updated", lambdaClient.created, lambdaClient.updated)
+	}
+
+	mapping := lambdaClient.mappings[0]
+
+	if aws.ToInt32(mapping.BatchSize) != 50 {
+		t.Errorf("Expected batch size 50, got %d", aws.ToInt32(mapping.BatchSize))
+	}
+
+	if len(mapping.FunctionResponseTypes) != 1 || mapping.FunctionResponseTypes[0] != types.FunctionResponseTypeReportBatchItemFailures {
+		t.Error("Expected the mapping to report batch item failures")
+	}
+
+	if len(lambdaClient.statements) != 0 {
+		t.Error("Expected no permission for a stream")
+	}
+
+	err = Disable(context.Background(), clients, trigger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lambdaClient.mappings) != 0 {
+		t.Error("Expected the mapping to be deleted")
+	}
+}
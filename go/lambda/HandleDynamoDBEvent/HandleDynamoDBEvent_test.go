@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// failProcessor fails every REMOVE record and counts the records it sees
+type failProcessor struct {
+	seen *int
+}
+
+func (p failProcessor) ProcessRecord(ctx context.Context, record events.DynamoDBEventRecord) error {
+	*p.seen++
+
+	if record.EventName == "REMOVE" {
+		return errors.New("could not process record")
+	}
+
+	return nil
+}
+
+func TestProcessRecords(t *testing.T) {
+	event := events.DynamoDBEvent{
+		Records: []events.DynamoDBEventRecord{
+			{EventName: "INSERT", Change: events.DynamoDBStreamRecord{SequenceNumber: "100"}},
+			{EventName: "REMOVE", Change: events.DynamoDBStreamRecord{SequenceNumber: "200"}},
+			{EventName: "MODIFY", Change: events.DynamoDBStreamRecord{SequenceNumber: "300"}},
+		},
+	}
+
+	seen := 0
+	resp := processRecords(context.Background(), failProcessor{seen: &seen}, event)
+
+	if len(resp.BatchItemFailures) != 1 || resp.BatchItemFailures[0].ItemIdentifier != "200" {
+		t.Errorf("Expected a checkpoint at sequence number 200, got %v", resp.BatchItemFailures)
+	}
+
+	if seen != 2 {
+		t.Errorf("Expected processing to stop after the failed record, but %d records were processed", seen)
+	}
+}
@@ -5,30 +5,32 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"log"
-	"os"
+	"errors"
+	"strconv"
 
+	"github.com/Doug-AWS/code-examples/go/lambda/clients"
+	"github.com/Doug-AWS/code-examples/go/lambda/lambdalog"
 	"github.com/aws/aws-lambda-go/events"
 	runtime "github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-lambda-go/lambdacontext"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
 )
 
 func init() {
-	_, _ = callLambda()
+	// Create any clients listed in warmUpClients, which doesn't call AWS.
+	err := clients.WarmUpFromEnv(context.Background())
+	if err != nil {
+		lambdalog.New(context.Background()).Error("Could not warm up clients", err, nil)
+	}
 }
 
-func callLambda() (string, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+func callLambda(ctx context.Context) (string, error) {
+	client, err := clients.Lambda(ctx)
 	if err != nil {
 		return "", err
 	}
 
-	client := lambda.NewFromConfig(cfg)
-
 	input := &lambda.GetAccountSettingsInput{}
-	resp, err := client.GetAccountSettings(context.Background(), input)
+	resp, err := client.GetAccountSettings(ctx, input)
 	if err != nil {
 		return "", err
 	}
@@ -38,37 +40,74 @@ func callLambda() (string, error) {
 	return string(output), err
 }
 
-func handleRequest(ctx context.Context, event events.SQSEvent) (string, error) {
-	// event
-	eventJSON, _ := json.MarshalIndent(event, "", "  ")
-	log.Printf("EVENT: %s", eventJSON)
+// RecordProcessor processes one record from an events.S3Event.
+// Set processor to your own implementation.
+type RecordProcessor interface {
+	ProcessRecord(ctx context.Context, record events.S3EventRecord) error
+}
+
+// logProcessor is the default RecordProcessor; it logs each record
+type logProcessor struct{}
+
+func (p logProcessor) ProcessRecord(ctx context.Context, record events.S3EventRecord) error {
+	lambdalog.FromContext(ctx).Info("Got record", lambdalog.Fields{
+		"eventName": record.EventName,
+		"bucket":    record.S3.Bucket.Name,
+		"key":       record.S3.Object.Key,
+	})
+	return nil
+}
 
-	// environment variables
-	log.Printf("REGION: %s", os.Getenv("AWS_REGION"))
-	log.Println("ALL ENV VARS:")
+var processor RecordProcessor = logProcessor{}
 
-	for _, element := range os.Environ() {
-		log.Println(element)
+// processRecords sends every record to p.
+// S3 invocations are asynchronous, so Lambda retries the whole event
+// if we return an error; we process every record before returning.
+func processRecords(ctx context.Context, p RecordProcessor, event events.S3Event) error {
+	failed := 0
+
+	for _, record := range event.Records {
+		err := p.ProcessRecord(ctx, record)
+		if err != nil {
+			lambdalog.FromContext(ctx).Error("Could not process record", err, lambdalog.Fields{"key": record.S3.Object.Key})
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return errors.New(strconv.Itoa(failed) + " of " + strconv.Itoa(len(event.Records)) + " records failed")
 	}
 
-	// request context
-	lc, _ := lambdacontext.FromContext(ctx)
-	log.Printf("REQUEST ID: %s", lc.AwsRequestID)
+	return nil
+}
+
+func handleRequest(ctx context.Context, event events.S3Event) error {
+	logger := lambdalog.New(ctx)
+	ctx = lambdalog.NewContext(ctx, logger)
 
-	// global variable
-	log.Printf("FUNCTION NAME: %s", lambdacontext.FunctionName)
+	defer logger.Done()
 
 	// context method
 	deadline, _ := ctx.Deadline()
-	log.Printf("DEADLINE: %s", deadline)
+
+	// event
+	logger.Info("Got event", lambdalog.Fields{
+		"event":    event,
+		"deadline": deadline,
+	})
+
+	// environment variables, with the values of any we didn't set redacted
+	logger.Info("Environment", lambdalog.Fields{"env": lambdalog.Environment()})
 
 	// AWS SDK call
-	usage, err := callLambda()
+	usage, err := callLambda(ctx)
 	if err != nil {
-		return "ERROR", err
+		logger.Error("Could not get account settings", err, nil)
+	} else {
+		logger.Info("Got account usage", lambdalog.Fields{"usage": json.RawMessage(usage)})
 	}
 
-	return usage, nil
+	return processRecords(ctx, processor, event)
 }
 
 func main() {
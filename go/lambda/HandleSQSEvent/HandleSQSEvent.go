@@ -5,30 +5,41 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
 	"os"
+	"strconv"
 
+	"github.com/Doug-AWS/code-examples/go/lambda/clients"
+	"github.com/Doug-AWS/code-examples/go/lambda/lambdalog"
 	"github.com/aws/aws-lambda-go/events"
 	runtime "github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-lambda-go/lambdacontext"
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 )
 
 func init() {
-	_, _ = callLambda()
+	// Create any clients listed in warmUpClients, which doesn't call AWS.
+	err := clients.WarmUpFromEnv(context.Background())
+	if err != nil {
+		lambdalog.New(context.Background()).Error("Could not warm up clients", err, nil)
+	}
+
+	batchOptions, err = newBatchOptions()
+	if err != nil {
+		lambdalog.New(context.Background()).Error("Could not configure poison-message handling", err, nil)
+	}
 }
 
-func callLambda() (string, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+func callLambda(ctx context.Context) (string, error) {
+	client, err := clients.Lambda(ctx)
 	if err != nil {
 		return "", err
 	}
 
-	client := lambda.NewFromConfig(cfg)
-
 	input := &lambda.GetAccountSettingsInput{}
-	resp, err := client.GetAccountSettings(context.Background(), input)
+	resp, err := client.GetAccountSettings(ctx, input)
 	if err != nil {
 		return "", err
 	}
@@ -38,37 +49,203 @@ func callLambda() (string, error) {
 	return string(output), err
 }
 
-func handleRequest(ctx context.Context, event events.SQSEvent) (string, error) {
-	// event
-	eventJSON, _ := json.MarshalIndent(event, "", "  ")
-	log.Printf("EVENT: %s", eventJSON)
+// MessageProcessor processes one message from an events.SQSEvent.
+// Set processor to your own implementation.
+type MessageProcessor interface {
+	ProcessMessage(ctx context.Context, message events.SQSMessage) error
+}
+
+// logProcessor is the default MessageProcessor; it logs each message
+type logProcessor struct{}
+
+func (p logProcessor) ProcessMessage(ctx context.Context, message events.SQSMessage) error {
+	lambdalog.FromContext(ctx).Info("Got message", lambdalog.Fields{
+		"messageId":      message.MessageId,
+		"eventSourceArn": message.EventSourceARN,
+		"body":           message.Body,
+	})
+	return nil
+}
+
+var processor MessageProcessor = logProcessor{}
+
+// DeadLetterHandler receives a failed message that has reached
+// BatchOptions.MaxReceiveCount.
+// If it returns nil, the message is deleted from the queue;
+// otherwise it stays on the queue as a batch item failure.
+type DeadLetterHandler interface {
+	HandleDeadLetter(ctx context.Context, message events.SQSMessage, cause error) error
+}
+
+// logDeadLetterHandler is the default DeadLetterHandler.
+// It logs the message and leaves it on the queue,
+// so the redrive policy of the queue can move it.
+type logDeadLetterHandler struct{}
+
+func (h logDeadLetterHandler) HandleDeadLetter(ctx context.Context, message events.SQSMessage, cause error) error {
+	lambdalog.FromContext(ctx).Error("Got poison message", cause, lambdalog.Fields{
+		"messageId":    message.MessageId,
+		"receiveCount": receiveCount(message),
+	})
+	return cause
+}
+
+// queueDeadLetterHandler sends the message to another queue
+type queueDeadLetterHandler struct {
+	queueURL string
+}
+
+func (h queueDeadLetterHandler) HandleDeadLetter(ctx context.Context, message events.SQSMessage, cause error) error {
+	client, err := clients.SQS(ctx)
+	if err != nil {
+		return err
+	}
+
+	input := &sqs.SendMessageInput{
+		QueueUrl:    aws.String(h.queueURL),
+		MessageBody: aws.String(message.Body),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"SourceMessageId": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(message.MessageId),
+			},
+			"SourceQueueArn": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(message.EventSourceARN),
+			},
+			"Error": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(cause.Error()),
+			},
+		},
+	}
+
+	_, err = client.SendMessage(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	lambdalog.FromContext(ctx).Info("Sent poison message to dead-letter queue", lambdalog.Fields{
+		"messageId": message.MessageId,
+		"queueUrl":  h.queueURL,
+	})
 
-	// environment variables
-	log.Printf("REGION: %s", os.Getenv("AWS_REGION"))
-	log.Println("ALL ENV VARS:")
+	return nil
+}
+
+// BatchOptions configure what processMessages does with failed messages
+type BatchOptions struct {
+	// MaxReceiveCount is the number of receives after which a failed message
+	// goes to DeadLetterHandler; 0 turns off poison-message detection
+	MaxReceiveCount   int
+	DeadLetterHandler DeadLetterHandler
+}
+
+// batchOptions are set from the maxReceiveCount and deadLetterQueueUrl
+// environment variables by newBatchOptions
+var batchOptions BatchOptions
+
+// newBatchOptions creates BatchOptions from the environment.
+// If deadLetterQueueUrl is set, poison messages are sent to that queue;
+// otherwise they are logged.
+func newBatchOptions() (BatchOptions, error) {
+	opts := BatchOptions{
+		MaxReceiveCount:   3,
+		DeadLetterHandler: logDeadLetterHandler{},
+	}
+
+	count := os.Getenv("maxReceiveCount")
+	if count != "" {
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return opts, errors.New("Could not parse maxReceiveCount '" + count + "': " + err.Error())
+		}
+
+		opts.MaxReceiveCount = n
+	}
 
-	for _, element := range os.Environ() {
-		log.Println(element)
+	queueURL := os.Getenv("deadLetterQueueUrl")
+	if queueURL != "" {
+		opts.DeadLetterHandler = queueDeadLetterHandler{
+			queueURL: queueURL,
+		}
 	}
 
-	// request context
-	lc, _ := lambdacontext.FromContext(ctx)
-	log.Printf("REQUEST ID: %s", lc.AwsRequestID)
+	return opts, nil
+}
 
-	// global variable
-	log.Printf("FUNCTION NAME: %s", lambdacontext.FunctionName)
+// receiveCount returns the ApproximateReceiveCount attribute of message
+func receiveCount(message events.SQSMessage) int {
+	n, err := strconv.Atoi(message.Attributes["ApproximateReceiveCount"])
+	if err != nil {
+		return 1
+	}
+
+	return n
+}
+
+// processMessages sends every message to p and returns the IDs of those that failed.
+// A failed message that has been received opts.MaxReceiveCount times
+// is a poison message; it goes to opts.DeadLetterHandler instead,
+// and is only reported as failed if the handler fails too.
+// The event source mapping must include ReportBatchItemFailures in its
+// FunctionResponseTypes, otherwise Lambda ignores the response
+// and deletes the whole batch.
+func processMessages(ctx context.Context, p MessageProcessor, opts BatchOptions, event events.SQSEvent) events.SQSEventResponse {
+	resp := events.SQSEventResponse{
+		BatchItemFailures: []events.SQSBatchItemFailure{},
+	}
+
+	for _, message := range event.Records {
+		err := p.ProcessMessage(ctx, message)
+		if err == nil {
+			continue
+		}
+
+		lambdalog.FromContext(ctx).Error("Could not process message", err, lambdalog.Fields{"messageId": message.MessageId})
+
+		if opts.MaxReceiveCount > 0 && opts.DeadLetterHandler != nil && receiveCount(message) >= opts.MaxReceiveCount {
+			err = opts.DeadLetterHandler.HandleDeadLetter(ctx, message, err)
+			if err == nil {
+				continue
+			}
+		}
+
+		resp.BatchItemFailures = append(resp.BatchItemFailures, events.SQSBatchItemFailure{
+			ItemIdentifier: message.MessageId,
+		})
+	}
+
+	return resp
+}
+
+func handleRequest(ctx context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
+	logger := lambdalog.New(ctx)
+	ctx = lambdalog.NewContext(ctx, logger)
+
+	defer logger.Done()
 
 	// context method
 	deadline, _ := ctx.Deadline()
-	log.Printf("DEADLINE: %s", deadline)
+
+	// event
+	logger.Info("Got event", lambdalog.Fields{
+		"event":    event,
+		"deadline": deadline,
+	})
+
+	// environment variables, with the values of any we didn't set redacted
+	logger.Info("Environment", lambdalog.Fields{"env": lambdalog.Environment()})
 
 	// AWS SDK call
-	usage, err := callLambda()
+	usage, err := callLambda(ctx)
 	if err != nil {
-		return "ERROR", err
+		logger.Error("Could not get account settings", err, nil)
+	} else {
+		logger.Info("Got account usage", lambdalog.Fields{"usage": json.RawMessage(usage)})
 	}
 
-	return usage, nil
+	return processMessages(ctx, processor, batchOptions, event), nil
 }
 
 func main() {
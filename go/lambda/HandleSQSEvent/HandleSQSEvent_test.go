@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// failProcessor fails every message whose body is "fail"
+type failProcessor struct{}
+
+func (p failProcessor) ProcessMessage(ctx context.Context, message events.SQSMessage) error {
+	if message.Body == "fail" {
+		return errors.New("could not process message")
+	}
+
+	return nil
+}
+
+func TestProcessMessages(t *testing.T) {
+	event := events.SQSEvent{
+		Records: []events.SQSMessage{
+			{MessageId: "1", Body: "ok"},
+			{MessageId: "2", Body: "fail"},
+			{MessageId: "3", Body: "ok"},
+			{MessageId: "4", Body: "fail"},
+		},
+	}
+
+	opts := BatchOptions{
+		MaxReceiveCount:   3,
+		DeadLetterHandler: logDeadLetterHandler{},
+	}
+
+	resp := processMessages(context.Background(), failProcessor{}, opts, event)
+
+	if len(resp.BatchItemFailures) != 2 {
+		t.Fatalf("Expected 2 batch item failures, got %d", len(resp.BatchItemFailures))
+	}
+
+	if resp.BatchItemFailures[0].ItemIdentifier != "2" || resp.BatchItemFailures[1].ItemIdentifier != "4" {
+		t.Errorf("Expected failures for messages 2 and 4, got %v", resp.BatchItemFailures)
+	}
+}
+
+// recordingHandler records the messages sent to the dead-letter handler
+type recordingHandler struct {
+	ids *[]string
+}
+
+func (h recordingHandler) HandleDeadLetter(ctx context.Context, message events.SQSMessage, cause error) error {
+	*h.ids = append(*h.ids, message.MessageId)
+	return nil
+}
+
+func TestPoisonMessages(t *testing.T) {
+	event := events.SQSEvent{
+		Records: []events.SQSMessage{
+			{MessageId: "1", Body: "fail", Attributes: map[string]string{"ApproximateReceiveCount": "1"}},
+			{MessageId: "2", Body: "fail", Attributes: map[string]string{"ApproximateReceiveCount": "3"}},
+			{MessageId: "3", Body: "ok", Attributes: map[string]string{"ApproximateReceiveCount": "5"}},
+		},
+	}
+
+	ids := []string{}
+	opts := BatchOptions{
+		MaxReceiveCount:   3,
+		DeadLetterHandler: recordingHandler{ids: &ids},
+	}
+
+	resp := processMessages(context.Background(), failProcessor{}, opts, event)
+
+	if len(ids) != 1 || ids[0] != "2" {
+		t.Errorf("Expected only message 2 to be dead-lettered, got %v", ids)
+	}
+
+	if len(resp.BatchItemFailures) != 1 || resp.BatchItemFailures[0].ItemIdentifier != "1" {
+		t.Errorf("Expected only message 1 to be reported as failed, got %v", resp.BatchItemFailures)
+	}
+}
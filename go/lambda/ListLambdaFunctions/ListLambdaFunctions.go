@@ -0,0 +1,437 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Doug-AWS/code-examples/go/lambda/lambdalog"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"gopkg.in/yaml.v2"
+)
+
+// LambdaInventoryAPI defines the interface for the Lambda functions used to list functions and their event sources
+type LambdaInventoryAPI interface {
+	ListFunctions(ctx context.Context,
+		params *lambda.ListFunctionsInput,
+		optFns ...func(*lambda.Options)) (*lambda.ListFunctionsOutput, error)
+
+	ListEventSourceMappings(ctx context.Context,
+		params *lambda.ListEventSourceMappingsInput,
+		optFns ...func(*lambda.Options)) (*lambda.ListEventSourceMappingsOutput, error)
+
+	GetPolicy(ctx context.Context,
+		params *lambda.GetPolicyInput,
+		optFns ...func(*lambda.Options)) (*lambda.GetPolicyOutput, error)
+}
+
+// Function describes a deployed function
+type Function struct {
+	Name         string
+	Runtime      string
+	Architecture string
+	MemorySize   int32
+	Timeout      int32
+	LastModified string
+	CodeSha256   string
+	Environment  map[string]string
+	// The ARNs of the queues and streams the function reads,
+	// and of the buckets and topics allowed to invoke it
+	EventSources []string
+}
+
+// FunctionSpec is the configuration a function should have.
+// Only the fields that are set are checked.
+type FunctionSpec struct {
+	Name         string            `yaml:"name"`
+	Runtime      string            `yaml:"runtime"`
+	Architecture string            `yaml:"architecture"`
+	MemorySize   int32             `yaml:"memorySize"`
+	Timeout      int32             `yaml:"timeout"`
+	CodeSha256   string            `yaml:"codeSha256"`
+	Environment  map[string]string `yaml:"environment"`
+	EventSources []string          `yaml:"eventSources"`
+}
+
+// Spec is the desired configuration of the functions in the spec file:
+//
+//	functions:
+//	  - name: HandleSQSEvent
+//	    runtime: provided.al2
+//	    architecture: arm64
+//	    memorySize: 128
+//	    timeout: 10
+//	    environment:
+//	      maxReceiveCount: "3"
+//	    eventSources:
+//	      - arn:aws:sqs:us-west-2:123456789012:MyQueue
+type Spec struct {
+	Functions []FunctionSpec `yaml:"functions"`
+}
+
+// Drift is a difference between a function and its spec
+type Drift struct {
+	Function string
+	Field    string
+	Want     string
+	Got      string
+}
+
+// policy is the part of a function's resource-based policy that names the sources
+type policy struct {
+	Statement []struct {
+		Condition map[string]map[string]interface{}
+	}
+}
+
+// LoadSpec reads the spec file; unknown fields are an error so typos don't go unchecked
+func LoadSpec(path string) (*Spec, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec Spec
+
+	err = yaml.UnmarshalStrict(b, &spec)
+	if err != nil {
+		return nil, errors.New("Could not parse " + path + ": " + err.Error())
+	}
+
+	seen := map[string]bool{}
+
+	for _, f := range spec.Functions {
+		if f.Name == "" {
+			return nil, errors.New("Every function in " + path + " needs a name")
+		}
+
+		if seen[f.Name] {
+			return nil, errors.New("Function " + f.Name + " is in " + path + " more than once")
+		}
+
+		seen[f.Name] = true
+	}
+
+	return &spec, nil
+}
+
+// PolicySources returns the source ARNs in the function's resource-based policy,
+// which is where the permissions for S3 and SNS triggers are
+func PolicySources(c context.Context, api LambdaInventoryAPI, name string) ([]string, error) {
+	resp, err := api.GetPolicy(c, &lambda.GetPolicyInput{
+		FunctionName: aws.String(name),
+	})
+	if err != nil {
+		// A function without a policy doesn't have any
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var p policy
+
+	err = json.Unmarshal([]byte(aws.ToString(resp.Policy)), &p)
+	if err != nil {
+		return nil, errors.New("Could not parse the policy of " + name + ": " + err.Error())
+	}
+
+	sources := []string{}
+
+	for _, s := range p.Statement {
+		for _, values := range s.Condition {
+			arn, ok := values["AWS:SourceArn"].(string)
+			if ok {
+				sources = append(sources, arn)
+			}
+		}
+	}
+
+	return sources, nil
+}
+
+// MappingSources returns the ARNs of the queues and streams the function reads
+func MappingSources(c context.Context, api LambdaInventoryAPI, name string) ([]string, error) {
+	sources := []string{}
+
+	input := &lambda.ListEventSourceMappingsInput{
+		FunctionName: aws.String(name),
+	}
+
+	for {
+		resp, err := api.ListEventSourceMappings(c, input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range resp.EventSourceMappings {
+			sources = append(sources, aws.ToString(m.EventSourceArn))
+		}
+
+		if aws.ToString(resp.NextMarker) == "" {
+			return sources, nil
+		}
+
+		input.Marker = resp.NextMarker
+	}
+}
+
+// newFunction returns the details of the function in cfg
+func newFunction(cfg types.FunctionConfiguration) Function {
+	f := Function{
+		Name:         aws.ToString(cfg.FunctionName),
+		Runtime:      string(cfg.Runtime),
+		MemorySize:   aws.ToInt32(cfg.MemorySize),
+		Timeout:      aws.ToInt32(cfg.Timeout),
+		LastModified: aws.ToString(cfg.LastModified),
+		CodeSha256:   aws.ToString(cfg.CodeSha256),
+		Environment:  map[string]string{},
+	}
+
+	// Functions created before Lambda supported arm64 don't list an architecture
+	f.Architecture = string(types.ArchitectureX8664)
+	if len(cfg.Architectures) > 0 {
+		f.Architecture = string(cfg.Architectures[0])
+	}
+
+	if cfg.Environment != nil && cfg.Environment.Variables != nil {
+		f.Environment = cfg.Environment.Variables
+	}
+
+	return f
+}
+
+// Inventory returns every function, sorted by name, with its event sources
+func Inventory(c context.Context, api LambdaInventoryAPI) ([]Function, error) {
+	functions := []Function{}
+
+	paginator := lambda.NewListFunctionsPaginator(api, &lambda.ListFunctionsInput{})
+
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(c)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cfg := range resp.Functions {
+			f := newFunction(cfg)
+
+			mappings, err := MappingSources(c, api, f.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			permissions, err := PolicySources(c, api, f.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			f.EventSources = append(mappings, permissions...)
+			sort.Strings(f.EventSources)
+
+			functions = append(functions, f)
+		}
+	}
+
+	sort.Slice(functions, func(i, j int) bool { return functions[i].Name < functions[j].Name })
+
+	return functions, nil
+}
+
+// envValue returns the value of the environment variable to show in a drift report.
+// Environment variables often hold secrets, so unless lambdalog logs the value or it's named in allow,
+// it's replaced by the start of its SHA-256 hash, which still shows whether two values differ.
+func envValue(name, value string, allow []string) string {
+	if lambdalog.IsSafe(name, allow...) {
+		return value
+	}
+
+	sum := sha256.Sum256([]byte(value))
+
+	return "sha256:" + hex.EncodeToString(sum[:])[:12]
+}
+
+// CheckDrift compares the functions with the spec.
+// The values of environment variables are hidden unless they're named in allow.
+func CheckDrift(functions []Function, spec *Spec, allow ...string) []Drift {
+	deployed := map[string]Function{}
+	for _, f := range functions {
+		deployed[f.Name] = f
+	}
+
+	drifts := []Drift{}
+
+	for _, s := range spec.Functions {
+		f, ok := deployed[s.Name]
+		if !ok {
+			drifts = append(drifts, Drift{Function: s.Name, Field: "function", Want: "deployed", Got: "missing"})
+			continue
+		}
+
+		add := func(field, want, got string) {
+			if want != got {
+				drifts = append(drifts, Drift{Function: s.Name, Field: field, Want: want, Got: got})
+			}
+		}
+
+		if s.Runtime != "" {
+			add("runtime", s.Runtime, f.Runtime)
+		}
+
+		if s.Architecture != "" {
+			add("architecture", s.Architecture, f.Architecture)
+		}
+
+		if s.MemorySize != 0 {
+			add("memorySize", strconv.Itoa(int(s.MemorySize)), strconv.Itoa(int(f.MemorySize)))
+		}
+
+		if s.Timeout != 0 {
+			add("timeout", strconv.Itoa(int(s.Timeout)), strconv.Itoa(int(f.Timeout)))
+		}
+
+		if s.CodeSha256 != "" {
+			add("codeSha256", s.CodeSha256, f.CodeSha256)
+		}
+
+		if s.Environment != nil {
+			names := []string{}
+			for name := range s.Environment {
+				names = append(names, name)
+			}
+
+			for name := range f.Environment {
+				_, ok := s.Environment[name]
+				if !ok {
+					names = append(names, name)
+				}
+			}
+
+			sort.Strings(names)
+
+			for _, name := range names {
+				want := "<unset>"
+				value, ok := s.Environment[name]
+				if ok {
+					want = envValue(name, value, allow)
+				}
+
+				got := "<unset>"
+				value, ok = f.Environment[name]
+				if ok {
+					got = envValue(name, value, allow)
+				}
+
+				add("environment."+name, want, got)
+			}
+		}
+
+		if s.EventSources != nil {
+			want := append([]string{}, s.EventSources...)
+			sort.Strings(want)
+
+			add("eventSources", strings.Join(want, ","), strings.Join(f.EventSources, ","))
+		}
+	}
+
+	return drifts
+}
+
+// PrintInventory writes a table of the functions to w
+func PrintInventory(w io.Writer, functions []Function) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "NAME\tRUNTIME\tARCHITECTURE\tMEMORY\tTIMEOUT\tLAST MODIFIED\tCODE SHA256\tEVENT SOURCES")
+
+	for _, f := range functions {
+		sources := strings.Join(f.EventSources, ",")
+		if sources == "" {
+			sources = "-"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%s\t%s\t%s\n",
+			f.Name, f.Runtime, f.Architecture, f.MemorySize, f.Timeout, f.LastModified, f.CodeSha256, sources)
+	}
+
+	tw.Flush()
+}
+
+// PrintDrift writes a table of the drifts to w
+func PrintDrift(w io.Writer, drifts []Drift) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "FUNCTION\tFIELD\tWANT\tGOT")
+
+	for _, d := range drifts {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", d.Function, d.Field, d.Want, d.Got)
+	}
+
+	tw.Flush()
+}
+
+func main() {
+	specFile := flag.String("s", "", "A YAML file describing the configuration the functions should have")
+	allow := flag.String("a", "", "A comma-separated list of environment variables whose values can be shown in the drift report")
+	flag.Parse()
+
+	var spec *Spec
+
+	if *specFile != "" {
+		var err error
+
+		spec, err = LoadSpec(*specFile)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		fmt.Println("Got an error loading the configuration")
+		os.Exit(1)
+	}
+
+	functions, err := Inventory(context.TODO(), lambda.NewFromConfig(cfg))
+	if err != nil {
+		fmt.Println("Could not list functions: " + err.Error())
+		os.Exit(1)
+	}
+
+	PrintInventory(os.Stdout, functions)
+
+	if spec == nil {
+		return
+	}
+
+	drifts := CheckDrift(functions, spec, strings.Split(*allow, ",")...)
+	if len(drifts) == 0 {
+		fmt.Println()
+		fmt.Println("No drift from " + *specFile)
+		return
+	}
+
+	fmt.Println()
+	fmt.Println(strconv.Itoa(len(drifts)) + " differences from " + *specFile + ":")
+	PrintDrift(os.Stdout, drifts)
+
+	os.Exit(1)
+}
@@ -0,0 +1,163 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// mockLambdaClient returns two pages of functions.
+// HandleSQSEvent reads a queue and HandleS3Event has a policy for a bucket.
+type mockLambdaClient struct{}
+
+func (m *mockLambdaClient) ListFunctions(ctx context.Context, params *lambda.ListFunctionsInput, optFns ...func(*lambda.Options)) (*lambda.ListFunctionsOutput, error) {
+	if params.Marker == nil {
+		return &lambda.ListFunctionsOutput{
+			Functions: []types.FunctionConfiguration{{
+				FunctionName:  aws.String("HandleSQSEvent"),
+				Runtime:       types.RuntimeProvidedal2,
+				Architectures: []types.Architecture{types.ArchitectureArm64},
+				MemorySize:    aws.Int32(128),
+				Timeout:       aws.Int32(10),
+				CodeSha256:    aws.String("abc="),
+				Environment: &types.EnvironmentResponse{
+					Variables: map[string]string{"maxReceiveCount": "5"},
+				},
+			}},
+			NextMarker: aws.String("page2"),
+		}, nil
+	}
+
+	return &lambda.ListFunctionsOutput{
+		Functions: []types.FunctionConfiguration{{
+			FunctionName: aws.String("HandleS3Event"),
+			Runtime:      types.RuntimeGo1x,
+			MemorySize:   aws.Int32(128),
+			Timeout:      aws.Int32(3),
+		}},
+	}, nil
+}
+
+func (m *mockLambdaClient) ListEventSourceMappings(ctx context.Context, params *lambda.ListEventSourceMappingsInput, optFns ...func(*lambda.Options)) (*lambda.ListEventSourceMappingsOutput, error) {
+	resp := &lambda.ListEventSourceMappingsOutput{}
+
+	if *params.FunctionName == "HandleSQSEvent" {
+		resp.EventSourceMappings = []types.EventSourceMappingConfiguration{{
+			EventSourceArn: aws.String("arn:aws:sqs:us-west-2:123456789012:MyQueue"),
+		}}
+	}
+
+	return resp, nil
+}
+
+func (m *mockLambdaClient) GetPolicy(ctx context.Context, params *lambda.GetPolicyInput, optFns ...func(*lambda.Options)) (*lambda.GetPolicyOutput, error) {
+	if *params.FunctionName != "HandleS3Event" {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("The resource you requested does not exist.")}
+	}
+
+	return &lambda.GetPolicyOutput{
+		Policy: aws.String(`{"Version":"2012-10-17","Statement":[{"Sid":"lambda_s3_mybucket_0123456789ab","Effect":"Allow",` +
+			`"Principal":{"Service":"s3.amazonaws.com"},"Action":"lambda:InvokeFunction",` +
+			`"Condition":{"StringEquals":{"AWS:SourceAccount":"123456789012"},"ArnLike":{"AWS:SourceArn":"arn:aws:s3:::mybucket"}}}]}`),
+	}, nil
+}
+
+func TestInventory(t *testing.T) {
+	functions, err := Inventory(context.Background(), &mockLambdaClient{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(functions) != 2 || functions[0].Name != "HandleS3Event" || functions[1].Name != "HandleSQSEvent" {
+		t.Fatalf("Expected both functions sorted by name, got %v", functions)
+	}
+
+	if functions[0].Architecture != "x86_64" {
+		t.Errorf("Expected a function without architectures to be x86_64, got %s", functions[0].Architecture)
+	}
+
+	if !reflect.DeepEqual(functions[0].EventSources, []string{"arn:aws:s3:::mybucket"}) {
+		t.Errorf("Got event sources %v from the policy", functions[0].EventSources)
+	}
+
+	if !reflect.DeepEqual(functions[1].EventSources, []string{"arn:aws:sqs:us-west-2:123456789012:MyQueue"}) {
+		t.Errorf("Got event sources %v from the mappings", functions[1].EventSources)
+	}
+}
+
+func TestCheckDrift(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ListLambdaFunctions")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "functions.yaml")
+
+	err = ioutil.WriteFile(path, []byte(`functions:
+  - name: HandleSQSEvent
+    runtime: provided.al2
+    architecture: arm64
+    timeout: 10
+    environment:
+      maxReceiveCount: "3"
+    eventSources:
+      - arn:aws:sqs:us-west-2:123456789012:MyQueue
+  - name: HandleS3Event
+    runtime: provided.al2
+  - name: HandleSNSEvent
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec, err := LoadSpec(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	functions, err := Inventory(context.Background(), &mockLambdaClient{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := CheckDrift(functions, spec, "maxReceiveCount")
+	want := []Drift{
+		{Function: "HandleSQSEvent", Field: "environment.maxReceiveCount", Want: "3", Got: "5"},
+		{Function: "HandleS3Event", Field: "runtime", Want: "provided.al2", Got: "go1.x"},
+		{Function: "HandleSNSEvent", Field: "function", Want: "deployed", Got: "missing"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got drift %v, want %v", got, want)
+	}
+
+	// Values that aren't allowed are only shown as hashes
+	got = CheckDrift(functions, spec)
+	want[0].Want = "sha256:4e07408562be"
+	want[0].Got = "sha256:ef2d127de37b"
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got drift %v, want %v", got, want)
+	}
+
+	err = ioutil.WriteFile(path, []byte("functions:\n  - name: HandleSQSEvent\n    memory: 128\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = LoadSpec(path)
+	if err == nil {
+		t.Error("Expected an error for an unknown field")
+	}
+}
@@ -1,13 +1,12 @@
 package main
 
 import (
-  "os"
-  "log"
   "context"
   "encoding/json"
+  "github.com/Doug-AWS/code-examples/go/lambda/lambdalog"
   runtime "github.com/aws/aws-lambda-go/lambda"
   "github.com/aws/aws-lambda-go/events"
-  "github.com/aws/aws-lambda-go/lambdacontext"
+  "github.com/aws/aws-sdk-go/aws/request"
   "github.com/aws/aws-sdk-go/aws/session"
   "github.com/aws/aws-sdk-go/service/lambda"
 )
@@ -15,38 +14,37 @@ import (
 var client = lambda.New(session.New())
 
 func init() {
-  callLambda()
+  // Send the X-Ray trace header of the invocation with every request
+  client.Handlers.Build.PushBack(func(r *request.Request) {
+    traceID := lambdalog.TraceID(r.Context())
+    if traceID != "" {
+      r.HTTPRequest.Header.Set(lambdalog.TraceHeader, traceID)
+    }
+  })
 }
 
-func callLambda() (string, error) {
+func callLambda(ctx context.Context) (string, error) {
   input := &lambda.GetAccountSettingsInput{}
   req, resp := client.GetAccountSettingsRequest(input)
+  req.SetContext(ctx)
   err := req.Send()
   output, _ := json.Marshal(resp.AccountUsage)
   return string(output), err
 }
 
 func handleRequest(ctx context.Context, event events.SQSEvent) (string, error) {
-  // event
-  eventJson, _ := json.MarshalIndent(event, "", "  ")
-  log.Printf("EVENT: %s", eventJson)
-  // environment variables
-  log.Printf("REGION: %s", os.Getenv("AWS_REGION"))
-  log.Println("ALL ENV VARS:")
-  for _, element := range os.Environ() {
-    log.Println(element)
-  }
-  // request context
-  lc, _ := lambdacontext.FromContext(ctx)
-  log.Printf("REQUEST ID: %s", lc.AwsRequestID)
-  // global variable
-  log.Printf("FUNCTION NAME: %s", lambdacontext.FunctionName)
+  logger := lambdalog.New(ctx)
+  defer logger.Done()
   // context method
   deadline, _ := ctx.Deadline()
-  log.Printf("DEADLINE: %s", deadline)
+  // event
+  logger.Info("Got event", lambdalog.Fields{"event": event, "deadline": deadline})
+  // environment variables, with the values of any we didn't set redacted
+  logger.Info("Environment", lambdalog.Fields{"env": lambdalog.Environment()})
   // AWS SDK call
-  usage, err := callLambda()
+  usage, err := callLambda(ctx)
   if err != nil {
+    logger.Error("Could not get account settings", err, nil)
     return "ERROR", err
   }
   return usage, nil
@@ -0,0 +1,199 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package clients creates AWS SDK for Go V2 clients the first time a handler needs them,
+// and shares them between invocations, so handlers don't call AWS in init().
+// Calling AWS in init() slows down every cold start, and fails when there's no network,
+// such as when lambda-local runs a handler.
+//
+// The handlers in go/lambda only log what their AWS call returns,
+// so a failed call is logged and doesn't fail the records in the event.
+package clients
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Doug-AWS/code-examples/go/lambda/lambdalog"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// WarmUpEnv is the environment variable that lists the clients WarmUpFromEnv creates,
+// such as "lambda,sqs", or "all" for every registered client
+const WarmUpEnv = "warmUpClients"
+
+// Factory creates a client from the shared configuration
+type Factory func(cfg aws.Config) interface{}
+
+// Registry loads the configuration and creates each client once
+type Registry struct {
+	mu        sync.Mutex
+	cfg       *aws.Config
+	loadFns   []func(*config.LoadOptions) error
+	factories map[string]Factory
+	clients   map[string]interface{}
+}
+
+// Default is the registry the package-level functions use.
+// Its clients send the X-Ray trace header of the invocation with every request.
+var Default = New(config.WithAPIOptions([]func(*middleware.Stack) error{lambdalog.AddTraceHeader}))
+
+// New creates a Registry that loads the configuration with loadFns
+// and knows how to create lambda and sqs clients
+func New(loadFns ...func(*config.LoadOptions) error) *Registry {
+	r := &Registry{
+		loadFns:   loadFns,
+		factories: map[string]Factory{},
+		clients:   map[string]interface{}{},
+	}
+
+	r.Register("lambda", func(cfg aws.Config) interface{} { return lambda.NewFromConfig(cfg) })
+	r.Register("sqs", func(cfg aws.Config) interface{} { return sqs.NewFromConfig(cfg) })
+
+	return r
+}
+
+// Register tells r how to create the client called name
+func (r *Registry) Register(name string, factory Factory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.factories[name] = factory
+}
+
+// config loads the configuration the first time it's called; r.mu must be held
+func (r *Registry) config(ctx context.Context) (aws.Config, error) {
+	if r.cfg != nil {
+		return *r.cfg, nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, r.loadFns...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+
+	r.cfg = &cfg
+
+	return cfg, nil
+}
+
+// Config returns the shared configuration
+func (r *Registry) Config(ctx context.Context) (aws.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.config(ctx)
+}
+
+// Get returns the client called name, creating it if this is the first call
+func (r *Registry) Get(ctx context.Context, name string) (interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	client, ok := r.clients[name]
+	if ok {
+		return client, nil
+	}
+
+	factory, ok := r.factories[name]
+	if !ok {
+		return nil, errors.New("No client registered as " + name)
+	}
+
+	cfg, err := r.config(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client = factory(cfg)
+	r.clients[name] = client
+
+	return client, nil
+}
+
+// WarmUp creates the named clients now instead of on first use.
+// Creating a client doesn't call AWS, so this works offline.
+func (r *Registry) WarmUp(ctx context.Context, names ...string) error {
+	for _, name := range names {
+		_, err := r.Get(ctx, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WarmUpFromEnv creates the clients listed in the warmUpClients environment variable
+func (r *Registry) WarmUpFromEnv(ctx context.Context) error {
+	value := os.Getenv(WarmUpEnv)
+	if value == "" {
+		return nil
+	}
+
+	names := []string{}
+
+	if strings.TrimSpace(value) == "all" {
+		r.mu.Lock()
+		for name := range r.factories {
+			names = append(names, name)
+		}
+		r.mu.Unlock()
+
+		sort.Strings(names)
+
+		return r.WarmUp(ctx, names...)
+	}
+
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return r.WarmUp(ctx, names...)
+}
+
+// Lambda returns the shared Lambda client
+func (r *Registry) Lambda(ctx context.Context) (*lambda.Client, error) {
+	client, err := r.Get(ctx, "lambda")
+	if err != nil {
+		return nil, err
+	}
+
+	return client.(*lambda.Client), nil
+}
+
+// SQS returns the shared Amazon SQS client
+func (r *Registry) SQS(ctx context.Context) (*sqs.Client, error) {
+	client, err := r.Get(ctx, "sqs")
+	if err != nil {
+		return nil, err
+	}
+
+	return client.(*sqs.Client), nil
+}
+
+// Lambda returns the Lambda client from the default registry
+func Lambda(ctx context.Context) (*lambda.Client, error) {
+	return Default.Lambda(ctx)
+}
+
+// SQS returns the Amazon SQS client from the default registry
+func SQS(ctx context.Context) (*sqs.Client, error) {
+	return Default.SQS(ctx)
+}
+
+// WarmUpFromEnv creates the clients listed in warmUpClients in the default registry
+func WarmUpFromEnv(ctx context.Context) error {
+	return Default.WarmUpFromEnv(ctx)
+}
@@ -0,0 +1,85 @@
+package clients
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+type fakeClient struct {
+	region string
+}
+
+func TestGet(t *testing.T) {
+	r := New(config.WithRegion("us-west-2"))
+
+	created := 0
+
+	r.Register("fake", func(cfg aws.Config) interface{} {
+		created++
+		return &fakeClient{region: cfg.Region}
+	})
+
+	first, err := r.Get(context.Background(), "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := r.Get(context.Background(), "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != second || created != 1 {
+		t.Errorf("Expected one shared client, created %d", created)
+	}
+
+	_, err = r.Get(context.Background(), "missing")
+	if err == nil {
+		t.Error("Expected an error getting an unregistered client")
+	}
+}
+
+func TestWarmUpFromEnv(t *testing.T) {
+	r := New(config.WithRegion("us-west-2"))
+
+	created := 0
+
+	r.Register("fake", func(cfg aws.Config) interface{} {
+		created++
+		return &fakeClient{}
+	})
+
+	os.Setenv(WarmUpEnv, "fake, ")
+	defer os.Unsetenv(WarmUpEnv)
+
+	err := r.WarmUpFromEnv(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created != 1 {
+		t.Errorf("Expected warm-up to create the client, created %d", created)
+	}
+
+	os.Setenv(WarmUpEnv, "fake,missing")
+
+	err = r.WarmUpFromEnv(context.Background())
+	if err == nil {
+		t.Error("Expected an error warming up an unregistered client")
+	}
+
+	os.Setenv(WarmUpEnv, "all")
+
+	err = r.WarmUpFromEnv(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.clients) != 3 {
+		t.Errorf("Expected all three registered clients, got %d", len(r.clients))
+	}
+}
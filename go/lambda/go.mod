@@ -0,0 +1,18 @@
+module github.com/Doug-AWS/code-examples/go/lambda
+
+go 1.15
+
+require (
+	github.com/aws/aws-lambda-go v1.28.0
+	github.com/aws/aws-sdk-go v1.37.20
+	github.com/aws/aws-sdk-go-v2 v1.11.1
+	github.com/aws/aws-sdk-go-v2/config v1.10.0
+	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.8.0
+	github.com/aws/aws-sdk-go-v2/service/lambda v1.13.0
+	github.com/aws/aws-sdk-go-v2/service/s3 v1.19.0
+	github.com/aws/aws-sdk-go-v2/service/sns v1.11.0
+	github.com/aws/aws-sdk-go-v2/service/sqs v1.12.0
+	github.com/aws/aws-sdk-go-v2/service/sts v1.10.0
+	github.com/aws/smithy-go v1.9.0
+	gopkg.in/yaml.v2 v2.2.8
+)
@@ -0,0 +1,3 @@
+module mymain
+
+go 1.15
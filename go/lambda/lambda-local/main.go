@@ -0,0 +1,713 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// The go1.x runtime starts a handler with _LAMBDA_SERVER_PORT set
+// and calls it over net/rpc.
+// InvokeRequest and InvokeResponse mirror the types in
+// github.com/aws/aws-lambda-go/lambda/messages,
+// which gob matches by field name.
+
+// PingRequest is sent to Function.Ping
+type PingRequest struct{}
+
+// PingResponse is returned by Function.Ping
+type PingResponse struct{}
+
+// InvokeRequestTimestamp is the deadline of an invocation
+type InvokeRequestTimestamp struct {
+	Seconds int64
+	Nanos   int64
+}
+
+// InvokeRequest is sent to Function.Invoke
+type InvokeRequest struct {
+	Payload               []byte
+	RequestId             string
+	XAmznTraceId          string
+	Deadline              InvokeRequestTimestamp
+	InvokedFunctionArn    string
+	CognitoIdentityId     string
+	CognitoIdentityPoolId string
+	ClientContext         []byte
+}
+
+// InvokeResponseError is the error returned by a handler
+type InvokeResponseError struct {
+	Message    string `json:"errorMessage"`
+	Type       string `json:"errorType"`
+	ShouldExit bool   `json:"-"`
+}
+
+// InvokeResponse is returned by Function.Invoke
+type InvokeResponse struct {
+	Payload []byte
+	Error   *InvokeResponseError
+}
+
+// Result is what we compare against a golden file
+type Result struct {
+	Event    string               `json:"event"`
+	Response json.RawMessage      `json:"response,omitempty"`
+	Error    *InvokeResponseError `json:"error,omitempty"`
+	Logs     []string             `json:"logs"`
+}
+
+// Options configure a local invocation
+type Options struct {
+	FunctionName string
+	RequestID    string
+	Region       string
+	Timeout      time.Duration
+	Env          []string
+	Masks        []*regexp.Regexp
+}
+
+// The log package prefixes each line with the date and time
+var logPrefix = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(\.\d+)? `)
+
+// The fields of lambdalog JSON lines that change from run to run
+var volatileFields = []string{"time", "durationMs", "deadline"}
+
+type multiFlag []string
+
+func (m *multiFlag) String() string {
+	return strings.Join(*m, ",")
+}
+
+func (m *multiFlag) Set(value string) error {
+	*m = append(*m, value)
+	return nil
+}
+
+// buildHandler compiles the handler package in dir for the local machine
+func buildHandler(dir, outDir string) (string, error) {
+	binary := filepath.Join(outDir, "handler")
+
+	cmd := exec.Command("go", "build", "-o", binary, ".")
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return "", errors.New("Could not build handler in " + dir + ": " + err.Error())
+	}
+
+	return binary, nil
+}
+
+// freePort asks the kernel for an unused TCP port
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// dial waits until the handler is listening on addr
+func dial(addr string, timeout time.Duration) (*rpc.Client, error) {
+	end := time.Now().Add(timeout)
+
+	for {
+		client, err := rpc.Dial("tcp", addr)
+		if err == nil {
+			return client, nil
+		}
+
+		if time.Now().After(end) {
+			return nil, errors.New("Handler did not start listening on " + addr + ": " + err.Error())
+		}
+
+		// Poll often so the wait doesn't inflate cold-start times
+		time.Sleep(2 * time.Millisecond)
+	}
+}
+
+// invoke sends payload to the handler that client is connected to
+func invoke(client *rpc.Client, payload []byte, opts Options) (*InvokeResponse, error) {
+	deadline := time.Now().Add(opts.Timeout)
+
+	req := &InvokeRequest{
+		Payload:   payload,
+		RequestId: opts.RequestID,
+		Deadline: InvokeRequestTimestamp{
+			Seconds: deadline.Unix(),
+			Nanos:   int64(deadline.Nanosecond()),
+		},
+		InvokedFunctionArn: "arn:aws:lambda:" + opts.Region + ":123456789012:function:" + opts.FunctionName,
+	}
+
+	var resp InvokeResponse
+
+	err := client.Call("Function.Invoke", req, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// handlerEnv is the environment the go1.x runtime gives a handler.
+// We don't pass through our own environment so logs are reproducible.
+func handlerEnv(port int, opts Options) []string {
+	env := []string{
+		"_LAMBDA_SERVER_PORT=" + strconv.Itoa(port),
+		"AWS_LAMBDA_FUNCTION_NAME=" + opts.FunctionName,
+		"AWS_LAMBDA_FUNCTION_VERSION=$LATEST",
+		"AWS_LAMBDA_FUNCTION_MEMORY_SIZE=128",
+		"AWS_LAMBDA_LOG_GROUP_NAME=/aws/lambda/" + opts.FunctionName,
+		"AWS_LAMBDA_LOG_STREAM_NAME=local",
+		"AWS_REGION=" + opts.Region,
+		"AWS_DEFAULT_REGION=" + opts.Region,
+		"PATH=/usr/local/bin:/usr/bin/:/bin:/opt/bin",
+		"HOME=" + os.Getenv("HOME"),
+	}
+
+	return append(env, opts.Env...)
+}
+
+// handlerProcess is a running handler binary
+type handlerProcess struct {
+	cmd    *exec.Cmd
+	client *rpc.Client
+	logs   bytes.Buffer
+}
+
+// startHandler starts binary and waits until it answers Function.Ping
+func startHandler(binary string, opts Options) (*handlerProcess, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+
+	h := &handlerProcess{
+		cmd: exec.Command(binary),
+	}
+
+	h.cmd.Env = handlerEnv(port, opts)
+	h.cmd.Stdout = &h.logs
+	h.cmd.Stderr = &h.logs
+
+	err = h.cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	h.client, err = dial("localhost:"+strconv.Itoa(port), 5*time.Second)
+	if err == nil {
+		err = h.client.Call("Function.Ping", &PingRequest{}, &PingResponse{})
+	}
+
+	if err != nil {
+		h.stop()
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// stop kills the handler and returns everything it logged
+func (h *handlerProcess) stop() string {
+	if h.client != nil {
+		h.client.Close()
+	}
+
+	_ = h.cmd.Process.Kill()
+	_ = h.cmd.Wait()
+
+	return h.logs.String()
+}
+
+// run starts binary, invokes it once with payload, and stops it
+func run(binary string, payload []byte, opts Options) (*InvokeResponse, string, error) {
+	h, err := startHandler(binary, opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := invoke(h.client, payload, opts)
+	logs := h.stop()
+
+	return resp, logs, err
+}
+
+// Timings are the results of benchmark
+type Timings struct {
+	// Cold is the time from starting the handler to the end of its first invocation,
+	// which includes process startup and init()
+	Cold time.Duration
+	// Warm are the times of the invocations after the first
+	Warm []time.Duration
+}
+
+// benchmark starts binary and invokes it 1 + warm times with payload
+func benchmark(binary string, payload []byte, warm int, opts Options) (*Timings, error) {
+	start := time.Now()
+
+	h, err := startHandler(binary, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	defer h.stop()
+
+	_, err = invoke(h.client, payload, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Timings{
+		Cold: time.Since(start),
+	}
+
+	for i := 0; i < warm; i++ {
+		start = time.Now()
+
+		_, err = invoke(h.client, payload, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		t.Warm = append(t.Warm, time.Since(start))
+	}
+
+	return t, nil
+}
+
+// summarize returns the minimum, mean, and maximum of durations
+func summarize(durations []time.Duration) (time.Duration, time.Duration, time.Duration) {
+	if len(durations) == 0 {
+		return 0, 0, 0
+	}
+
+	min, max, total := durations[0], durations[0], time.Duration(0)
+
+	for _, d := range durations {
+		if d < min {
+			min = d
+		}
+
+		if d > max {
+			max = d
+		}
+
+		total += d
+	}
+
+	return min, total / time.Duration(len(durations)), max
+}
+
+// milliseconds formats d as milliseconds with two decimal places
+func milliseconds(d time.Duration) string {
+	return strconv.FormatFloat(float64(d.Microseconds())/1000, 'f', 2, 64)
+}
+
+// normalize strips timestamps, durations, and masked values from the handler output
+func normalize(output string, masks []*regexp.Regexp) []string {
+	lines := []string{}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+
+		line = logPrefix.ReplaceAllString(line, "")
+
+		var fields map[string]interface{}
+		if json.Unmarshal([]byte(line), &fields) == nil {
+			for _, name := range volatileFields {
+				delete(fields, name)
+			}
+
+			b, _ := json.Marshal(fields)
+			line = string(b)
+		}
+
+		for _, m := range masks {
+			line = m.ReplaceAllString(line, "<masked>")
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// newResult creates the value we compare against the golden file
+func newResult(event string, resp *InvokeResponse, output string, masks []*regexp.Regexp) *Result {
+	result := &Result{
+		Event: event,
+		Error: resp.Error,
+		Logs:  normalize(output, masks),
+	}
+
+	if len(resp.Payload) > 0 {
+		if json.Valid(resp.Payload) {
+			result.Response = json.RawMessage(resp.Payload)
+		} else {
+			quoted, _ := json.Marshal(string(resp.Payload))
+			result.Response = json.RawMessage(quoted)
+		}
+	}
+
+	return result
+}
+
+// diffLines returns the lines that differ between want and got,
+// prefixed with - and + respectively
+func diffLines(want, got []string) []string {
+	// Longest common subsequence table
+	lcs := make([][]int, len(want)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(got)+1)
+	}
+
+	for i := len(want) - 1; i >= 0; i-- {
+		for j := len(got) - 1; j >= 0; j-- {
+			if want[i] == got[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	diff := []string{}
+	i, j := 0, 0
+
+	for i < len(want) && j < len(got) {
+		switch {
+		case want[i] == got[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "- "+want[i])
+			i++
+		default:
+			diff = append(diff, "+ "+got[j])
+			j++
+		}
+	}
+
+	for ; i < len(want); i++ {
+		diff = append(diff, "- "+want[i])
+	}
+
+	for ; j < len(got); j++ {
+		diff = append(diff, "+ "+got[j])
+	}
+
+	return diff
+}
+
+// compareGolden returns the difference between result and the golden file.
+// If update is true, it writes result to the golden file instead.
+func compareGolden(result *Result, golden string, update bool) ([]string, error) {
+	got, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	got = append(got, '\n')
+
+	if update {
+		err = os.MkdirAll(filepath.Dir(golden), 0755)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, ioutil.WriteFile(golden, got, 0644)
+	}
+
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		return nil, errors.New("Could not read golden file " + golden + " (run with -u to create it)")
+	}
+
+	return diffLines(strings.Split(string(want), "\n"), strings.Split(string(got), "\n")), nil
+}
+
+// eventFile returns the fixture for name, which is either
+// one of the files in events/ (such as sqs) or a path to a JSON file
+func eventFile(eventDir, name string) (string, string) {
+	if strings.HasSuffix(name, ".json") {
+		return strings.TrimSuffix(filepath.Base(name), ".json"), name
+	}
+
+	return name, filepath.Join(eventDir, name+".json")
+}
+
+// Config is what main runs for each handler
+type Config struct {
+	Events    []string
+	EventDir  string
+	GoldenDir string
+	Update    bool
+	Verbose   bool
+	Bench     int
+	Opts      Options
+}
+
+// readEvents reads the fixtures named in cfg.Events
+func readEvents(cfg Config) (map[string][]byte, []string, error) {
+	payloads := map[string][]byte{}
+	names := []string{}
+
+	for _, e := range cfg.Events {
+		event, path := eventFile(cfg.EventDir, strings.TrimSpace(e))
+
+		payload, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, nil, errors.New("Could not read " + path)
+		}
+
+		payloads[event] = payload
+		names = append(names, event)
+	}
+
+	return payloads, names, nil
+}
+
+// testHandler compares the handler with its golden files
+// and returns the number of events that failed
+func testHandler(name, binary string, cfg Config) int {
+	payloads, events, err := readEvents(cfg)
+	if err != nil {
+		fmt.Println("FAIL " + name + ": " + err.Error())
+		return 1
+	}
+
+	failed := 0
+
+	for _, event := range events {
+		resp, output, err := run(binary, payloads[event], cfg.Opts)
+		if err != nil {
+			fmt.Println("FAIL " + name + " " + event + ": " + err.Error())
+			fmt.Print(output)
+			failed++
+			continue
+		}
+
+		result := newResult(event, resp, output, cfg.Opts.Masks)
+
+		if cfg.Verbose {
+			out, _ := json.MarshalIndent(result, "", "  ")
+			fmt.Println(string(out))
+		}
+
+		golden := filepath.Join(cfg.GoldenDir, name+"."+event+".json")
+
+		diff, err := compareGolden(result, golden, cfg.Update)
+		if err != nil {
+			fmt.Println("FAIL " + name + " " + event + ": " + err.Error())
+			failed++
+			continue
+		}
+
+		if cfg.Update {
+			fmt.Println("Wrote " + golden)
+			continue
+		}
+
+		if len(diff) > 0 {
+			fmt.Println("FAIL " + name + " " + event + ": output differs from " + golden)
+			fmt.Println(strings.Join(diff, "\n"))
+			failed++
+			continue
+		}
+
+		fmt.Println("ok   " + name + " " + event)
+	}
+
+	return failed
+}
+
+// benchHandler prints the cold and warm invocation times of the handler
+// for each event and returns the number of events that failed
+func benchHandler(name, binary string, cfg Config, w io.Writer) int {
+	payloads, events, err := readEvents(cfg)
+	if err != nil {
+		fmt.Println("FAIL " + name + ": " + err.Error())
+		return 1
+	}
+
+	failed := 0
+
+	for _, event := range events {
+		t, err := benchmark(binary, payloads[event], cfg.Bench, cfg.Opts)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t%s\tFAIL: %s\n", name, event, err.Error())
+			failed++
+			continue
+		}
+
+		min, mean, max := summarize(t.Warm)
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", name, event, milliseconds(t.Cold),
+			milliseconds(min), milliseconds(mean), milliseconds(max))
+	}
+
+	return failed
+}
+
+func main() {
+	handlerDirs := flag.String("handlers", "", "Comma-separated directories of handler packages")
+	binary := flag.String("b", "", "A pre-built handler binary to use instead of building -handlers")
+	eventNames := flag.String("e", "s3,sqs,sns,dynamodb,ses,cloudtrail", "Comma-separated event fixtures (names in -d or paths to JSON files)")
+	eventDir := flag.String("d", "events", "The directory containing the event fixtures")
+	goldenDir := flag.String("g", "events/golden", "The directory containing the golden files")
+	update := flag.Bool("u", false, "Whether to write the golden files instead of comparing them")
+	function := flag.String("f", "", "The function name to put in lambdacontext (default: handler directory name)")
+	region := flag.String("r", "us-west-2", "The region to put in AWS_REGION")
+	timeout := flag.Duration("t", 5*time.Second, "The invocation deadline")
+	verbose := flag.Bool("v", false, "Whether to print the response and logs of every invocation")
+	bench := flag.Int("bench", 0, "Instead of comparing golden files, time a cold invocation and this many warm invocations")
+
+	var env multiFlag
+	var masks multiFlag
+
+	flag.Var(&env, "env", "A KEY=VALUE environment variable for the handler (repeatable)")
+	flag.Var(&masks, "m", "A regular expression whose matches are masked in the logs (repeatable)")
+
+	flag.Parse()
+
+	if *handlerDirs == "" && *binary == "" {
+		fmt.Println("You must supply the handler directories (-handlers DIRECTORY[,DIRECTORY...]) or a pre-built handler binary (-b BINARY)")
+		return
+	}
+
+	cfg := Config{
+		Events:    strings.Split(*eventNames, ","),
+		EventDir:  *eventDir,
+		GoldenDir: *goldenDir,
+		Update:    *update,
+		Verbose:   *verbose,
+		Bench:     *bench,
+		Opts: Options{
+			RequestID: "495b12a8-xmpl-4eca-8168-160484189f99",
+			Region:    *region,
+			Timeout:   *timeout,
+			Env:       env,
+		},
+	}
+
+	for _, m := range masks {
+		re, err := regexp.Compile(m)
+		if err != nil {
+			fmt.Println("Could not compile mask " + m + ": " + err.Error())
+			os.Exit(1)
+		}
+
+		cfg.Opts.Masks = append(cfg.Opts.Masks, re)
+	}
+
+	// Map the path of each handler to its binary;
+	// handlers are named after the last element of their path
+	binaries := map[string]string{}
+	paths := []string{}
+
+	cleanup := func() {}
+
+	if *binary != "" {
+		binaries[*binary] = *binary
+		paths = append(paths, *binary)
+	} else {
+		tmpDir, err := ioutil.TempDir("", "lambda-local")
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+
+		cleanup = func() { os.RemoveAll(tmpDir) }
+
+		for _, dir := range strings.Split(*handlerDirs, ",") {
+			abs, err := filepath.Abs(strings.TrimSpace(dir))
+			if err != nil {
+				fmt.Println(err.Error())
+				cleanup()
+				os.Exit(1)
+			}
+
+			if binaries[abs] != "" {
+				continue
+			}
+
+			outDir := filepath.Join(tmpDir, strconv.Itoa(len(paths)))
+
+			err = os.Mkdir(outDir, 0755)
+			if err == nil {
+				binaries[abs], err = buildHandler(abs, outDir)
+			}
+
+			if err != nil {
+				fmt.Println(err.Error())
+				cleanup()
+				os.Exit(1)
+			}
+
+			paths = append(paths, abs)
+		}
+	}
+
+	failed := 0
+
+	var w *tabwriter.Writer
+
+	if cfg.Bench > 0 {
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "HANDLER\tEVENT\tCOLD (ms)\tWARM MIN (ms)\tWARM MEAN (ms)\tWARM MAX (ms)")
+	}
+
+	for _, path := range paths {
+		name := filepath.Base(path)
+
+		cfg.Opts.FunctionName = *function
+		if cfg.Opts.FunctionName == "" {
+			cfg.Opts.FunctionName = name
+		}
+
+		if cfg.Bench > 0 {
+			failed += benchHandler(name, binaries[path], cfg, w)
+		} else {
+			failed += testHandler(name, binaries[path], cfg)
+		}
+	}
+
+	if w != nil {
+		w.Flush()
+	}
+
+	cleanup()
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Function is a stand-in for the go1.x runtime handler server
+type Function struct{}
+
+func (f *Function) Ping(req *PingRequest, resp *PingResponse) error {
+	return nil
+}
+
+func (f *Function) Invoke(req *InvokeRequest, resp *InvokeResponse) error {
+	if req.RequestId == "" {
+		return errors.New("missing request ID")
+	}
+
+	if string(req.Payload) == `{"fail":true}` {
+		resp.Error = &InvokeResponseError{Message: "bad event", Type: "errorString"}
+		return nil
+	}
+
+	resp.Payload = []byte(`{"echo":` + string(req.Payload) + `}`)
+	return nil
+}
+
+func startServer(t *testing.T) string {
+	server := rpc.NewServer()
+
+	err := server.Register(&Function{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { l.Close() })
+
+	go server.Accept(l)
+
+	return l.Addr().String()
+}
+
+func TestInvoke(t *testing.T) {
+	addr := startServer(t)
+
+	opts := Options{
+		FunctionName: "test",
+		RequestID:    "495b12a8-xmpl-4eca-8168-160484189f99",
+		Region:       "us-west-2",
+		Timeout:      time.Second,
+	}
+
+	client, err := dial(addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer client.Close()
+
+	resp, err := invoke(client, []byte(`{"a":1}`), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(resp.Payload) != `{"echo":{"a":1}}` {
+		t.Errorf("Got payload %s", resp.Payload)
+	}
+
+	resp, err = invoke(client, []byte(`{"fail":true}`), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Error == nil || resp.Error.Message != "bad event" {
+		t.Errorf("Expected error 'bad event', got %v", resp.Error)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	min, mean, max := summarize([]time.Duration{3 * time.Millisecond, time.Millisecond, 2 * time.Millisecond})
+
+	if min != time.Millisecond || mean != 2*time.Millisecond || max != 3*time.Millisecond {
+		t.Errorf("Got %v, %v, %v", min, mean, max)
+	}
+
+	if milliseconds(1500*time.Microsecond) != "1.50" {
+		t.Errorf("Got %s", milliseconds(1500*time.Microsecond))
+	}
+
+	min, mean, max = summarize(nil)
+	if min != 0 || mean != 0 || max != 0 {
+		t.Error("Expected zero durations for no invocations")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	output := "2021/03/01 12:00:00 REQUEST ID: 495b12a8\n\n2021/03/01 12:00:00 DEADLINE: 2021-03-01 12:00:05 +0000 UTC\r\nplain line\n" +
+		`{"time":"2021-03-01T12:00:00Z","msg":"hello","durationMs":1.5,"requestId":"495b12a8"}` + "\n"
+	masks := []*regexp.Regexp{regexp.MustCompile(`DEADLINE: .*`)}
+
+	got := normalize(output, masks)
+	want := []string{"REQUEST ID: 495b12a8", "<masked>", "plain line", `{"msg":"hello","requestId":"495b12a8"}`}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %q, want %q", got, want)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	want := []string{"a", "b", "c", "d"}
+	got := []string{"a", "c", "x", "d"}
+
+	diff := diffLines(want, got)
+	expected := []string{"- b", "+ x"}
+
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("Got %q, want %q", diff, expected)
+	}
+
+	if len(diffLines(want, want)) != 0 {
+		t.Error("Expected no difference between identical input")
+	}
+}
+
+func TestCompareGolden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lambda-local")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	golden := filepath.Join(dir, "handler.sqs.json")
+
+	resp := &InvokeResponse{Payload: []byte(`"FunctionCount"`)}
+	result := newResult("sqs", resp, "2021/03/01 12:00:00 hello\n", nil)
+
+	_, err = compareGolden(result, golden, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := compareGolden(result, golden, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diff) != 0 {
+		t.Errorf("Expected no difference, got %q", diff)
+	}
+
+	result.Logs = append(result.Logs, "goodbye")
+
+	diff, err = compareGolden(result, golden, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diff) == 0 {
+		t.Error("Expected a difference after changing the logs")
+	}
+}
+
+// TestGoldenFiles builds each handler that has a golden file in events/golden
+// and compares it with the golden file, the way go run . -handlers does
+func TestGoldenFiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping building the handlers in short mode")
+	}
+
+	goldens, err := filepath.Glob(filepath.Join("events", "golden", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(goldens) == 0 {
+		t.Fatal("Expected golden files in events/golden")
+	}
+
+	dir := t.TempDir()
+
+	for i, golden := range goldens {
+		// Such as HandleSQSEvent.sqs.json
+		parts := strings.Split(strings.TrimSuffix(filepath.Base(golden), ".json"), ".")
+		if len(parts) != 2 {
+			t.Errorf("Unexpected golden file name %s", golden)
+			continue
+		}
+
+		name, event := parts[0], parts[1]
+
+		outDir := filepath.Join(dir, strconv.Itoa(i))
+
+		err = os.Mkdir(outDir, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		binary, err := buildHandler(filepath.Join("..", name), outDir)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		payload, err := ioutil.ReadFile(filepath.Join("events", event+".json"))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		// Without credentials, so the handlers' AWS calls fail the same way everywhere
+		opts := Options{
+			FunctionName: name,
+			RequestID:    "495b12a8-xmpl-4eca-8168-160484189f99",
+			Region:       "us-west-2",
+			Timeout:      5 * time.Second,
+			Env:          []string{"HOME=" + dir, "AWS_EC2_METADATA_DISABLED=true"},
+		}
+
+		resp, output, err := run(binary, payload, opts)
+		if err != nil {
+			t.Errorf("%s %s: %v\n%s", name, event, err, output)
+			continue
+		}
+
+		diff, err := compareGolden(newResult(event, resp, output, nil), golden, false)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if len(diff) > 0 {
+			t.Errorf("%s %s differs from %s:\n%s", name, event, golden, strings.Join(diff, "\n"))
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package lambdalog writes JSON log lines tagged with the Lambda invocation,
+// and adds the X-Ray trace header of the invocation to AWS SDK requests.
+package lambdalog
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// TraceHeader is the HTTP header that carries the X-Ray trace ID
+const TraceHeader = "X-Amzn-Trace-Id"
+
+// Redacted replaces the value of an environment variable that isn't safe to log
+const Redacted = "REDACTED"
+
+// Fields are extra name/value pairs for a log line
+type Fields map[string]interface{}
+
+// Logger writes JSON log lines for one invocation;
+// each line has the request ID, function name, whether it's a cold start, and the duration so far
+type Logger struct {
+	out          io.Writer
+	requestID    string
+	functionName string
+	traceID      string
+	coldStart    bool
+	start        time.Time
+}
+
+var (
+	mu        sync.Mutex
+	coldStart = true
+)
+
+// Lambda sets these environment variables; none of them are secret
+var safeEnv = map[string]bool{
+	"AWS_DEFAULT_REGION":              true,
+	"AWS_EXECUTION_ENV":               true,
+	"AWS_LAMBDA_FUNCTION_MEMORY_SIZE": true,
+	"AWS_LAMBDA_FUNCTION_NAME":        true,
+	"AWS_LAMBDA_FUNCTION_VERSION":     true,
+	"AWS_LAMBDA_LOG_GROUP_NAME":       true,
+	"AWS_LAMBDA_LOG_STREAM_NAME":      true,
+	"AWS_REGION":                      true,
+	"LAMBDA_RUNTIME_DIR":              true,
+	"LAMBDA_TASK_ROOT":                true,
+	"LANG":                            true,
+	"PATH":                            true,
+	"TZ":                              true,
+}
+
+// New creates a Logger for the invocation in ctx that writes to stdout.
+// The first Logger created for an invocation in a process is marked as a cold start;
+// Loggers created outside an invocation, such as in init(), don't count.
+func New(ctx context.Context) *Logger {
+	return NewWithWriter(ctx, os.Stdout)
+}
+
+// NewWithWriter creates a Logger for the invocation in ctx that writes to out
+func NewWithWriter(ctx context.Context, out io.Writer) *Logger {
+	l := &Logger{
+		out:          out,
+		functionName: lambdacontext.FunctionName,
+		traceID:      TraceID(ctx),
+		start:        time.Now(),
+	}
+
+	mu.Lock()
+	l.coldStart = coldStart
+
+	lc, ok := lambdacontext.FromContext(ctx)
+	if ok {
+		l.requestID = lc.AwsRequestID
+		coldStart = false
+	}
+
+	mu.Unlock()
+
+	return l
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries l
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the Logger in ctx,
+// or a new Logger for the invocation if there isn't one
+func FromContext(ctx context.Context) *Logger {
+	l, ok := ctx.Value(contextKey{}).(*Logger)
+	if ok {
+		return l
+	}
+
+	return New(ctx)
+}
+
+// TraceID returns the X-Ray trace header of the invocation in ctx
+func TraceID(ctx context.Context) string {
+	// The runtime stores the header in the context under this string key
+	// and in the _X_AMZN_TRACE_ID environment variable
+	traceID, ok := ctx.Value("x-amzn-trace-id").(string)
+	if ok && traceID != "" {
+		return traceID
+	}
+
+	return os.Getenv("_X_AMZN_TRACE_ID")
+}
+
+// Log writes one JSON line with level, msg, the invocation details, and fields
+func (l *Logger) Log(level, msg string, fields Fields) {
+	line := map[string]interface{}{}
+
+	for k, v := range fields {
+		line[k] = v
+	}
+
+	line["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	line["level"] = level
+	line["msg"] = msg
+	line["requestId"] = l.requestID
+	line["functionName"] = l.functionName
+	line["coldStart"] = l.coldStart
+	line["durationMs"] = float64(time.Since(l.start).Microseconds()) / 1000
+
+	if l.traceID != "" {
+		line["traceId"] = l.traceID
+	}
+
+	b, err := json.Marshal(line)
+	if err != nil {
+		b, _ = json.Marshal(map[string]string{
+			"level": "ERROR",
+			"msg":   "Could not marshal log line: " + err.Error(),
+		})
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	_, _ = l.out.Write(append(b, '\n'))
+}
+
+// Info writes an INFO line
+func (l *Logger) Info(msg string, fields Fields) {
+	l.Log("INFO", msg, fields)
+}
+
+// Error writes an ERROR line with err in the error field
+func (l *Logger) Error(msg string, err error, fields Fields) {
+	f := Fields{}
+
+	for k, v := range fields {
+		f[k] = v
+	}
+
+	if err != nil {
+		f["error"] = err.Error()
+	}
+
+	l.Log("ERROR", msg, f)
+}
+
+// Done writes the final line of the invocation, including its duration
+func (l *Logger) Done() {
+	l.Info("Invocation complete", nil)
+}
+
+// IsSafe returns whether the value of the environment variable can be shown:
+// Lambda sets it, or it's named in allow
+func IsSafe(name string, allow ...string) bool {
+	if safeEnv[name] {
+		return true
+	}
+
+	for _, a := range allow {
+		if a == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Environment returns the environment variables of the process.
+// Every value is redacted except those Lambda sets and those named in allow.
+func Environment(allow ...string) map[string]string {
+	env := map[string]string{}
+
+	for _, element := range os.Environ() {
+		parts := strings.SplitN(element, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		if IsSafe(parts[0], allow...) {
+			env[parts[0]] = parts[1]
+		} else {
+			env[parts[0]] = Redacted
+		}
+	}
+
+	return env
+}
+
+// AddTraceHeader adds a middleware that sets the X-Ray trace header of the
+// invocation on every request an AWS SDK for Go V2 client sends.
+// Pass the handler's ctx to each operation so the header matches the invocation:
+//
+//	cfg, err := config.LoadDefaultConfig(ctx,
+//	    config.WithAPIOptions([]func(*middleware.Stack) error{lambdalog.AddTraceHeader}))
+func AddTraceHeader(stack *middleware.Stack) error {
+	return stack.Build.Add(middleware.BuildMiddlewareFunc("LambdaTraceHeader",
+		func(ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler) (middleware.BuildOutput, middleware.Metadata, error) {
+			req, ok := in.Request.(*smithyhttp.Request)
+			if ok {
+				traceID := TraceID(ctx)
+				if traceID != "" {
+					req.Header.Set(TraceHeader, traceID)
+				}
+			}
+
+			return next.HandleBuild(ctx, in)
+		}), middleware.After)
+}
@@ -0,0 +1,91 @@
+package lambdalog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+func TestLogger(t *testing.T) {
+	ctx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{
+		AwsRequestID: "495b12a8-xmpl-4eca-8168-160484189f99",
+	})
+	ctx = context.WithValue(ctx, "x-amzn-trace-id", "Root=1-5759e988-bd862e3fe1be46a994272793")
+
+	var buf bytes.Buffer
+
+	first := NewWithWriter(ctx, &buf)
+	first.Info("hello", Fields{"count": 2})
+	first.Error("failed", errors.New("boom"), nil)
+
+	second := NewWithWriter(ctx, &buf)
+	second.Done()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got %d", len(lines))
+	}
+
+	var line map[string]interface{}
+
+	err := json.Unmarshal([]byte(lines[0]), &line)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if line["msg"] != "hello" || line["level"] != "INFO" || line["count"] != float64(2) {
+		t.Errorf("Unexpected line: %s", lines[0])
+	}
+
+	if line["traceId"] != "Root=1-5759e988-bd862e3fe1be46a994272793" {
+		t.Errorf("Expected the trace ID in %s", lines[0])
+	}
+
+	if line["coldStart"] != true {
+		t.Errorf("Expected the first logger to be a cold start: %s", lines[0])
+	}
+
+	err = json.Unmarshal([]byte(lines[1]), &line)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if line["error"] != "boom" || line["level"] != "ERROR" {
+		t.Errorf("Unexpected line: %s", lines[1])
+	}
+
+	err = json.Unmarshal([]byte(lines[2]), &line)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if line["coldStart"] != false {
+		t.Errorf("Expected the second logger to be a warm start: %s", lines[2])
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	os.Setenv("AWS_REGION", "us-west-2")
+	os.Setenv("LAMBDALOG_TEST_SECRET", "hunter2")
+	os.Setenv("LAMBDALOG_TEST_ALLOWED", "visible")
+
+	env := Environment("LAMBDALOG_TEST_ALLOWED")
+
+	if env["AWS_REGION"] != "us-west-2" {
+		t.Errorf("Expected AWS_REGION to be logged, got %s", env["AWS_REGION"])
+	}
+
+	if env["LAMBDALOG_TEST_SECRET"] != Redacted {
+		t.Errorf("Expected LAMBDALOG_TEST_SECRET to be redacted, got %s", env["LAMBDALOG_TEST_SECRET"])
+	}
+
+	if env["LAMBDALOG_TEST_ALLOWED"] != "visible" {
+		t.Errorf("Expected LAMBDALOG_TEST_ALLOWED to be logged, got %s", env["LAMBDALOG_TEST_ALLOWED"])
+	}
+}
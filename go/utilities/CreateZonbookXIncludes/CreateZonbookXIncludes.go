@@ -17,7 +17,7 @@ import (
 	"strings"
 	"unicode"
 
-	"gopkg.in/yaml.v2"
+	"github.com/Doug-AWS/code-examples/go/utilities/metadata"
 )
 
 // RepoTree represents the files in a repo
@@ -479,20 +479,7 @@ type XFile struct {
 
 var XFiles []XFile
 
-// Metadata defines the elements in metatdata.yaml
-type Metadata struct {
-	Files []struct {
-		Path        string `yaml:"path"`
-		Description string `yaml:"description"`
-		SdkLink     string `yaml:"sdklink"`
-		Services    []struct {
-			Service string   `yaml:"service"`
-			Actions []string `yaml:"actions"`
-		} `yaml:"services"`
-	} `yaml:"files"`
-}
-
-func processFiles(debug bool, metadata Metadata, local bool, branch, path, language, outDir string) error {
+func processFiles(debug bool, meta *metadata.Metadata, local bool, branch, path, language, outDir string) error {
 	filePrefix := "https://github.com/awsdocs/aws-doc-sdk-examples/tree/" + branch + "/"
 
 	if local {
@@ -501,7 +488,7 @@ func processFiles(debug bool, metadata Metadata, local bool, branch, path, langu
 
 	// Iterate through files.
 	// Create a link and tab for services/operations
-	for _, data := range metadata.Files {
+	for _, data := range meta.Files {
 		// If Description is "test", skip it
 		if data.Description == "test" {
 			// debugPrint(debug, "Skipping test file "+data.Path)
@@ -565,22 +552,11 @@ func processLocalMetadata(debug bool, branch, path, outDir string) error {
 		return nil
 	}
 
-	// Get contents of file and stuff it into a Metadata struct
-	var metadata Metadata
-
-	file, err := os.Open(path)
+	// Get contents of file and stuff it into a Metadata struct;
+	// the language directory says what version it is if it has no version line
+	meta, err := metadata.LoadAs(path, metadata.DefaultVersion(strings.Join(parts, "/")))
 	if err != nil {
-		fmt.Println("Got an error opening " + path)
-		return err
-	}
-	defer file.Close()
-
-	// Init new YAML decode
-	d := yaml.NewDecoder(file)
-
-	// Start YAML decoding from file
-	if err := d.Decode(&metadata); err != nil {
-		// fmt.Println("Skipping metadata file due to YAML parsing errors: " + path)
+		fmt.Println("Got an error reading " + path)
 		return err
 	}
 
@@ -588,7 +564,7 @@ func processLocalMetadata(debug bool, branch, path, outDir string) error {
 	language := mapLanguageToExtension(debug, parts[3])
 
 	// Wade through metadata file and create entries
-	err = processFiles(debug, metadata, false, branch, path, language, outDir)
+	err = processFiles(debug, meta, false, branch, path, language, outDir)
 	if err != nil {
 		fmt.Println("Got an error processing local files:")
 		fmt.Println(err.Error())
@@ -615,8 +591,6 @@ func processRemoteMetadata(debug bool, branch, path, outDir string) error {
 
 	language = mapLanguageToExtension(debug, language)
 
-	var metadata Metadata
-
 	results, err := http.Get(path)
 	if err != nil {
 		fmt.Println("Got an error getting the file:")
@@ -634,14 +608,14 @@ func processRemoteMetadata(debug bool, branch, path, outDir string) error {
 
 	bytes := buf.Bytes()
 
-	err = yaml.Unmarshal(bytes, &metadata)
+	meta, err := metadata.ParseAs(bytes, metadata.DefaultVersion(path))
 	if err != nil {
-		fmt.Println("Got an error unmarshalling data for path " + path)
+		fmt.Println("Got an error parsing the metadata for path " + path)
 		fmt.Println(err)
 		return err
 	}
 
-	err = processFiles(debug, metadata, false, branch, path, language, outDir)
+	err = processFiles(debug, meta, false, branch, path, language, outDir)
 	if err != nil {
 		fmt.Println("Got an error processing remote files:")
 		fmt.Println(err.Error())
@@ -0,0 +1,7 @@
+module mymain
+
+go 1.15
+
+require github.com/Doug-AWS/code-examples/go/utilities/metadata v0.0.0
+
+replace github.com/Doug-AWS/code-examples/go/utilities/metadata => ../metadata
@@ -2,6 +2,6 @@ module mymain
 
 go 1.15
 
-require (
-	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
-)
+require github.com/Doug-AWS/code-examples/go/utilities/metadata v0.0.0
+
+replace github.com/Doug-AWS/code-examples/go/utilities/metadata => ../metadata
@@ -4,11 +4,13 @@ import (
 	"flag"
 	"fmt"
 
-	"gopkg.in/yaml.v3"
+	"github.com/Doug-AWS/code-examples/go/utilities/metadata"
 )
 
 /*
-   The existing structure of metaday.yaml:
+   The Metadata, File, and Service types are in the metadata package,
+   which reads both versions of metadata.yaml and writes version 2.
+   Version 1 is the older structure:
 
    files:
      - path: CopyObject/CopyObjectv2.go
@@ -18,45 +20,10 @@ import (
        services:
          - s3
 
-	Which gives us a struct like:
-
-	type Metadata struct {
-       Files []struct {
-          Path     string   `yaml:"path"`
-	      Services []string `yaml:"services"`
-	   } `yaml:"files"`
-    }
-
-	But if we have a code example with multiple services,
-	how would that look?
-
-	Here's the struct that handles the yaml this puts out
-
-type Metadata struct {
-	Files []struct {
-		Path     string `yaml:"path"`
-		Services []struct {
-			Service string   `yaml:"service"`
-			Actions []string `yaml:"actions"`
-		} `yaml:"services"`
-	} `yaml:"files"`
-}
+   But if we have a code example with multiple services,
+   how would that look? That's version 2, which this puts out.
 */
 
-type Service struct {
-	Service string
-	Actions []string
-}
-
-type File struct {
-	Path     string
-	Services []Service
-}
-
-type Metadata struct {
-	Files []File
-}
-
 func debugPrint(debug bool, s string) {
 	if debug {
 		fmt.Println(s)
@@ -69,42 +36,42 @@ func main() {
 
 	debugPrint(*debug, "Debugging enabled")
 
-	s1 := Service{
+	s1 := metadata.Service{
 		Service: "s3",
 		Actions: []string{"DoThis", "DoThat"},
 	}
 
-	s2 := Service{
+	s2 := metadata.Service{
 		Service: "sns",
 		Actions: []string{"DidIt"},
 	}
 
-	file1 := File{
+	file1 := metadata.File{
 		Path:     "one/two",
-		Services: []Service{s1, s2},
+		Services: []metadata.Service{s1, s2},
 	}
 
-	s3 := Service{
+	s3 := metadata.Service{
 		Service: "sqs",
 		Actions: []string{"DoSqsThis", "DoSqsThat"},
 	}
 
-	s4 := Service{
+	s4 := metadata.Service{
 		Service: "sns",
 		Actions: []string{"SnsDidIt"},
 	}
 
-	file2 := File{
-		Path:     "one/two",
-		Services: []Service{s3, s4},
+	file2 := metadata.File{
+		Path:     "one/three",
+		Services: []metadata.Service{s3, s4},
 	}
 
-	myData := Metadata{
-		Files: []File{file1, file2},
+	myData := metadata.Metadata{
+		Files: []metadata.File{file1, file2},
 	}
 
 	// Display yaml
-	output, err := yaml.Marshal(myData)
+	output, err := myData.Marshal()
 	if err != nil {
 		fmt.Println("Got an error marshalling struct:")
 		fmt.Println(err.Error())
@@ -112,4 +79,11 @@ func main() {
 	}
 
 	fmt.Println(string(output))
+
+	// Make sure it reads back
+	_, err = metadata.Parse(output)
+	if err != nil {
+		fmt.Println("Got an error parsing the output:")
+		fmt.Println(err.Error())
+	}
 }
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Doug-AWS/code-examples/go/utilities/atomicfile"
+	"github.com/Doug-AWS/code-examples/go/utilities/metadata"
+)
+
+func usage() {
+	fmt.Println("Usage:")
+	fmt.Println("    go run . COMMAND [OPTIONS]")
+	fmt.Println(" where COMMAND is:")
+	fmt.Println("    lint [-r ROOT]   checks every metadata.yaml under ROOT (default .)")
+	fmt.Println("                     and lists the problems as FILE:LINE:COLUMN: MESSAGE")
+	fmt.Println("    schema [-o FILE] writes the JSON Schema for metadata.yaml to FILE (default stdout)")
+	fmt.Println("    coverage [-r ROOT] [-l LANGUAGES] [-json] [-v]")
+	fmt.Println("                     reports how much of each service's examples its metadata covers")
+	fmt.Println("    generate [-w] DIR")
+	fmt.Println("                     adds the SDK calls in the Go examples under DIR to DIR/metadata.yaml")
+	fmt.Println("                     and prints it, or with -w, writes it")
+	fmt.Println(" Run go run . COMMAND -h for the options of a command")
+}
+
+func lint(args []string) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	root := flags.String("r", ".", "The root of the checkout to look for metadata files in")
+	flags.Parse(args)
+
+	problems, checked, err := Lint(*root)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	for _, p := range problems {
+		fmt.Println(p.String())
+	}
+
+	fmt.Println(lintSummary(problems, checked))
+
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+}
+
+func schema(args []string) {
+	flags := flag.NewFlagSet("schema", flag.ExitOnError)
+	out := flags.String("o", "", "The file to write the schema to; by default it's written to stdout")
+	flags.Parse(args)
+
+	b, err := metadata.JSONSchema()
+	if err != nil {
+		fmt.Println("Got an error creating the schema: " + err.Error())
+		os.Exit(1)
+	}
+
+	if *out == "" {
+		fmt.Print(string(b))
+		return
+	}
+
+	err = ioutil.WriteFile(*out, b, 0644)
+	if err != nil {
+		fmt.Println("Got an error writing " + *out + ": " + err.Error())
+		os.Exit(1)
+	}
+}
+
+func coverage(args []string) {
+	flags := flag.NewFlagSet("coverage", flag.ExitOnError)
+	root := flags.String("r", ".", "The root of the checkout")
+	languages := flags.String("l", "", "A comma-separated list of the languages to report on, such as gov2,python; by default all of them")
+	asJSON := flags.Bool("json", false, "Write the report as JSON")
+	details := flags.Bool("v", false, "List the files that aren't in the metadata and the entries that aren't there")
+	flags.Parse(args)
+
+	layouts := metadata.Layouts
+
+	if *languages != "" {
+		layouts = []metadata.Layout{}
+
+		for _, name := range strings.Split(*languages, ",") {
+			found := false
+
+			for _, l := range metadata.Layouts {
+				if l.Language == strings.TrimSpace(name) {
+					layouts = append(layouts, l)
+					found = true
+				}
+			}
+
+			if !found {
+				fmt.Println("Unknown language " + name)
+				os.Exit(2)
+			}
+		}
+	}
+
+	report, err := Coverage(*root, layouts)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	if !*asJSON {
+		WriteCoverageTable(os.Stdout, report, *details)
+		return
+	}
+
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fmt.Println("Got an error writing the report: " + err.Error())
+		os.Exit(1)
+	}
+
+	fmt.Println(string(b))
+}
+
+func generate(args []string) {
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	write := flags.Bool("w", false, "Write the metadata file instead of printing it")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Println("You must supply the directory of a service's examples, such as gov2/s3")
+		os.Exit(2)
+	}
+
+	dir := flags.Arg(0)
+
+	// Keep the name of a .metadata.yaml file
+	file := filepath.Join(dir, "metadata.yaml")
+
+	_, err := os.Stat(file)
+	if os.IsNotExist(err) {
+		hidden := filepath.Join(dir, ".metadata.yaml")
+
+		_, err = os.Stat(hidden)
+		if err == nil {
+			file = hidden
+		}
+	}
+
+	// generate reads Go examples, so a directory outside a checkout gets the gov2 version
+	version := metadata.FindLayout("gov2").MetadataVersion
+
+	abs, err := filepath.Abs(file)
+	if err == nil {
+		if l := metadata.LayoutFor(abs); l != nil {
+			version = l.MetadataVersion
+		}
+	}
+
+	m := &metadata.Metadata{Version: metadata.Version, Files: []metadata.File{}}
+
+	_, err = os.Stat(file)
+	if err == nil {
+		m, err = metadata.LoadAs(file, version)
+		if err != nil {
+			fmt.Println("Fix the metadata before adding to it:")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	generated, err := GenerateFiles(dir)
+	if err != nil {
+		fmt.Println("Got an error reading the examples: " + err.Error())
+		os.Exit(1)
+	}
+
+	files, actions := MergeFiles(m, generated)
+
+	b, err := m.Marshal()
+	if err != nil {
+		fmt.Println("Got an error writing the metadata: " + err.Error())
+		os.Exit(1)
+	}
+
+	summary := "Added " + strconv.Itoa(files) + " files and " + strconv.Itoa(actions) + " actions to " + file
+
+	if !*write {
+		fmt.Print(string(b))
+		fmt.Fprintln(os.Stderr, summary)
+		return
+	}
+
+	err = atomicfile.WriteFile(file, b, 0644)
+	if err != nil {
+		fmt.Println("Got an error writing " + file + ": " + err.Error())
+		os.Exit(1)
+	}
+
+	fmt.Println(summary)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "lint":
+		lint(os.Args[2:])
+	case "schema":
+		schema(os.Args[2:])
+	case "coverage":
+		coverage(os.Args[2:])
+	case "generate":
+		generate(os.Args[2:])
+	case "-h", "help":
+		usage()
+	default:
+		fmt.Println("Unknown command " + os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Doug-AWS/code-examples/go/utilities/metadata"
+)
+
+// writeFiles creates the files, by path relative to root
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = ioutil.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	root := t.TempDir()
+
+	writeFiles(t, root, map[string]string{
+		"gov2/s3/metadata.yaml": `version: 2
+files:
+  - path: CopyObject/CopyObjectv2.go
+    services:
+      - service: s3
+        actions: [CopyObject]
+  - path: ListBuckets/ListBucketsv2.go
+    services:
+      - service: s3
+        actions: [ListBuckets]
+`,
+		"gov2/s3/CopyObject/CopyObjectv2.go": "package main\n",
+		"gov2/sns/.metadata.yaml": `version: 2
+files:
+  - path: Publish/Publishv2.go
+    services:
+      - service: sns
+        action: [Publish]
+`,
+		"gov2/sns/Publish/Publishv2.go": "package main\n",
+		// Version 1, like the rest of gov2, without a version line
+		"gov2/sqs/metadata.yaml":                    "files:\n  - path: main.go\n    services: [sqs]\n",
+		"gov2/sqs/main.go":                          "package main\n",
+		"javascriptv3/node_modules/x/metadata.yaml": "not: metadata\n",
+	})
+
+	problems, checked, err := Lint(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, p := range problems {
+		rel, _ := filepath.Rel(root, p.File)
+		got = append(got, filepath.ToSlash(rel)+":"+p.Diagnostic.String())
+	}
+
+	want := []string{
+		"gov2/s3/metadata.yaml:7:5: The file ListBuckets/ListBucketsv2.go does not exist",
+		"gov2/sns/.metadata.yaml:5:9: files[0].services[0] has no actions",
+		"gov2/sns/.metadata.yaml:6:9: Unknown field action in files[0].services[0]; expected service, actions",
+	}
+
+	if checked != 3 || !reflect.DeepEqual(got, want) {
+		t.Errorf("Checked %d files and got:\n%v", checked, got)
+	}
+
+	if s := lintSummary(problems, checked); s != "Checked 3 metadata files: 3 problems in 2 files" {
+		t.Errorf("Got the summary %q", s)
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	root := t.TempDir()
+
+	writeFiles(t, root, map[string]string{
+		// Version 1, like the rest of gov2, without a version line
+		"gov2/s3/metadata.yaml": `files:
+  - path: CopyObject/CopyObjectv2.go
+    services: [s3]
+    operations: [CopyObject]
+  - path: DeleteBucket/DeleteBucketv2.go
+    services: [s3]
+    operations: [DeleteBucket]
+`,
+		"gov2/s3/CopyObject/CopyObjectv2.go":       "package main\n",
+		"gov2/s3/CopyObject/CopyObjectv2_test.go":  "package main\n",
+		"gov2/s3/ListBuckets/ListBucketsv2.go":     "package main\n",
+		"gov2/sns/Publish/Publishv2.go":            "package main\n",
+		"gov2/.github/x.go":                        "package main\n",
+		"python/example_code/sqs/metadata.yaml":    "files:\n  - path: queue_wrapper.py\n    services:\n      - service: sqs\n        actions: [CreateQueue]\n",
+		"python/example_code/sqs/queue_wrapper.py": "",
+		"python/example_code/sqs/test_queue.py":    "",
+		"python/example_code/sqs/requirements.txt": "",
+		"python/example_code/shared/README.md":     "",
+	})
+
+	report, err := Coverage(root, metadata.Layouts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ServiceCoverage{
+		{
+			Language:    "gov2",
+			Sdk:         "go",
+			Service:     "s3",
+			Metadata:    "gov2/s3/metadata.yaml",
+			Sources:     2,
+			Covered:     1,
+			Actions:     2,
+			Missing:     []string{"ListBuckets/ListBucketsv2.go"},
+			MissingDirs: []string{"ListBuckets"},
+			Stale:       []string{"DeleteBucket/DeleteBucketv2.go"},
+		},
+		{
+			Language:    "gov2",
+			Sdk:         "go",
+			Service:     "sns",
+			Sources:     1,
+			Missing:     []string{"Publish/Publishv2.go"},
+			MissingDirs: []string{"Publish"},
+		},
+		{
+			Language: "python",
+			Sdk:      "py",
+			Service:  "sqs",
+			Metadata: "python/example_code/sqs/metadata.yaml",
+			Sources:  1,
+			Covered:  1,
+			Actions:  1,
+		},
+	}
+
+	if !reflect.DeepEqual(report.Services, want) {
+		t.Errorf("Got %+v", report.Services)
+	}
+
+	wantSdks := []SdkCoverage{
+		{Sdk: "go", Services: 2, Sources: 3, Covered: 1, Actions: 2, Stale: 1, Percent: 100.0 / 3},
+		{Sdk: "py", Services: 1, Sources: 1, Covered: 1, Actions: 1, Percent: 100},
+	}
+
+	if !reflect.DeepEqual(report.Sdks, wantSdks) {
+		t.Errorf("Got %+v", report.Sdks)
+	}
+
+	var b strings.Builder
+
+	WriteCoverageTable(&b, report, true)
+
+	for _, s := range []string{
+		"go   s3       2      1        50.0%     2        1             1\n",
+		"go   sns      1      0        0.0%      0        1             no metadata\n",
+		"go   2         3      1        33.3%     2        1\n",
+		"go s3:\n  not in the metadata: ListBuckets/ListBucketsv2.go\n  not there:           DeleteBucket/DeleteBucketv2.go\n",
+	} {
+		if !strings.Contains(b.String(), s) {
+			t.Errorf("Expected %q in:\n%s", s, b.String())
+		}
+	}
+}
+
+func TestFindCalls(t *testing.T) {
+	src := `package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	sqsv1 "github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// SNSPublishAPI is how the examples call a service through an interface
+type SNSPublishAPI interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+type S3ListAPI interface {
+	ListBuckets(ctx context.Context, params *s3.ListBucketsInput, optFns ...func(*s3.Options)) (*s3.ListBucketsOutput, error)
+}
+
+func list(c context.Context, api S3ListAPI) {
+	input := &s3.ListBucketsInput{}
+	api.ListBuckets(c, input)
+}
+
+func copy(client *s3.Client) {
+	client.CopyObject(context.TODO(), &s3.CopyObjectInput{})
+	client.Options()
+}
+
+func main() {
+	cfg, _ := config.LoadDefaultConfig(context.TODO())
+	client := s3.NewFromConfig(cfg)
+	client.PutObject(context.TODO(), &s3.PutObjectInput{Bucket: nil})
+
+	p := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{})
+	p.NextPage(context.TODO())
+
+	queue := sqsv1.New(nil)
+	queue.SendMessage(&sqsv1.SendMessageInput{})
+
+	_ = types.BucketLocationConstraintEu
+}
+`
+
+	got, err := FindCalls("main.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sns isn't imported, so its interface doesn't count
+	want := []metadata.Service{
+		{Service: "s3", Actions: []string{"CopyObject", "ListBuckets", "ListObjectsV2", "PutObject"}},
+		{Service: "sqs", Actions: []string{"SendMessage"}},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %+v", got)
+	}
+}
+
+func TestMergeFiles(t *testing.T) {
+	m, err := metadata.Parse([]byte(`files:
+  - path: PutObject/PutObjectv2.go
+    description: Uploads a file to a bucket.
+    services:
+      - service: s3
+        actions: [PutObject, CreateBucket]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, actions := MergeFiles(m, []metadata.File{
+		{Path: "PutObject/PutObjectv2.go", Services: []metadata.Service{
+			{Service: "s3", Actions: []string{"HeadBucket", "PutObject"}},
+			{Service: "sts", Actions: []string{"GetCallerIdentity"}},
+		}},
+		{Path: "ListBuckets/ListBucketsv2.go", Services: []metadata.Service{
+			{Service: "s3", Actions: []string{"ListBuckets"}},
+		}},
+	})
+
+	want := []metadata.File{
+		{
+			Path:        "PutObject/PutObjectv2.go",
+			Description: "Uploads a file to a bucket.",
+			Services: []metadata.Service{
+				{Service: "s3", Actions: []string{"PutObject", "CreateBucket", "HeadBucket"}},
+				{Service: "sts", Actions: []string{"GetCallerIdentity"}},
+			},
+			Line:   2,
+			Column: 5,
+		},
+		{Path: "ListBuckets/ListBucketsv2.go", Services: []metadata.Service{
+			{Service: "s3", Actions: []string{"ListBuckets"}},
+		}},
+	}
+
+	if files != 1 || actions != 3 || !reflect.DeepEqual(m.Files, want) {
+		t.Errorf("Added %d files and %d actions and got %+v", files, actions, m.Files)
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Doug-AWS/code-examples/go/utilities/metadata"
+)
+
+// ServiceCoverage is how much of the examples for a service its metadata covers.
+// The paths are relative to the service directory.
+type ServiceCoverage struct {
+	Language string `json:"language"`
+	Sdk      string `json:"sdk"`
+	Service  string `json:"service"`
+	// The metadata file, relative to the checkout, or empty if there isn't one
+	Metadata string `json:"metadata,omitempty"`
+	Sources  int    `json:"sources"`
+	Covered  int    `json:"covered"`
+	// The number of service actions in the metadata
+	Actions int `json:"actions"`
+	// Source files that aren't in the metadata
+	Missing []string `json:"missing,omitempty"`
+	// Directories with source files, none of which are in the metadata
+	MissingDirs []string `json:"missingDirs,omitempty"`
+	// Paths in the metadata that aren't there
+	Stale []string `json:"stale,omitempty"`
+	// Why the metadata couldn't be read
+	Error string `json:"error,omitempty"`
+}
+
+// Percent returns how much of the sources the metadata covers
+func (s ServiceCoverage) Percent() float64 {
+	if s.Sources == 0 {
+		return 100
+	}
+
+	return float64(s.Covered) * 100 / float64(s.Sources)
+}
+
+// SdkCoverage is the total coverage of an SDK's services
+type SdkCoverage struct {
+	Sdk      string  `json:"sdk"`
+	Services int     `json:"services"`
+	Sources  int     `json:"sources"`
+	Covered  int     `json:"covered"`
+	Actions  int     `json:"actions"`
+	Stale    int     `json:"stale"`
+	Percent  float64 `json:"percent"`
+}
+
+// CoverageReport is the coverage of each service and SDK
+type CoverageReport struct {
+	Services []ServiceCoverage `json:"services"`
+	Sdks     []SdkCoverage     `json:"sdks"`
+}
+
+// sourceFiles returns the source files under dir, relative to it with slashes
+func sourceFiles(l metadata.Layout, dir string) ([]string, error) {
+	files := []string{}
+
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if p != dir && (skipDirs[info.Name()] || strings.HasPrefix(info.Name(), ".")) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if l.IsSource(info.Name()) {
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+
+			files = append(files, filepath.ToSlash(rel))
+		}
+
+		return nil
+	})
+
+	sort.Strings(files)
+
+	return files, err
+}
+
+// serviceCoverage compares the metadata in a service directory with its source files
+func serviceCoverage(root string, l metadata.Layout, service string) (ServiceCoverage, error) {
+	s := ServiceCoverage{Language: l.Language, Sdk: l.Sdk, Service: service}
+	dir := filepath.Join(root, filepath.FromSlash(l.ServiceDir), service)
+
+	sources, err := sourceFiles(l, dir)
+	if err != nil {
+		return s, err
+	}
+
+	s.Sources = len(sources)
+
+	var m *metadata.Metadata
+
+	for _, name := range []string{"metadata.yaml", ".metadata.yaml"} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+
+		s.Metadata = path.Join(l.ServiceDir, service, name)
+
+		m, err = metadata.LoadAs(filepath.Join(dir, name), l.MetadataVersion)
+		if err != nil {
+			s.Error = err.Error()
+		}
+
+		break
+	}
+
+	listed := map[string]bool{}
+	actions := map[string]bool{}
+
+	if m != nil {
+		for _, f := range m.Files {
+			listed[path.Clean(f.Path)] = true
+
+			for _, svc := range f.Services {
+				for _, a := range svc.Actions {
+					actions[svc.Service+":"+a] = true
+				}
+			}
+
+			_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(f.Path)))
+			if os.IsNotExist(err) {
+				s.Stale = append(s.Stale, f.Path)
+			}
+		}
+	}
+
+	s.Actions = len(actions)
+
+	// A directory is covered if any of its sources are
+	dirs := map[string]bool{}
+
+	for _, f := range sources {
+		d := path.Dir(f)
+
+		if listed[f] {
+			s.Covered++
+			dirs[d] = true
+			continue
+		}
+
+		s.Missing = append(s.Missing, f)
+
+		_, ok := dirs[d]
+		if !ok {
+			dirs[d] = false
+		}
+	}
+
+	for d, covered := range dirs {
+		if !covered && d != "." {
+			s.MissingDirs = append(s.MissingDirs, d)
+		}
+	}
+
+	sort.Strings(s.MissingDirs)
+
+	return s, nil
+}
+
+// Coverage compares the metadata of every service of each language in the checkout at root
+// with the source files; a language that isn't in the checkout is skipped
+func Coverage(root string, layouts []metadata.Layout) (*CoverageReport, error) {
+	report := &CoverageReport{Services: []ServiceCoverage{}, Sdks: []SdkCoverage{}}
+
+	for _, l := range layouts {
+		entries, err := ioutil.ReadDir(filepath.Join(root, filepath.FromSlash(l.ServiceDir)))
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		total := SdkCoverage{Sdk: l.Sdk}
+
+		for _, e := range entries {
+			if !e.IsDir() || skipDirs[e.Name()] || strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+
+			s, err := serviceCoverage(root, l, e.Name())
+			if err != nil {
+				return nil, errors.New("Got an error reading " + l.ServiceDir + "/" + e.Name() + ": " + err.Error())
+			}
+
+			// Skip directories with nothing in them for the language, such as shared resources
+			if s.Sources == 0 && s.Metadata == "" {
+				continue
+			}
+
+			report.Services = append(report.Services, s)
+
+			total.Services++
+			total.Sources += s.Sources
+			total.Covered += s.Covered
+			total.Actions += s.Actions
+			total.Stale += len(s.Stale)
+		}
+
+		total.Percent = ServiceCoverage{Sources: total.Sources, Covered: total.Covered}.Percent()
+		report.Sdks = append(report.Sdks, total)
+	}
+
+	return report, nil
+}
+
+// percent formats a percentage to one decimal place
+func percent(p float64) string {
+	return strconv.FormatFloat(p, 'f', 1, 64) + "%"
+}
+
+// WriteCoverageTable writes a row for each service, the totals for each SDK,
+// and, if details is true, the missing and stale paths of each service
+func WriteCoverageTable(w io.Writer, report *CoverageReport, details bool) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "SDK\tService\tFiles\tCovered\tCoverage\tActions\tMissing dirs\tStale")
+
+	for _, s := range report.Services {
+		stale := strconv.Itoa(len(s.Stale))
+		if s.Metadata == "" {
+			stale = "no metadata"
+		} else if s.Error != "" {
+			stale = "not valid"
+		}
+
+		fmt.Fprintln(tw, s.Sdk+"\t"+s.Service+"\t"+strconv.Itoa(s.Sources)+"\t"+strconv.Itoa(s.Covered)+"\t"+
+			percent(s.Percent())+"\t"+strconv.Itoa(s.Actions)+"\t"+strconv.Itoa(len(s.MissingDirs))+"\t"+stale)
+	}
+
+	tw.Flush()
+
+	fmt.Fprintln(w)
+
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "SDK\tServices\tFiles\tCovered\tCoverage\tActions\tStale")
+
+	for _, t := range report.Sdks {
+		fmt.Fprintln(tw, t.Sdk+"\t"+strconv.Itoa(t.Services)+"\t"+strconv.Itoa(t.Sources)+"\t"+strconv.Itoa(t.Covered)+"\t"+
+			percent(t.Percent)+"\t"+strconv.Itoa(t.Actions)+"\t"+strconv.Itoa(t.Stale))
+	}
+
+	tw.Flush()
+
+	if !details {
+		return
+	}
+
+	for _, s := range report.Services {
+		if len(s.Missing) == 0 && len(s.Stale) == 0 && s.Error == "" {
+			continue
+		}
+
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, s.Sdk+" "+s.Service+":")
+
+		if s.Error != "" {
+			fmt.Fprintln(w, "  "+strings.Replace(s.Error, "\n", "\n  ", -1))
+		}
+
+		for _, f := range s.Missing {
+			fmt.Fprintln(w, "  not in the metadata: "+f)
+		}
+
+		for _, f := range s.Stale {
+			fmt.Fprintln(w, "  not there:           "+f)
+		}
+	}
+}
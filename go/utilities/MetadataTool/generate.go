@@ -0,0 +1,380 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Doug-AWS/code-examples/go/utilities/metadata"
+)
+
+// servicePrefixes are the import paths of the SDK service packages; the service is the rest of the path
+var servicePrefixes = []string{
+	"github.com/aws/aws-sdk-go-v2/service/",
+	"github.com/aws/aws-sdk-go/service/",
+}
+
+// serviceImports returns the service of each SDK service package the file imports, by its name in the file
+func serviceImports(f *ast.File) map[string]string {
+	services := map[string]string{}
+
+	for _, imp := range f.Imports {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+
+		for _, prefix := range servicePrefixes {
+			if !strings.HasPrefix(p, prefix) {
+				continue
+			}
+
+			// Skip packages like service/s3/types
+			service := strings.TrimPrefix(p, prefix)
+			if strings.Contains(service, "/") {
+				continue
+			}
+
+			name := service
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+
+			if name != "_" && name != "." {
+				services[name] = service
+			}
+		}
+	}
+
+	return services
+}
+
+// calls finds the service actions one file calls
+type calls struct {
+	// The services imported, by package name
+	packages map[string]string
+	// The service of each variable that's a client, by name
+	clients map[string]string
+	// The service and action of each variable that's an input, by name
+	inputs map[string][2]string
+	// What's found, by service
+	found map[string]map[string]bool
+}
+
+func (c *calls) add(service, action string) {
+	if c.found[service] == nil {
+		c.found[service] = map[string]bool{}
+	}
+
+	c.found[service][action] = true
+}
+
+// serviceType returns the service and name of a type like *s3.Client or s3.ListBucketsInput
+func (c *calls) serviceType(e ast.Expr) (string, string) {
+	switch t := e.(type) {
+	case *ast.StarExpr:
+		return c.serviceType(t.X)
+	case *ast.UnaryExpr:
+		return c.serviceType(t.X)
+	case *ast.CompositeLit:
+		return c.serviceType(t.Type)
+	case *ast.SelectorExpr:
+		pkg, ok := t.X.(*ast.Ident)
+		if ok && c.packages[pkg.Name] != "" {
+			return c.packages[pkg.Name], t.Sel.Name
+		}
+	}
+
+	return "", ""
+}
+
+// input returns the service and action of an argument that's an operation's input
+func (c *calls) input(e ast.Expr) (string, string) {
+	id, ok := e.(*ast.Ident)
+	if ok {
+		in, ok := c.inputs[id.Name]
+		if ok {
+			return in[0], in[1]
+		}
+
+		return "", ""
+	}
+
+	service, name := c.serviceType(e)
+	if service != "" && strings.HasSuffix(name, "Input") {
+		return service, strings.TrimSuffix(name, "Input")
+	}
+
+	return "", ""
+}
+
+// declare records the type of a variable, if it's a client or an input
+func (c *calls) declare(name string, value ast.Expr) {
+	// A call like s3.NewFromConfig(cfg) or s3.New(sess)
+	call, ok := value.(*ast.CallExpr)
+	if ok {
+		service, fn := c.serviceType(call.Fun)
+		if service != "" && (fn == "NewFromConfig" || fn == "New") {
+			c.clients[name] = service
+		}
+
+		return
+	}
+
+	service, typ := c.serviceType(value)
+	if service == "" {
+		return
+	}
+
+	switch {
+	case typ == "Client":
+		c.clients[name] = service
+	case strings.HasSuffix(typ, "Input"):
+		c.inputs[name] = [2]string{service, strings.TrimSuffix(typ, "Input")}
+	}
+}
+
+func (c *calls) Visit(n ast.Node) ast.Visitor {
+	switch n := n.(type) {
+	case *ast.AssignStmt:
+		if len(n.Lhs) == len(n.Rhs) {
+			for i, lhs := range n.Lhs {
+				id, ok := lhs.(*ast.Ident)
+				if ok {
+					c.declare(id.Name, n.Rhs[i])
+				}
+			}
+		}
+
+	case *ast.ValueSpec:
+		for i, id := range n.Names {
+			if n.Type != nil {
+				c.declare(id.Name, n.Type)
+			} else if i < len(n.Values) {
+				c.declare(id.Name, n.Values[i])
+			}
+		}
+
+	case *ast.Field:
+		// Parameters and struct fields like client *s3.Client
+		for _, id := range n.Names {
+			c.declare(id.Name, n.Type)
+		}
+
+	case *ast.InterfaceType:
+		// The interfaces the examples call through, with methods like
+		// ListBuckets(ctx context.Context, params *s3.ListBucketsInput, optFns ...func(*s3.Options))
+		for _, m := range n.Methods.List {
+			fn, ok := m.Type.(*ast.FuncType)
+			if !ok || len(m.Names) == 0 {
+				continue
+			}
+
+			for _, param := range fn.Params.List {
+				service, action := c.input(param.Type)
+				if service != "" && action == m.Names[0].Name {
+					c.add(service, action)
+				}
+			}
+		}
+
+		// Don't record the method parameters as inputs
+		return nil
+
+	case *ast.CallExpr:
+		sel, ok := n.Fun.(*ast.SelectorExpr)
+		if !ok {
+			break
+		}
+
+		// A paginator, such as s3.NewListObjectsV2Paginator(client, params)
+		service, fn := c.serviceType(sel)
+		if service != "" {
+			if strings.HasPrefix(fn, "New") && strings.HasSuffix(fn, "Paginator") {
+				c.add(service, strings.TrimSuffix(strings.TrimPrefix(fn, "New"), "Paginator"))
+			}
+
+			break
+		}
+
+		// A call on a client, such as client.PutObject(...), but not client.Options()
+		id, ok := sel.X.(*ast.Ident)
+		if ok && c.clients[id.Name] != "" && ast.IsExported(sel.Sel.Name) && sel.Sel.Name != "Options" {
+			c.add(c.clients[id.Name], sel.Sel.Name)
+			break
+		}
+
+		// A call with an input for the same action, such as api.PutObject(ctx, input)
+		for _, arg := range n.Args {
+			service, action := c.input(arg)
+			if service != "" && action == sel.Sel.Name {
+				c.add(service, action)
+			}
+		}
+	}
+
+	return c
+}
+
+// FindCalls returns the services and actions the Go source calls, sorted
+func FindCalls(filename string, src []byte) ([]metadata.Service, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), filename, src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &calls{
+		packages: serviceImports(f),
+		clients:  map[string]string{},
+		inputs:   map[string][2]string{},
+		found:    map[string]map[string]bool{},
+	}
+
+	if len(c.packages) == 0 {
+		return nil, nil
+	}
+
+	// Twice, so variables declared after the functions that use them are known
+	ast.Walk(c, f)
+	ast.Walk(c, f)
+
+	services := []metadata.Service{}
+
+	for service, actions := range c.found {
+		s := metadata.Service{Service: service, Actions: []string{}}
+		for a := range actions {
+			s.Actions = append(s.Actions, a)
+		}
+
+		sort.Strings(s.Actions)
+		services = append(services, s)
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Service < services[j].Service
+	})
+
+	return services, nil
+}
+
+// GenerateFiles returns an entry for each Go example under dir that calls the SDK,
+// with paths relative to dir
+func GenerateFiles(dir string) ([]metadata.File, error) {
+	files := []metadata.File{}
+
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if p != dir && (skipDirs[info.Name()] || strings.HasPrefix(info.Name(), ".")) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !strings.HasSuffix(info.Name(), ".go") || strings.HasSuffix(info.Name(), "_test.go") {
+			return nil
+		}
+
+		src, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		services, err := FindCalls(p, src)
+		if err != nil {
+			return err
+		}
+
+		if len(services) == 0 {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, metadata.File{Path: filepath.ToSlash(rel), Services: services})
+
+		return nil
+	})
+
+	return files, err
+}
+
+// MergeFiles adds the generated entries to the metadata and returns how many files and actions it added.
+// The descriptions, links, and services already in the metadata are kept;
+// generated actions are added after the ones that are there.
+func MergeFiles(m *metadata.Metadata, generated []metadata.File) (int, int) {
+	addedFiles, addedActions := 0, 0
+
+	byPath := map[string]int{}
+	for i, f := range m.Files {
+		byPath[path.Clean(f.Path)] = i
+	}
+
+	for _, g := range generated {
+		i, ok := byPath[path.Clean(g.Path)]
+		if !ok {
+			m.Files = append(m.Files, g)
+			byPath[path.Clean(g.Path)] = len(m.Files) - 1
+
+			addedFiles++
+			for _, s := range g.Services {
+				addedActions += len(s.Actions)
+			}
+
+			continue
+		}
+
+		f := &m.Files[i]
+
+		for _, gs := range g.Services {
+			found := false
+
+			for j := range f.Services {
+				s := &f.Services[j]
+				if s.Service != gs.Service {
+					continue
+				}
+
+				found = true
+
+				for _, a := range gs.Actions {
+					if !contains(s.Actions, a) {
+						s.Actions = append(s.Actions, a)
+						addedActions++
+					}
+				}
+			}
+
+			if !found {
+				f.Services = append(f.Services, gs)
+				addedActions += len(gs.Actions)
+			}
+		}
+	}
+
+	return addedFiles, addedActions
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/Doug-AWS/code-examples/go/utilities/metadata"
+)
+
+// skipDirs are the directories that never have metadata in them
+var skipDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	"vendor":       true,
+}
+
+// isMetadataFile returns whether the file name is one the tools read metadata from
+func isMetadataFile(name string) bool {
+	return name == "metadata.yaml" || name == ".metadata.yaml"
+}
+
+// FindMetadata returns the metadata files under root, sorted
+func FindMetadata(root string) ([]string, error) {
+	files := []string{}
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if path != root && skipDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if isMetadataFile(info.Name()) {
+			files = append(files, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// Problem is a diagnostic in a metadata file
+type Problem struct {
+	File string
+	metadata.Diagnostic
+}
+
+func (p Problem) String() string {
+	return p.File + ":" + p.Diagnostic.String()
+}
+
+// LintFile returns the problems in the metadata file,
+// including paths that aren't there relative to the file.
+// A file without a version line is read as the version of the language it's in.
+func LintFile(path string) ([]Problem, error) {
+	problems := []Problem{}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := metadata.LoadAs(path, metadata.DefaultVersion(abs))
+	if err != nil {
+		var e *metadata.Error
+		if !errors.As(err, &e) {
+			return nil, err
+		}
+
+		for _, d := range e.Diagnostics {
+			problems = append(problems, Problem{File: path, Diagnostic: d})
+		}
+
+		return problems, nil
+	}
+
+	dir := filepath.Dir(path)
+
+	for _, f := range m.Files {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(f.Path)))
+		if os.IsNotExist(err) {
+			problems = append(problems, Problem{
+				File:       path,
+				Diagnostic: metadata.Diagnostic{Line: f.Line, Column: f.Column, Message: "The file " + f.Path + " does not exist"},
+			})
+		}
+	}
+
+	return problems, nil
+}
+
+// Lint checks every metadata file under root
+// and returns the problems in them and how many files it checked
+func Lint(root string) ([]Problem, int, error) {
+	files, err := FindMetadata(root)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	problems := []Problem{}
+
+	for _, f := range files {
+		p, err := LintFile(f)
+		if err != nil {
+			return nil, 0, errors.New("Got an error reading " + f + ": " + err.Error())
+		}
+
+		problems = append(problems, p...)
+	}
+
+	return problems, len(files), nil
+}
+
+// lintSummary describes the result of Lint
+func lintSummary(problems []Problem, checked int) string {
+	files := map[string]bool{}
+	for _, p := range problems {
+		files[p.File] = true
+	}
+
+	return "Checked " + strconv.Itoa(checked) + " metadata files: " +
+		strconv.Itoa(len(problems)) + " problems in " + strconv.Itoa(len(files)) + " files"
+}
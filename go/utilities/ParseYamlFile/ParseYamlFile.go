@@ -6,21 +6,9 @@ import (
 	"fmt"
 	"net/http"
 
-	"gopkg.in/yaml.v2"
+	"github.com/Doug-AWS/code-examples/go/utilities/metadata"
 )
 
-// Metadata defines the elements in metatdata.yaml
-type Metadata struct {
-	Files []struct {
-		Path        string `yaml:"path"`
-		Description string `yaml:"description"`
-		Services    []struct {
-			Service string   `yaml:"service"`
-			Actions []string `yaml:"actions"`
-		} `yaml:"services"`
-	} `yaml:"files"`
-}
-
 func debugPrint(debug bool, s string) {
 	if debug {
 		fmt.Println(s)
@@ -36,8 +24,6 @@ func main() {
 
 	fmt.Println("Parsing " + path)
 
-	var metadata Metadata
-
 	results, err := http.Get(path)
 	if err != nil {
 		fmt.Println("Got an error getting the file:")
@@ -50,9 +36,9 @@ func main() {
 	buf.ReadFrom(results.Body)
 	bytes := buf.Bytes()
 
-	err = yaml.Unmarshal(bytes, &metadata)
+	meta, err := metadata.ParseAs(bytes, metadata.DefaultVersion(path))
 	if err != nil {
-		fmt.Println("Got an error unmarshalling:")
+		fmt.Println("Got an error parsing the metadata:")
 		fmt.Println(err)
 		return
 	}
@@ -60,12 +46,12 @@ func main() {
 	debugPrint(debug, "Unmarshalled data for "+path)
 	if debug {
 		fmt.Println("Data:")
-		fmt.Println(metadata)
+		fmt.Println(meta)
 	}
 
 	// Iterate through files.
 	// Create a link and tab for services/operations
-	for _, data := range metadata.Files {
+	for _, data := range meta.Files {
 		debugPrint(debug, "")
 		debugPrint(debug, "Path:        "+data.Path)
 		debugPrint(debug, "Description: "+data.Description)
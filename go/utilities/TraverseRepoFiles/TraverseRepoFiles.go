@@ -10,39 +10,33 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 
-	"gopkg.in/yaml.v2"
+	"github.com/Doug-AWS/code-examples/go/dynamodb/registry"
+	"github.com/Doug-AWS/code-examples/go/utilities/metadata"
 )
 
-// Data represents the entries in metadata.yaml
-type Data struct {
-	Files []struct {
-		Path        string   `yaml:"path"`
-		Description string   `yaml:"description"`
-		Services    []string `yaml:"services"`
-		Operations  []string `yaml:"operations"`
-	} `yaml:"files"`
-}
-
 // Global struct to hold metadata for gov2 and gov2/<service> folders
-var files Data
+var files = &metadata.Metadata{}
+
+func populateFiles(debug bool, getter FileGetter, layout *Layout, path string) error {
+	debugPrint(debug, "Getting metadata from "+path)
+	b, err := getter.GetFile(path)
+	if err == errNotFound {
+		debugPrint(debug, "There is no "+path)
+		files = &metadata.Metadata{}
+		return nil
+	}
 
-func populateFiles(debug bool, path string) error {
-	filePrefix := "https://raw.githubusercontent.com/awsdocs/aws-doc-sdk-examples/master/"
-	debugPrint(debug, "Getting metadata from "+filePrefix+path)
-	results, err := http.Get(filePrefix + path)
 	if err != nil {
 		return err
 	}
 
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(results.Body)
-	text := buf.String()
-
-	err = yaml.Unmarshal([]byte(text), &files)
+	// Some metadata, such as gov2's, is in the older format, without a version line
+	files, err = metadata.ParseAs(b, layout.MetadataVersion)
 	if err != nil {
-		fmt.Println("Got an error unmarshalling " + filePrefix + path)
+		fmt.Println("Got an error parsing " + path)
 		return err
 	}
 
@@ -52,17 +46,20 @@ func populateFiles(debug bool, path string) error {
 // RepoTree represents the files in a repo
 // See https://docs.github.com/en/free-pro-team@latest/rest/reference/git#trees
 type RepoTree struct {
-	Sha  string `json:"sha"` // Like a checksum for the request
-	URL  string `json:"url"` // The API URL for the request
-	Tree []struct {
-		Path string `json:"path"` // The path to the directory/file after https://github.com/awsdocs/aws-doc-sdk-examples/blob/master/
-		Mode string `json:"mode"` // The file permissions. Most folders are ; files 100644
-		Type string `json:"type"` // "blob" for files; "tree" for directories
-		Sha  string `json:"sha"`  // Like a checksum for the directory/file
-		Size int    `json:"size"` // The size, in bytes, of files
-		URL  string `json:"url"`  // The API URL for the file
-	} `json:"tree"`
-	Truncated bool `json:"truncated"` // Whether the request was truncated (more to come)
+	Sha       string      `json:"sha"`       // Like a checksum for the request
+	URL       string      `json:"url"`       // The API URL for the request
+	Tree      []RepoEntry `json:"tree"`      // The directories and files
+	Truncated bool        `json:"truncated"` // Whether the request was truncated (more to come)
+}
+
+// RepoEntry is a directory or file in a RepoTree
+type RepoEntry struct {
+	Path string `json:"path"`          // The path to the directory/file after https://github.com/awsdocs/aws-doc-sdk-examples/blob/master/
+	Mode string `json:"mode"`          // The file permissions. Most folders are 040000; files 100644
+	Type string `json:"type"`          // "blob" for files; "tree" for directories
+	Sha  string `json:"sha,omitempty"` // Like a checksum for the directory/file
+	Size int    `json:"size"`          // The size, in bytes, of files
+	URL  string `json:"url,omitempty"` // The API URL for the file
 }
 
 func debugPrint(debug bool, s string) {
@@ -72,42 +69,27 @@ func debugPrint(debug bool, s string) {
 }
 
 func isValueLanguage(debug bool, lang string) bool {
-	for _, l := range globalConfig.Languages {
-		if lang == l {
-			return true
-		}
-	}
-
-	return false
+	return lang == "all" || findLayout(lang) != nil
 }
 
-// This only works for Go currently
+// registryFile is the registry of service names, relative to this directory
+const registryFile = "../../dynamodb/registry/registry.yaml"
+
+// globalRegistry has the names of the services
+var globalRegistry *registry.Registry
+
+// Service directories are lowercase in most languages, but not all, such as dotnetv3/SNS
 func getNameForSvcDir(debug bool, dir string) string {
-	switch dir {
-	case "cloudwatch":
-		return "Amazon CloudWatch"
-	case "dynamodb":
-		return "Amazon DynamoDB"
-	case "ec2":
-		return "Amazon EC2"
-	case "iam":
-		return "IAM"
-	case "kms":
-		return "AWS KMS"
-	case "s3":
-		return "Amazon S3"
-	case "sns":
-		return "Amazon SNS"
-	case "ssm":
-		return "AWS Systems Manager"
-	case "sts":
-		return "AWS STS"
-	default:
+	e, err := globalRegistry.Service(strings.ToLower(dir))
+	if err != nil {
+		debugPrint(debug, err.Error())
 		return ""
 	}
+
+	return e.Name
 }
 
-func createSvcDir(debug bool, language string, dir string, outDir string, translation string) error {
+func createSvcDir(debug bool, layout *Layout, dir string, outDir string, translation string) error {
 	name := getNameForSvcDir(debug, dir)
 	if name == "" {
 		msg := dir + " is not recognized as a valid service directory"
@@ -150,16 +132,16 @@ func createSvcDir(debug bool, language string, dir string, outDir string, transl
 	case "index":
 		// Here's what we write to [<service>]_index.md
 		content = "---\n" +
-			"title: \"" + name + " Examples Using the AWS SDK for Go\"\n" +
+			"title: \"" + name + " Examples Using the " + layout.SdkTitle + "\"\n" +
 			"linkTitle: \"" + name + " Examples\"\n" +
 			"weight: 3\n" +
 			"---\n" +
 			"\n" +
-			"# This section contains code examples for " + name + " using version 2 of the Go SDK.\n"
+			"# This section contains code examples for " + name + " using " + layout.SdkLong + ".\n"
 
 		break
 	default:
-		content = "# This section contains code examples for " + name + " using version 2 of the Go SDK.\n"
+		content = "# This section contains code examples for " + name + " using " + layout.SdkLong + ".\n"
 		// Add after updating metadata.yaml with description, operations:
 		//   1. Get all paths that don't end in _test.go -> ## path
 		//   2. Get description for that entry           -> Description
@@ -174,7 +156,7 @@ func createSvcDir(debug bool, language string, dir string, outDir string, transl
 
 	/* If we ever want to add the contents of the README.md file in that folder:
 	filePrefix := "https://raw.githubusercontent.com/awsdocs/aws-doc-sdk-examples/master/"
-	path := filePrefix + language + "/" + dir + "/" + "README.md"
+	path := filePrefix + layout.ServiceDir + "/" + dir + "/" + "README.md"
 	results, err := http.Get(path)
 	if err != nil {
 		return err
@@ -206,48 +188,49 @@ func createOperationDir(debug bool, dir string, subdir string, outDir string) er
 	return err
 }
 
-func getNameFromMetadata(debug bool, subdir string, file string) (string, error) {
-	// Wade through files
-	// If subdir/file == path, return operation[0], if it exists
+// mdName returns the name of the file with its extension replaced by .md
+func mdName(file string) string {
+	return strings.TrimSuffix(file, filepath.Ext(file)) + ".md"
+}
+
+// getNameFromMetadata returns the name of the topic for the file at relPath in the service directory,
+// which is its first action in the metadata, or the name of the file with .md if it doesn't have one
+func getNameFromMetadata(debug bool, relPath string, file string) string {
 	for _, f := range files.Files {
 		debugPrint(debug, "Looking at metadata path: "+f.Path)
 
-		// Until we get operations for all Go v2 service metadata files
-		if f.Path == subdir+"/"+file {
-			if nil != f.Operations && f.Operations[0] != "" {
-				return f.Operations[0], nil
-			}
+		// Until we get actions for all Go v2 service metadata files
+		if f.Path == relPath && len(f.Services) > 0 && len(f.Services[0].Actions) > 0 {
+			return f.Services[0].Actions[0] + ".md"
 		}
-
-		debugPrint(debug, "No operations for "+subdir+"/"+file)
-		// Just return the filename, with .go replace by .md
-		file = strings.Replace(file, ".go", ".md", 1)
-		return file, nil
 	}
 
-	return "", nil
-}
+	debugPrint(debug, "No actions for "+relPath)
 
-func createFile(debug bool, dir string, subdir string, fileName string, path string, outDir string, translation string) error {
-	filePrefix := "https://raw.githubusercontent.com/awsdocs/aws-doc-sdk-examples/master/"
-	linkPrefix := "https://github.com/awsdocs/aws-doc-sdk-examples/blob/master/gov2/"
-	linkFile := linkPrefix + dir + "/" + subdir + "/" + fileName
+	return mdName(file)
+}
 
-	filePath := filePrefix + path
+// createFile creates the topic for a source file in topicDir, relative to outDir,
+// such as s3/ListBuckets for gov2/s3/ListBuckets/ListBucketsv2.go
+func createFile(debug bool, getter FileGetter, topicDir string, relPath string, fileName string, path string, outDir string, translation string) error {
+	linkPrefix := "https://github.com/awsdocs/aws-doc-sdk-examples/blob/master/"
+	linkFile := linkPrefix + path
 
 	// Is there a README.md file in the same directory?
-	//   filePath is something like:
-	//     https://raw.githubusercontent.com/awsdocs/aws-doc-sdk-examples/master/gov2/cloudwatch/DescribeAlarms/DescribeAlarmsv2.go
+	//   path is something like:
+	//     gov2/cloudwatch/DescribeAlarms/DescribeAlarmsv2.go
 	// So split the string by '/', get the last part, and replace it with README.md.
-	mdFilePath := strings.Replace(filePath, fileName, "README.md", 1)
+	mdFilePath := strings.Replace(path, fileName, "README.md", 1)
 
 	// Snarf README.md and use it to start building the local file
-	results, err := http.Get(mdFilePath)
-	if err != nil {
+	readme, err := getter.GetFile(mdFilePath)
+	if err == errNotFound {
+		debugPrint(debug, "There is no "+mdFilePath)
+	} else if err != nil {
 		return err
 	}
 
-	outFileName := outDir + "/" + dir + "/" + subdir
+	outFileName := outDir + "/" + topicDir
 
 	// The file to create
 	switch translation {
@@ -255,24 +238,19 @@ func createFile(debug bool, dir string, subdir string, fileName string, path str
 		outFileName += "/_index.md"
 		break
 	case "metadata":
-		name, err := getNameFromMetadata(debug, subdir, fileName)
-		if err != nil {
-			return err
-		}
-
+		name := getNameFromMetadata(debug, relPath, fileName)
 		debugPrint(debug, "Name from metadata: "+name)
 
 		outFileName += "/" + name
 		break
 	default: // none
-		fileName = strings.Replace(fileName, ".go", ".md", 1)
-		outFileName += "/" + fileName
+		outFileName += "/" + mdName(fileName)
 	}
 
 	debugPrint(debug, "Creating output file: "+outFileName)
 
 	// Read each line of readme file from repo
-	scanner := bufio.NewScanner(results.Body)
+	scanner := bufio.NewScanner(bytes.NewReader(readme))
 
 	f, err := os.OpenFile(outFileName, os.O_RDWR|os.O_CREATE, 0644)
 	if err != nil {
@@ -300,12 +278,12 @@ func createFile(debug bool, dir string, subdir string, fileName string, path str
 	}
 
 	// Get contents of repo file
-	resp, err := http.Get(filePath)
+	source, err := getter.GetFile(path)
 	if err != nil {
 		return err
 	}
 
-	scanner = bufio.NewScanner(resp.Body)
+	scanner = bufio.NewScanner(bytes.NewReader(source))
 
 	// Start of code
 	_, err = f.Write([]byte("```\n"))
@@ -339,10 +317,10 @@ func createFile(debug bool, dir string, subdir string, fileName string, path str
 	return nil
 }
 
-func processFiles(debug bool, language string, input string, outDir string, translation string) error {
-	// We're only looking at gov2 paths for now
-	if language != "gov2" {
-		return errors.New("Sorry, only gov2 is currently supported as a language")
+func processFiles(debug bool, getter FileGetter, language string, input string, outDir string, translation string) error {
+	layout := findLayout(language)
+	if layout == nil {
+		return errors.New("There is no layout for the language " + language)
 	}
 
 	// Unmarshal string into tree
@@ -351,112 +329,122 @@ func processFiles(debug bool, language string, input string, outDir string, tran
 	json.Unmarshal([]byte(input), &repoTree)
 
 	svcDir := ""
-	subDir := ""
+	skipDir := ""
 
 	for _, leaf := range repoTree.Tree {
 		// leaf.Path for a file looks like:
 		//   gov2/cloudwatch/CreateCustomMetric/CreateCustomMetricv2.go
+		//   python/example_code/sqs/queue_wrapper.py
 		debugPrint(debug, "Processing path: "+leaf.Path)
 
-		if leaf.Path == language {
+		if leaf.Path == layout.ServiceDir {
 			if translation == "metadata" {
 				// Read metadata so we can name output files correctly
 				debugPrint(debug, "Processing "+leaf.Path+"/metadata.yaml")
-				err := populateFiles(debug, leaf.Path+"/metadata.yaml")
+				err := populateFiles(debug, getter, layout, leaf.Path+"/metadata.yaml")
 				if err != nil {
 					return err
 				}
 			}
 		}
 
-		// Split up the path '/'
-		parts := strings.Split(leaf.Path, "/")
-		// parts[0] == gov2
-		// parts[1] == cloudwatch
-		// parts[2] == CreateCustomeMetric
-		// parts[3] == CreateCustomMetricv2.go
+		// The path after the service directory, split up by '/'
+		rel, ok := layout.servicePath(leaf.Path)
+		if !ok {
+			continue
+		}
 
-		if parts[0] != language {
+		parts := strings.Split(rel, "/")
+		// parts[0] == cloudwatch
+		// parts[1] == CreateCustomMetric
+		// parts[2] == CreateCustomMetricv2.go
+
+		if parts[0] == skipDir {
 			continue
 		}
 
-		switch len(parts) {
-		case 2:
-			debugPrint(debug, "Looking at a path with 2 parts")
+		if len(parts) == 1 {
 			// We have something like:
 			//     gov2/cloudwatch or gov2/.gitignore
 			// so skip files
-			if leaf.Type == "blob" {
-				break
+			if leaf.Type == "blob" || svcDir == parts[0] {
+				continue
 			}
 
-			if svcDir != parts[1] {
-				if translation == "metadata" {
-					// Read metadata so we can:
-					// * name operation topic files correctly when metadata-based naming scheme is specified
-					// * create list of code examples for service-level topic
-					debugPrint(debug, "Processing "+parts[0]+"/"+parts[1]+"/metadata.yaml")
-					err := populateFiles(debug, parts[0]+"/"+parts[1]+"/metadata.yaml")
-					if err != nil {
-						return err
-					}
-				}
+			if getNameForSvcDir(debug, parts[0]) == "" {
+				fmt.Println("Skipping " + leaf.Path + ", as " + parts[0] + " is not recognized as a valid service directory")
+				skipDir = parts[0]
+				continue
+			}
 
-				err := createSvcDir(debug, language, parts[1], outDir, translation)
+			if translation == "metadata" {
+				// Read metadata so we can:
+				// * name operation topic files correctly when metadata-based naming scheme is specified
+				// * create list of code examples for service-level topic
+				debugPrint(debug, "Processing "+leaf.Path+"/metadata.yaml")
+				err := populateFiles(debug, getter, layout, leaf.Path+"/metadata.yaml")
 				if err != nil {
 					return err
 				}
+			}
 
-				svcDir = parts[1]
+			err := createSvcDir(debug, layout, parts[0], outDir, translation)
+			if err != nil {
+				return err
 			}
 
-			break
-		case 3:
+			svcDir = parts[0]
+
+			continue
+		}
+
+		if parts[0] != svcDir {
+			continue
+		}
+
+		if leaf.Type != "blob" {
 			// Now we have entries like:
 			//     gov2/cloudwatch/CreateCustomMetric
-			// Make sure we trap any files at this level
-			// Files are of type "blob"
-			if leaf.Type == "blob" {
-				break
-			}
-
-			if subDir != parts[2] {
-				err := createOperationDir(debug, svcDir, parts[2], outDir)
+			// which get a directory for their topics if the operations have their own directories
+			if layout.Operations && len(parts) == 2 {
+				err := createOperationDir(debug, svcDir, parts[1], outDir)
 				if err != nil {
 					return err
 				}
-
-				subDir = parts[2]
 			}
 
-			break
-		case 4:
-			// Now we have entries like:
-			//     gov2/cloudwatch/CreateCustomMetric/CreateCustomMetricv2.go
-			//       parts[0] == gov2
-			//       parts[1] == cloudwatch
-			//       parts[2] == CreateCustomeMetric
-			//       parts[3] == CreateCustomMetricv2.go
-			//     gov2/cloudwatch/CreateCustomMetric/CreateCustomMetricv2_test.go
-			//     gov2/cloudwatch/CreateCustomMetric/config.json
-			// We only care about *.go, but not _test.go
-			if strings.Contains(parts[3], "_test.go") {
-				break
-			}
+			continue
+		}
 
-			if strings.Contains(parts[3], ".go") {
-				// For paths like:
-				//     gov2/cloudwatch/CreateCustomMetric/CreateCustomMetricv2.go
-				err := createFile(debug, svcDir, subDir, parts[3], leaf.Path, outDir, translation)
-				if err != nil {
-					return err
-				}
+		// Now we have entries like:
+		//     gov2/cloudwatch/CreateCustomMetric/CreateCustomMetricv2.go
+		//     gov2/cloudwatch/CreateCustomMetric/CreateCustomMetricv2_test.go
+		//     gov2/cloudwatch/CreateCustomMetric/config.json
+		// We only care about sources, but not tests
+		fileName := parts[len(parts)-1]
+		if !layout.IsSource(fileName) {
+			continue
+		}
+
+		topicDir := svcDir
+		if layout.Operations {
+			// Files directly in the service directory, like gov2/cloudwatch/main.go, don't have an operation
+			if len(parts) < 3 {
+				continue
 			}
 
-			break
+			topicDir += "/" + parts[1]
+		}
 
-		default:
-			break
+		// Each operation directory has one _index.md, so it's only used when there are operation directories
+		fileTranslation := translation
+		if translation == "index" && !layout.Operations {
+			fileTranslation = "none"
+		}
+
+		err := createFile(debug, getter, topicDir, strings.Join(parts[1:], "/"), fileName, leaf.Path, outDir, fileTranslation)
+		if err != nil {
+			return err
 		}
 	}
 
@@ -465,26 +453,64 @@ func processFiles(debug bool, language string, input string, outDir string, tran
 
 func usage() {
 	fmt.Println("Usage:")
-	fmt.Println("    go run GetGitRepoFiles.go [-u NAME] [-l Language] [-o OUTPUT-DIR] [-d] [-h] [-t]")
+	fmt.Println("    go run GetGitRepoFiles.go [-u NAME | -r ROOT] [-l Language] [-o OUTPUT-DIR] [-registry REGISTRY] [-d] [-h] [-t]")
 	fmt.Println(" where:")
 	fmt.Println("    NAME      is the name of the GitHub user used to the GitHub API")
 	fmt.Println("              the default is the value of UserName in config.json")
-	fmt.Println("    LANGUAGE  is the programming language directory in our GitHub repo, such as gov2")
+	fmt.Println("    ROOT      is a local checkout of the repo to use instead of GitHub")
+	fmt.Println("              the default is the value of Root in config.json")
+	fmt.Println("    LANGUAGE  is the programming language directory in our GitHub repo, such as gov2,")
+	fmt.Println("              or all, to create the topics for each language in OUT-DIR/LANGUAGE")
 	fmt.Println("              the default is the value of Language in config.json")
 	fmt.Println("    OUT-DIR   specifies where the code example topics are saved")
 	fmt.Println("              the default is the value of OutDir in config.json")
+	fmt.Println("    REGISTRY  is the YAML file with the names of the services")
+	fmt.Println("              the default is " + registryFile)
 	fmt.Println("    -d        displays additional debugging information")
 	fmt.Println("    -h        displays this error message and quits")
 	fmt.Println("    -t        displays the response as JSON and quits")
 }
 
+// getRemoteTree gets the files in the repo from the GitHub trees API
+func getRemoteTree(debug bool, userName string) ([]byte, error) {
+	gitHubURL := "https://api.github.com"
+	query := gitHubURL + "/repos/awsdocs/aws-doc-sdk-examples/git/trees/master?recursive=1"
+
+	debugPrint(debug, "Querying: ")
+	debugPrint(debug, query)
+
+	jsonData := ""
+	jsonValue, _ := json.Marshal(jsonData)
+
+	request, err := http.NewRequest("GET", query, bytes.NewBuffer(jsonValue))
+	if err != nil {
+		return nil, errors.New("Got an error creating HTTP request: " + err.Error())
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/vnd.github.v3+json")
+
+	request.SetBasicAuth(userName, "")
+
+	client := &http.Client{}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, errors.New("The HTTP request failed with error " + err.Error())
+	}
+
+	defer response.Body.Close()
+
+	return ioutil.ReadAll(response.Body)
+}
+
 // Config defines the configuration values from config.json
 type Config struct {
-	UserName    string   `json:"UserName"`
-	Languages   []string `json:"Languages"`
-	Language    string   `json:"Language"`
-	OutDir      string   `json:"OutDir"`
-	Translation string   `json:"Translation"`
+	UserName    string `json:"UserName"`
+	Root        string `json:"Root"`
+	Language    string `json:"Language"`
+	OutDir      string `json:"OutDir"`
+	Translation string `json:"Translation"`
 }
 
 var configFileName = "config.json"
@@ -517,20 +543,29 @@ func main() {
 	}
 
 	userName := flag.String("u", globalConfig.UserName, "Your GitHub user name")
+	root := flag.String("r", globalConfig.Root, "A local checkout of the repo to read instead of GitHub")
 	language := flag.String("l", globalConfig.Language, "The language in the GitHub repo")
 	outDir := flag.String("o", globalConfig.OutDir, "Root directory where the output files are created")
 	translation := flag.String("t", globalConfig.Translation, "Whether to translate source filename -> destination filename; valid values are none (just use existing filename), index (default; translate everything to _index.md), or metadata (use name from metadata.yaml)")
 	debug := flag.Bool("d", false, "Whether to barf out more info. False by default.")
 	fakeIt := flag.Bool("f", false, "Whether to just barf out the response")
+	registryPath := flag.String("registry", registryFile, "The YAML file with the names of the services")
 	help := flag.Bool("h", false, "Displays usage and quits")
 	flag.Parse()
 
+	globalRegistry, err = registry.Load(*registryPath)
+	if err != nil {
+		fmt.Println("Got an error loading the registry " + *registryPath + ":")
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
 	if *help {
 		usage()
 		return
 	}
 
-	if *userName == "" || *language == "" || *outDir == "" {
+	if (*userName == "" && *root == "") || *language == "" || *outDir == "" {
 		usage()
 		return
 	}
@@ -538,13 +573,14 @@ func main() {
 	if *debug {
 		fmt.Println("Debugging enabled")
 		fmt.Println("User:        " + *userName)
+		fmt.Println("Root:        " + *root)
 		fmt.Println("Language:    " + *language)
 		fmt.Println("Output dir:  " + *outDir)
 		fmt.Println("Translation: " + globalConfig.Translation)
 	}
 
 	if !isValueLanguage(*debug, *language) {
-		fmt.Println("The language directory " + *language + " is not present in the repo")
+		fmt.Println("The language " + *language + " is not all or one of: " + strings.Join(languageNames(), ", "))
 		return
 	}
 
@@ -553,37 +589,31 @@ func main() {
 		return
 	}
 
-	gitHubURL := "https://api.github.com"
-	query := gitHubURL + "/repos/awsdocs/aws-doc-sdk-examples/git/trees/master?recursive=1"
-
-	debugPrint(*debug, "Querying: ")
-	debugPrint(*debug, query)
-
-	jsonData := ""
-	jsonValue, _ := json.Marshal(jsonData)
-
-	request, err := http.NewRequest("GET", query, bytes.NewBuffer(jsonValue))
-	if err != nil {
-		fmt.Println("Got an error creating HTTP request:")
-		fmt.Println(err)
-		return
-	}
+	var data []byte
+	var getter FileGetter
 
-	request.Header.Set("Content-Type", "application/json")
-	request.Header.Set("Accept", "application/vnd.github.v3+json")
+	if *root != "" {
+		debugPrint(*debug, "Reading the checkout in "+*root)
 
-	request.SetBasicAuth(*userName, "")
+		tree, err := LocalTree(*root)
+		if err != nil {
+			fmt.Println("Got an error reading " + *root + ":")
+			fmt.Println(err)
+			return
+		}
 
-	client := &http.Client{}
+		data, _ = json.Marshal(tree)
+		getter = localFiles{root: *root}
+	} else {
+		data, err = getRemoteTree(*debug, *userName)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
 
-	response, err := client.Do(request)
-	if err != nil {
-		fmt.Printf("The HTTP request failed with error %s\n", err)
-		return
+		getter = newRemoteFiles()
 	}
 
-	data, _ := ioutil.ReadAll(response.Body)
-
 	var prettyJSON bytes.Buffer
 	error := json.Indent(&prettyJSON, data, "", "\t")
 	if error != nil {
@@ -598,9 +628,28 @@ func main() {
 		return
 	}
 
-	err = processFiles(*debug, *language, string(prettyJSON.Bytes()), *outDir, *translation)
-	if err != nil {
-		fmt.Println("Got an error processing files:")
-		fmt.Println(err)
+	if *language != "all" {
+		err = processFiles(*debug, getter, *language, string(prettyJSON.Bytes()), *outDir, *translation)
+		if err != nil {
+			fmt.Println("Got an error processing files:")
+			fmt.Println(err)
+		}
+
+		return
+	}
+
+	// Create the same topic tree for each language, in a directory named after it
+	for _, l := range languageNames() {
+		dir := *outDir + "/" + l
+
+		err = os.MkdirAll(dir, 0755)
+		if err == nil {
+			err = processFiles(*debug, getter, l, string(prettyJSON.Bytes()), dir, *translation)
+		}
+
+		if err != nil {
+			fmt.Println("Got an error processing " + l + " files:")
+			fmt.Println(err)
+		}
 	}
 }
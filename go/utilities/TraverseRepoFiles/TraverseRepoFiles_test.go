@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Doug-AWS/code-examples/go/dynamodb/registry"
+)
+
+func TestMain(m *testing.M) {
+	var err error
+
+	globalRegistry, err = registry.Load(registryFile)
+	if err != nil {
+		panic(err)
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestGetNameForSvcDir(t *testing.T) {
+	for dir, want := range map[string]string{
+		"s3":  "Amazon S3",
+		"SNS": "Amazon SNS",
+		"kms": "AWS Key Management Service (AWS KMS)",
+		"bin": "",
+	} {
+		got := getNameForSvcDir(false, dir)
+		if got != want {
+			t.Errorf("Got %q for %s, not %q", got, dir, want)
+		}
+	}
+}
+
+func TestLocalTree(t *testing.T) {
+	tree, err := LocalTree("testdata/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"gov2 tree",
+		"gov2/s3 tree",
+		"gov2/s3/CopyObject tree",
+		"gov2/s3/CopyObject/CopyObjectv2.go blob",
+		"gov2/s3/ListBuckets tree",
+		"gov2/s3/ListBuckets/ListBucketsv2.go blob",
+		"gov2/s3/ListBuckets/ListBucketsv2_test.go blob",
+		"gov2/s3/ListBuckets/README.md blob",
+		"gov2/s3/metadata.yaml blob",
+		"python tree",
+		"python/example_code tree",
+		"python/example_code/demo_tools tree",
+		"python/example_code/demo_tools/question.py blob",
+		"python/example_code/sqs tree",
+		"python/example_code/sqs/queue_wrapper.py blob",
+		"python/example_code/sqs/test_queue_wrapper.py blob",
+	}
+
+	if len(tree.Tree) != len(want) {
+		t.Fatalf("Got %+v", tree.Tree)
+	}
+
+	for i, e := range tree.Tree {
+		if e.Path+" "+e.Type != want[i] {
+			t.Errorf("Expected %s, got %s %s", want[i], e.Path, e.Type)
+		}
+	}
+}
+
+func TestProcessLocalFiles(t *testing.T) {
+	tree, err := LocalTree("testdata/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input, err := json.Marshal(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outDir := t.TempDir()
+
+	err = processFiles(false, localFiles{root: "testdata/repo"}, "gov2", string(input), outDir, "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"s3/s3_index.md": "# This section contains code examples for Amazon S3 using version 2 of the Go SDK.\n",
+		"s3/ListBuckets/ListBucketsv2.md": "# Amazon S3: ListBuckets\n\nThis example lists your buckets.\n\n" +
+			"## Source code\n\n```\npackage main\n\nfunc main() {\n}\n\n```\n\n" +
+			"See the [complete example in GitHub](https://github.com/awsdocs/aws-doc-sdk-examples/blob/master/gov2/s3/ListBuckets/ListBucketsv2.go).",
+		// Without a README.md
+		"s3/CopyObject/CopyObjectv2.md": "\n## Source code\n\n```\npackage main\n\nfunc main() {\n}\n```\n\n" +
+			"See the [complete example in GitHub](https://github.com/awsdocs/aws-doc-sdk-examples/blob/master/gov2/s3/CopyObject/CopyObjectv2.go).",
+	}
+
+	for name, content := range want {
+		b, err := ioutil.ReadFile(filepath.Join(outDir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if string(b) != content {
+			t.Errorf("Expected %s to be:\n%s\ngot:\n%s", name, content, b)
+		}
+	}
+
+	_, err = ioutil.ReadFile(filepath.Join(outDir, "s3", "ListBuckets", "ListBucketsv2_test.md"))
+	if err == nil {
+		t.Error("Expected the test file to be skipped")
+	}
+}
+
+func TestProcessLayouts(t *testing.T) {
+	tree, err := LocalTree("testdata/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input, err := json.Marshal(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outDir := t.TempDir()
+
+	// Python keeps all of a service's examples in its directory, so they don't get _index.md files
+	err = processFiles(false, localFiles{root: "testdata/repo"}, "python", string(input), outDir, "index")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"sqs/_index.md": "---\ntitle: \"Amazon SQS Examples Using the AWS SDK for Python (Boto3)\"\nlinkTitle: \"Amazon SQS Examples\"\nweight: 3\n---\n\n" +
+			"# This section contains code examples for Amazon SQS using the AWS SDK for Python (Boto3).\n",
+		"sqs/queue_wrapper.md": "\n## Source code\n\n```\ndef create_queue(name):\n    pass\n```\n\n" +
+			"See the [complete example in GitHub](https://github.com/awsdocs/aws-doc-sdk-examples/blob/master/python/example_code/sqs/queue_wrapper.py).",
+	}
+
+	for name, content := range want {
+		b, err := ioutil.ReadFile(filepath.Join(outDir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if string(b) != content {
+			t.Errorf("Expected %s to be:\n%s\ngot:\n%s", name, content, b)
+		}
+	}
+
+	// The test and the directory that isn't a service are skipped
+	for _, name := range []string{"sqs/test_queue_wrapper.md", "demo_tools"} {
+		_, err = os.Stat(filepath.Join(outDir, filepath.FromSlash(name)))
+		if !os.IsNotExist(err) {
+			t.Errorf("Expected no %s, got %v", name, err)
+		}
+	}
+
+	err = processFiles(false, localFiles{root: "testdata/repo"}, "cobol", string(input), outDir, "none")
+	if err == nil {
+		t.Error("Expected an error for a language without a layout")
+	}
+}
+
+func TestProcessMetadata(t *testing.T) {
+	tree, err := LocalTree("testdata/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input, err := json.Marshal(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The topics are named after the first action of each file in gov2/s3/metadata.yaml,
+	// and after the file in python, which has no metadata.yaml
+	want := map[string][]string{
+		"gov2":   {"s3/ListBuckets/ListBuckets.md", "s3/CopyObject/CopyObject.md"},
+		"python": {"sqs/queue_wrapper.md"},
+	}
+
+	for language, names := range want {
+		outDir := t.TempDir()
+
+		err = processFiles(false, localFiles{root: "testdata/repo"}, language, string(input), outDir, "metadata")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, name := range names {
+			_, err = os.Stat(filepath.Join(outDir, filepath.FromSlash(name)))
+			if err != nil {
+				t.Errorf("Expected %s for %s: %v", name, language, err)
+			}
+		}
+	}
+}
+
+func TestIsSource(t *testing.T) {
+	tests := map[string][]string{
+		"gov2":       {"ListBucketsv2.go", "!ListBucketsv2_test.go", "!README.md"},
+		"javav2":     {"ListBuckets.java", "!ListBucketsTest.java", "!S3ServiceIT.java"},
+		"dotnetv3":   {"ListTopics.cs", "!ListTopicsTests.cs"},
+		"typescript": {"s3_listbuckets.ts", "!s3_listbuckets.test.ts", "!types.d.ts"},
+	}
+
+	for language, names := range tests {
+		layout := findLayout(language)
+
+		for _, name := range names {
+			want := name[0] != '!'
+			name = strings.TrimPrefix(name, "!")
+
+			if layout.IsSource(name) != want {
+				t.Errorf("Expected %s to be a %s source: %v", name, language, want)
+			}
+		}
+	}
+}
+
+func TestSdkNames(t *testing.T) {
+	for _, language := range languageNames() {
+		layout := findLayout(language)
+		if layout.SdkTitle == "" || layout.SdkLong == "" {
+			t.Errorf("Expected names for the %s SDK", language)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// errNotFound is the error for a file that isn't in the repo
+var errNotFound = errors.New("The file is not in the repo")
+
+// FileGetter gets the contents of a file in the repo,
+// by its path from the root of the repo, such as gov2/s3/metadata.yaml
+type FileGetter interface {
+	GetFile(path string) ([]byte, error)
+}
+
+// remoteFiles gets files from raw.githubusercontent.com
+type remoteFiles struct {
+	prefix string
+}
+
+func newRemoteFiles() remoteFiles {
+	return remoteFiles{prefix: "https://raw.githubusercontent.com/awsdocs/aws-doc-sdk-examples/master/"}
+}
+
+func (r remoteFiles) GetFile(path string) ([]byte, error) {
+	resp, err := http.Get(r.prefix + path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("Got status " + strconv.Itoa(resp.StatusCode) + " getting " + r.prefix + path)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// localFiles gets files from a checkout of the repo
+type localFiles struct {
+	root string
+}
+
+func (l localFiles) GetFile(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(filepath.Join(l.root, filepath.FromSlash(path)))
+	if os.IsNotExist(err) {
+		return nil, errNotFound
+	}
+
+	return b, err
+}
+
+// LocalTree returns the files and directories in a checkout of the repo
+// the way the GitHub trees API does, parents first, without the .git directory
+func LocalTree(root string) (RepoTree, error) {
+	var tree RepoTree
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path == root {
+			return nil
+		}
+
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		entry := RepoEntry{Path: filepath.ToSlash(rel), Mode: "040000", Type: "tree"}
+
+		if !d.IsDir() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			entry.Mode = "100644"
+			if info.Mode()&0111 != 0 {
+				entry.Mode = "100755"
+			}
+
+			entry.Type = "blob"
+			entry.Size = int(info.Size())
+		}
+
+		tree.Tree = append(tree.Tree, entry)
+
+		return nil
+	})
+
+	return tree, err
+}
@@ -0,0 +1,12 @@
+module mymain
+
+go 1.16
+
+require (
+	github.com/Doug-AWS/code-examples/go/dynamodb/registry v0.0.0
+	github.com/Doug-AWS/code-examples/go/utilities/metadata v0.0.0
+)
+
+replace github.com/Doug-AWS/code-examples/go/dynamodb/registry => ../../dynamodb/registry
+
+replace github.com/Doug-AWS/code-examples/go/utilities/metadata => ../metadata
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/Doug-AWS/code-examples/go/utilities/metadata"
+)
+
+// Layout is the layout of a language in the repo, with how its topics name the SDK
+type Layout struct {
+	metadata.Layout
+	// The SDK, as it's named in topic titles, such as AWS SDK for Go
+	SdkTitle string
+	// The SDK, as it's named in topic text, such as version 2 of the Go SDK
+	SdkLong string
+}
+
+// sdkNames are the titles and text names of the SDKs, by language
+var sdkNames = map[string][2]string{
+	"gov2":       {"AWS SDK for Go", "version 2 of the Go SDK"},
+	"javav2":     {"AWS SDK for Java", "version 2 of the Java SDK"},
+	"python":     {"AWS SDK for Python (Boto3)", "the AWS SDK for Python (Boto3)"},
+	"dotnetv3":   {"AWS SDK for .NET", "version 3 of the AWS SDK for .NET"},
+	"rust":       {"AWS SDK for Rust", "the developer preview of the AWS SDK for Rust"},
+	"ruby":       {"AWS SDK for Ruby", "version 3 of the AWS SDK for Ruby"},
+	"php":        {"AWS SDK for PHP", "version 3 of the AWS SDK for PHP"},
+	"typescript": {"AWS SDK for JavaScript", "version 3 of the AWS SDK for JavaScript in TypeScript"},
+}
+
+// findLayout returns the layout for the language, or nil if there isn't one
+func findLayout(language string) *Layout {
+	l := metadata.FindLayout(language)
+	if l == nil {
+		return nil
+	}
+
+	names := sdkNames[language]
+
+	return &Layout{Layout: *l, SdkTitle: names[0], SdkLong: names[1]}
+}
+
+// languageNames returns the languages there are layouts for
+func languageNames() []string {
+	names := []string{}
+	for _, l := range metadata.Layouts {
+		names = append(names, l.Language)
+	}
+
+	return names
+}
+
+// servicePath returns the path in the repo relative to the service directory,
+// such as s3/ListBuckets/ListBucketsv2.go, or false if the path isn't in it
+func (l *Layout) servicePath(p string) (string, bool) {
+	rel := strings.TrimPrefix(p, l.ServiceDir+"/")
+	if rel == p {
+		return "", false
+	}
+
+	// Skip installed packages and build output
+	for _, part := range strings.Split(rel, "/") {
+		if part == "node_modules" || part == "vendor" || part == "target" || strings.HasPrefix(part, ".") {
+			return "", false
+		}
+	}
+
+	return rel, true
+}
@@ -0,0 +1,7 @@
+// snippet-start:[s3.go-v2.ListBuckets]
+package main
+
+func main() {
+}
+
+// snippet-end:[s3.go-v2.ListBuckets]
@@ -0,0 +1,2 @@
+def question(text):
+    pass
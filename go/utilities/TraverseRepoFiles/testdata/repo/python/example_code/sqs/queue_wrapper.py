@@ -0,0 +1,4 @@
+# snippet-start:[python.example_code.sqs.CreateQueue]
+def create_queue(name):
+    pass
+# snippet-end:[python.example_code.sqs.CreateQueue]
@@ -0,0 +1,39 @@
+// Package atomicfile writes files through a temporary file in the same directory and a rename,
+// so nobody sees half a file, and a shorter file doesn't keep the end of the old one.
+package atomicfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile writes content to the file name, which has the permissions perm afterwards.
+// If it fails, the file is unchanged and the temporary file is removed.
+func WriteFile(name string, content []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(content)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	// TempFile creates the file with 0600
+	err = os.Chmod(tmp.Name(), perm)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
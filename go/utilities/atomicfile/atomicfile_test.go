@@ -0,0 +1,49 @@
+package atomicfile
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "out.txt")
+
+	err := WriteFile(name, []byte("a longer first version\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = WriteFile(name, []byte("shorter\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "shorter\n" {
+		t.Errorf("Got %q", b)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 {
+		t.Errorf("Expected only %s, got %d files", name, len(files))
+	}
+
+	if files[0].Mode().Perm() != 0644 {
+		t.Errorf("Expected mode 0644, got %v", files[0].Mode().Perm())
+	}
+
+	err = WriteFile(filepath.Join(dir, "missing", "out.txt"), []byte("x"), 0644)
+	if err == nil {
+		t.Error("Expected an error writing to a directory that isn't there")
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/Doug-AWS/code-examples/go/utilities/atomicfile
+
+go 1.15
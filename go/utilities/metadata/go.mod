@@ -0,0 +1,5 @@
+module github.com/Doug-AWS/code-examples/go/utilities/metadata
+
+go 1.15
+
+require gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
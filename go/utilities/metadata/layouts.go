@@ -0,0 +1,116 @@
+package metadata
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Layout describes where a language keeps its examples in the examples repository
+type Layout struct {
+	// The language directory, such as gov2
+	Language string `json:"language"`
+	// The ID of the SDK in go/dynamodb/registry/registry.yaml, such as py
+	Sdk string `json:"sdk"`
+	// The directory, relative to the checkout, that has a directory for each service
+	ServiceDir string `json:"serviceDir"`
+	// Whether each directory in a service directory is an operation,
+	// like gov2/s3/ListBuckets; otherwise all of a service's examples are in its directory
+	Operations bool `json:"operations"`
+	// The extensions of source files, such as .go
+	Sources []string `json:"sources"`
+	// The patterns, for path.Match, of the names of test files, such as *_test.go
+	Tests []string `json:"tests"`
+	// The version of the metadata files in the service directories that have no version line
+	MetadataVersion int `json:"metadataVersion"`
+}
+
+// Layouts are the layouts of the languages in the examples repository
+var Layouts = []Layout{
+	{Language: "gov2", Sdk: "go", ServiceDir: "gov2", Operations: true, Sources: []string{".go"}, Tests: []string{"*_test.go"}, MetadataVersion: 1},
+	{Language: "javav2", Sdk: "java", ServiceDir: "javav2/example_code", Sources: []string{".java"}, Tests: []string{"*Test.java", "*IT.java"}, MetadataVersion: 2},
+	{Language: "python", Sdk: "py", ServiceDir: "python/example_code", Sources: []string{".py"}, Tests: []string{"test_*.py", "*_test.py", "conftest.py"}, MetadataVersion: 2},
+	{Language: "dotnetv3", Sdk: "cs", ServiceDir: "dotnetv3", Operations: true, Sources: []string{".cs"}, Tests: []string{"*Test.cs", "*Tests.cs"}, MetadataVersion: 2},
+	{Language: "rust", Sdk: "rs", ServiceDir: "rust_dev_preview", Sources: []string{".rs"}, Tests: []string{"*_test.rs"}, MetadataVersion: 2},
+	{Language: "ruby", Sdk: "rb", ServiceDir: "ruby/example_code", Sources: []string{".rb"}, Tests: []string{"*_spec.rb", "*_test.rb"}, MetadataVersion: 2},
+	{Language: "php", Sdk: "php", ServiceDir: "php/example_code", Sources: []string{".php"}, Tests: []string{"*Test.php"}, MetadataVersion: 2},
+	{Language: "typescript", Sdk: "js", ServiceDir: "typescript/example_code", Sources: []string{".ts"}, Tests: []string{"*.test.ts", "*.d.ts"}, MetadataVersion: 2},
+}
+
+// FindLayout returns the layout of the language, or nil if there isn't one
+func FindLayout(language string) *Layout {
+	for i := range Layouts {
+		if Layouts[i].Language == language {
+			return &Layouts[i]
+		}
+	}
+
+	return nil
+}
+
+// IsSource returns whether the file name is an example for the layout, and not a test
+func (l Layout) IsSource(name string) bool {
+	found := false
+	for _, ext := range l.Sources {
+		if strings.HasSuffix(name, ext) {
+			found = true
+		}
+	}
+
+	if !found {
+		return false
+	}
+
+	for _, pattern := range l.Tests {
+		match, _ := path.Match(pattern, name)
+		if match {
+			return false
+		}
+	}
+
+	return true
+}
+
+// LayoutFor returns the layout of the service directory the file is in,
+// such as gov2 for /home/me/aws-doc-sdk-examples/gov2/s3/metadata.yaml,
+// or nil if it isn't in one
+func LayoutFor(file string) *Layout {
+	parts := strings.Split(filepath.ToSlash(filepath.Clean(file)), "/")
+
+	var found *Layout
+	at := -1
+
+	// The last match wins, so a checkout inside another directory named like a language still works
+	for i := range Layouts {
+		dir := strings.Split(Layouts[i].ServiceDir, "/")
+
+		// There must be a service directory after the service directories
+		for start := 0; start+len(dir) < len(parts); start++ {
+			match := true
+
+			for j, d := range dir {
+				if parts[start+j] != d {
+					match = false
+					break
+				}
+			}
+
+			if match && start >= at {
+				found = &Layouts[i]
+				at = start
+			}
+		}
+	}
+
+	return found
+}
+
+// DefaultVersion returns the version of the metadata file if it has no version line
+func DefaultVersion(file string) int {
+	l := LayoutFor(file)
+	if l == nil {
+		return Version
+	}
+
+	return l.MetadataVersion
+}
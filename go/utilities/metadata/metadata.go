@@ -0,0 +1,490 @@
+// Package metadata reads and writes the metadata.yaml files that describe the code examples
+// in each service directory:
+//
+//	version: 2
+//	files:
+//	  - path: CopyObject/CopyObjectv2.go
+//	    description: Copies an object from one bucket to another.
+//	    services:
+//	      - service: s3
+//	        actions:
+//	          - CopyObject
+//
+// Version 1 is the older format, where services is a list of names and the actions are in operations:
+//
+//	version: 1
+//	files:
+//	  - path: CopyObject/CopyObjectv2.go
+//	    services:
+//	      - s3
+//	    operations:
+//	      - CopyObject
+//
+// A file without a version is version 2, unless it's read with ParseAs or LoadAs;
+// DefaultVersion returns the version of the files without one in each language's directory.
+// Decoding is strict: an unknown field, a value of the wrong type, a missing path, service, or action,
+// or a path, service, or action listed twice is an error with the line and column it's on.
+package metadata
+
+import (
+	"errors"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the version of the schema this package writes
+const Version = 2
+
+// Service is a service and the actions of it an example calls
+type Service struct {
+	Service string   `yaml:"service" json:"service"`
+	Actions []string `yaml:"actions" json:"actions"`
+}
+
+// File is the metadata for one example file
+type File struct {
+	// The path of the file, relative to the metadata file
+	Path        string `yaml:"path" json:"path"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	// A link to the example in the SDK's documentation, if there is one
+	SdkLink  string    `yaml:"sdklink,omitempty" json:"sdklink,omitempty"`
+	Services []Service `yaml:"services" json:"services"`
+
+	// Where the file starts in the metadata it was read from
+	Line   int `yaml:"-" json:"-"`
+	Column int `yaml:"-" json:"-"`
+}
+
+// Metadata is the contents of a metadata.yaml file
+type Metadata struct {
+	Version int    `yaml:"version" json:"version"`
+	Files   []File `yaml:"files" json:"files"`
+}
+
+// Diagnostic is a problem at a line and column of a metadata file
+type Diagnostic struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return strconv.Itoa(d.Line) + ":" + strconv.Itoa(d.Column) + ": " + d.Message
+}
+
+// Error is the problems in a metadata file, in the order they're in the file
+type Error struct {
+	// The name of the file, if the metadata came from one
+	File        string
+	Diagnostics []Diagnostic
+}
+
+func (e *Error) Error() string {
+	lines := []string{}
+
+	for _, d := range e.Diagnostics {
+		s := d.String()
+		if e.File != "" {
+			s = e.File + ":" + s
+		}
+
+		lines = append(lines, s)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Load reads and checks the metadata file
+func Load(path string) (*Metadata, error) {
+	return LoadAs(path, Version)
+}
+
+// LoadAs is Load for a metadata file that's the version if it has no version line
+func LoadAs(path string, version int) (*Metadata, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := ParseAs(b, version)
+	if err != nil {
+		var e *Error
+		if errors.As(err, &e) {
+			e.File = path
+		}
+
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// syntaxLine finds the line in a YAML syntax error
+var syntaxLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// Parse reads and checks metadata; the problems it finds are returned as an *Error.
+// Version 1 metadata is converted to version 2.
+func Parse(b []byte) (*Metadata, error) {
+	return ParseAs(b, Version)
+}
+
+// ParseAs is Parse for metadata without a version line that isn't version 2,
+// such as the version 1 files the older tools wrote
+func ParseAs(b []byte, version int) (*Metadata, error) {
+	if version < 1 || version > Version {
+		return nil, errors.New("The version must be 1 or 2, not " + strconv.Itoa(version))
+	}
+
+	var doc yaml.Node
+
+	err := yaml.Unmarshal(b, &doc)
+	if err != nil {
+		d := Diagnostic{Line: 1, Column: 1, Message: strings.TrimPrefix(err.Error(), "yaml: ")}
+
+		match := syntaxLine.FindStringSubmatch(err.Error())
+		if match != nil {
+			d.Line, _ = strconv.Atoi(match[1])
+			d.Message = match[2]
+		}
+
+		return nil, &Error{Diagnostics: []Diagnostic{d}}
+	}
+
+	p := &parser{}
+	m := p.document(&doc, version)
+
+	if len(p.diagnostics) > 0 {
+		sort.SliceStable(p.diagnostics, func(i, j int) bool {
+			a, b := p.diagnostics[i], p.diagnostics[j]
+			return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+		})
+
+		return nil, &Error{Diagnostics: p.diagnostics}
+	}
+
+	return m, nil
+}
+
+// Marshal returns the metadata as version 2 YAML
+func (m *Metadata) Marshal() ([]byte, error) {
+	out := *m
+	out.Version = Version
+
+	var b strings.Builder
+
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+
+	err := encoder.Encode(out)
+	if err != nil {
+		return nil, err
+	}
+
+	err = encoder.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(b.String()), nil
+}
+
+// parser walks the YAML nodes, so every problem can be reported with its line
+type parser struct {
+	diagnostics []Diagnostic
+}
+
+func (p *parser) fail(n *yaml.Node, msg string) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{Line: n.Line, Column: n.Column, Message: msg})
+}
+
+// describe names the kind of node for error messages
+func describe(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	case yaml.AliasNode:
+		return "an alias"
+	}
+
+	if n.Tag == "!!null" {
+		return "empty"
+	}
+
+	return "the " + strings.TrimPrefix(n.Tag, "!!") + " " + strconv.Quote(n.Value)
+}
+
+// fields calls the function for each field of the mapping,
+// reporting fields that aren't in the list or that are there twice
+func (p *parser) fields(n *yaml.Node, what string, known []string, field func(name string, value *yaml.Node)) bool {
+	if n.Kind != yaml.MappingNode {
+		p.fail(n, what+" must be a mapping with "+strings.Join(known, ", ")+", not "+describe(n))
+		return false
+	}
+
+	seen := map[string]int{}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+
+		found := false
+		for _, k := range known {
+			if key.Value == k {
+				found = true
+			}
+		}
+
+		if !found {
+			p.fail(key, "Unknown field "+key.Value+" in "+what+"; expected "+strings.Join(known, ", "))
+			continue
+		}
+
+		line, ok := seen[key.Value]
+		if ok {
+			p.fail(key, key.Value+" is already set in "+what+" at line "+strconv.Itoa(line))
+			continue
+		}
+
+		seen[key.Value] = key.Line
+
+		field(key.Value, value)
+	}
+
+	return true
+}
+
+// str returns the value of a scalar, reporting anything else
+func (p *parser) str(n *yaml.Node, what string) string {
+	if n.Kind != yaml.ScalarNode || n.Tag == "!!null" {
+		p.fail(n, what+" must be a string, not "+describe(n))
+		return ""
+	}
+
+	if strings.TrimSpace(n.Value) == "" {
+		p.fail(n, what+" is empty")
+		return ""
+	}
+
+	return n.Value
+}
+
+// list returns the items of a sequence, reporting anything else
+func (p *parser) list(n *yaml.Node, what string) []*yaml.Node {
+	if n.Kind != yaml.SequenceNode {
+		p.fail(n, what+" must be a list, not "+describe(n))
+		return nil
+	}
+
+	if len(n.Content) == 0 {
+		p.fail(n, what+" is empty")
+	}
+
+	return n.Content
+}
+
+// strings returns the strings in a sequence, reporting any that are there twice
+func (p *parser) strings(n *yaml.Node, what string) []string {
+	values := []string{}
+	seen := map[string]int{}
+
+	for i, item := range p.list(n, what) {
+		s := p.str(item, what+"["+strconv.Itoa(i)+"]")
+		if s == "" {
+			continue
+		}
+
+		line, ok := seen[s]
+		if ok {
+			p.fail(item, s+" is already in "+what+" at line "+strconv.Itoa(line))
+			continue
+		}
+
+		seen[s] = item.Line
+		values = append(values, s)
+	}
+
+	return values
+}
+
+func (p *parser) document(doc *yaml.Node, version int) *Metadata {
+	m := &Metadata{Version: version, Files: []File{}}
+
+	if doc.Kind == 0 || len(doc.Content) == 0 {
+		p.diagnostics = append(p.diagnostics, Diagnostic{Line: 1, Column: 1, Message: "The metadata is empty"})
+		return m
+	}
+
+	root := doc.Content[0]
+
+	if root.Kind != yaml.MappingNode {
+		p.fail(root, "The metadata must be a mapping with version and files, not "+describe(root))
+		return m
+	}
+
+	// The version decides how the files are read, so find it first
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "version" {
+			continue
+		}
+
+		value := root.Content[i+1]
+
+		v, err := strconv.Atoi(value.Value)
+		if err != nil || value.Kind != yaml.ScalarNode || v < 1 || v > Version {
+			p.fail(value, "version must be 1 or 2, not "+describe(value))
+			return m
+		}
+
+		m.Version = v
+	}
+
+	var files *yaml.Node
+
+	p.fields(root, "the metadata", []string{"version", "files"}, func(name string, value *yaml.Node) {
+		if name == "files" {
+			files = value
+		}
+	})
+
+	if files == nil {
+		p.fail(root, "The metadata has no files")
+		return m
+	}
+
+	paths := map[string]int{}
+
+	for i, n := range p.list(files, "files") {
+		f := p.file(n, "files["+strconv.Itoa(i)+"]", m.Version)
+		if f.Path == "" {
+			continue
+		}
+
+		line, ok := paths[f.Path]
+		if ok {
+			p.fail(n, "The path "+f.Path+" is already in files at line "+strconv.Itoa(line))
+			continue
+		}
+
+		paths[f.Path] = n.Line
+		m.Files = append(m.Files, f)
+	}
+
+	m.Version = Version
+
+	return m
+}
+
+func (p *parser) file(n *yaml.Node, what string, version int) File {
+	f := File{Line: n.Line, Column: n.Column}
+
+	known := []string{"path", "description", "sdklink", "services"}
+	if version == 1 {
+		known = append(known, "operations")
+	}
+
+	var services, operations *yaml.Node
+	hasPath := false
+
+	ok := p.fields(n, what, known, func(name string, value *yaml.Node) {
+		switch name {
+		case "path":
+			hasPath = true
+			f.Path = p.str(value, what+".path")
+		case "description":
+			f.Description = p.str(value, what+".description")
+		case "sdklink":
+			f.SdkLink = p.str(value, what+".sdklink")
+		case "services":
+			services = value
+		case "operations":
+			operations = value
+		}
+	})
+
+	if !ok {
+		return f
+	}
+
+	if !hasPath {
+		p.fail(n, what+" has no path")
+	}
+
+	if services == nil {
+		p.fail(n, what+" has no services")
+		return f
+	}
+
+	if version == 1 {
+		for _, s := range p.strings(services, what+".services") {
+			f.Services = append(f.Services, Service{Service: s, Actions: []string{}})
+		}
+
+		if operations != nil {
+			actions := p.strings(operations, what+".operations")
+
+			if len(f.Services) > 1 {
+				p.fail(operations, what+" has more than one service, so its operations can't be matched to a service; use version 2")
+			} else if len(f.Services) == 1 {
+				f.Services[0].Actions = actions
+			}
+		}
+
+		return f
+	}
+
+	seen := map[string]int{}
+
+	for i, sn := range p.list(services, what+".services") {
+		sWhat := what + ".services[" + strconv.Itoa(i) + "]"
+
+		if sn.Kind == yaml.ScalarNode {
+			p.fail(sn, sWhat+" must be a mapping with service and actions, not "+describe(sn)+"; add version: 1 at the top of metadata in the older format")
+			continue
+		}
+
+		s := Service{}
+		var actions *yaml.Node
+
+		ok := p.fields(sn, sWhat, []string{"service", "actions"}, func(name string, value *yaml.Node) {
+			switch name {
+			case "service":
+				s.Service = p.str(value, sWhat+".service")
+			case "actions":
+				actions = value
+			}
+		})
+
+		if !ok {
+			continue
+		}
+
+		if s.Service == "" {
+			p.fail(sn, sWhat+" has no service")
+			continue
+		}
+
+		line, dup := seen[s.Service]
+		if dup {
+			p.fail(sn, "The service "+s.Service+" is already in "+what+" at line "+strconv.Itoa(line))
+			continue
+		}
+
+		seen[s.Service] = sn.Line
+
+		if actions == nil {
+			p.fail(sn, sWhat+" has no actions")
+			continue
+		}
+
+		s.Actions = p.strings(actions, sWhat+".actions")
+		f.Services = append(f.Services, s)
+	}
+
+	return f
+}
@@ -0,0 +1,210 @@
+package metadata
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	input := `version: 2
+files:
+  - path: CopyObject/CopyObjectv2.go
+    description: Copies an object from one bucket to another.
+    services:
+      - service: s3
+        actions:
+          - CopyObject
+  - path: Publish/Publishv2.go
+    sdklink: https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns/publish/
+    services:
+      - service: sns
+        actions: [Publish]
+      - service: sqs
+        actions: [ReceiveMessage, DeleteMessage]
+`
+
+	m, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Metadata{
+		Version: 2,
+		Files: []File{
+			{
+				Path:        "CopyObject/CopyObjectv2.go",
+				Description: "Copies an object from one bucket to another.",
+				Services:    []Service{{Service: "s3", Actions: []string{"CopyObject"}}},
+				Line:        3,
+				Column:      5,
+			},
+			{
+				Path:    "Publish/Publishv2.go",
+				SdkLink: "https://aws.github.io/aws-sdk-go-v2/docs/code-examples/sns/publish/",
+				Services: []Service{
+					{Service: "sns", Actions: []string{"Publish"}},
+					{Service: "sqs", Actions: []string{"ReceiveMessage", "DeleteMessage"}},
+				},
+				Line:   9,
+				Column: 5,
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("Got %+v", m)
+	}
+
+	// Writing it and reading it again gives the same metadata
+	b, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := Parse(b)
+	if err != nil {
+		t.Fatalf("Got %v parsing:\n%s", err, b)
+	}
+
+	for i := range again.Files {
+		again.Files[i].Line = m.Files[i].Line
+		again.Files[i].Column = m.Files[i].Column
+	}
+
+	if !reflect.DeepEqual(again, m) {
+		t.Errorf("Got %+v from:\n%s", again, b)
+	}
+}
+
+func TestParseVersion1(t *testing.T) {
+	input := `version: 1
+files:
+  - path: CreateTable/CreateTablev2.go
+    services:
+      - dynamodb
+    operations:
+      - CreateTable
+`
+
+	m, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Service{{Service: "dynamodb", Actions: []string{"CreateTable"}}}
+
+	if m.Version != Version || !reflect.DeepEqual(m.Files[0].Services, want) {
+		t.Errorf("Got %+v", m)
+	}
+
+	// The same metadata without the version line
+	m, err = ParseAs([]byte(strings.TrimPrefix(input, "version: 1\n")), 1)
+	if err != nil || !reflect.DeepEqual(m.Files[0].Services, want) {
+		t.Errorf("Got %+v and %v without a version", m, err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"empty", "", []string{"1:1: The metadata is empty"}},
+		{"syntax", "files:\n  - path: a.go\n    description: A: B\n", []string{"3:1: mapping values are not allowed in this context"}},
+		{"unknown field", "files:\n  - path: a.go\n    desription: A\n    services:\n      - service: s3\n        actions: [GetObject]\n",
+			[]string{"3:5: Unknown field desription in files[0]"}},
+		{"no path", "files:\n  - services:\n      - service: s3\n        actions: [GetObject]\n",
+			[]string{"2:5: files[0] has no path"}},
+		{"duplicate path", "files:\n  - path: a.go\n    services:\n      - service: s3\n        actions: [GetObject]\n  - path: a.go\n    services:\n      - service: s3\n        actions: [PutObject]\n",
+			[]string{"6:5: The path a.go is already in files at line 2"}},
+		{"duplicate action", "files:\n  - path: a.go\n    services:\n      - service: s3\n        actions: [GetObject, GetObject]\n",
+			[]string{"5:30: GetObject is already in files[0].services[0].actions at line 5"}},
+		{"version 1 services", "files:\n  - path: a.go\n    services:\n      - s3\n",
+			[]string{"4:9: files[0].services[0] must be a mapping", "add version: 1"}},
+		{"bad version", "version: 3\nfiles: []\n", []string{"1:10: version must be 1 or 2"}},
+		{"operations for two services", "version: 1\nfiles:\n  - path: a.go\n    services: [s3, sns]\n    operations: [GetObject]\n",
+			[]string{"5:17: files[0] has more than one service"}},
+	}
+
+	for _, test := range tests {
+		_, err := Parse([]byte(test.input))
+
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Errorf("%s: expected an *Error, got %v", test.name, err)
+			continue
+		}
+
+		for _, s := range test.want {
+			if !strings.Contains(err.Error(), s) {
+				t.Errorf("%s: expected %q in:\n%s", test.name, s, err.Error())
+			}
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metadata.yaml")
+
+	err := ioutil.WriteFile(path, []byte("files:\n  - path: a.go\n    services: []\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Load(path)
+	if err == nil || !strings.HasPrefix(err.Error(), path+":3:15: files[0].services is empty") {
+		t.Errorf("Expected the error to start with the file name, got %v", err)
+	}
+}
+
+func TestDefaultVersion(t *testing.T) {
+	tests := map[string]int{
+		"gov2/s3/metadata.yaml":                                    1,
+		"/home/me/aws-doc-sdk-examples/gov2/s3/metadata.yaml":      1,
+		"testdata/repo/gov2/s3":                                    1,
+		"python/example_code/sqs/.metadata.yaml":                   2,
+		"metadata.yaml":                                            Version,
+		filepath.Join("javav2", "example_code", "s3", "test.yaml"): 2,
+	}
+
+	for path, want := range tests {
+		got := DefaultVersion(path)
+		if got != want {
+			t.Errorf("Expected version %d for %s, got %d", want, path, got)
+		}
+	}
+
+	if FindLayout("gov2") == nil || FindLayout("cobol") != nil {
+		t.Error("Expected a layout for gov2 and none for cobol")
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	b, err := JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var schema map[string]interface{}
+
+	err = json.Unmarshal(b, &schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if schema["additionalProperties"] != false || schema["definitions"].(map[string]interface{})["file"] == nil {
+		t.Errorf("Got:\n%s", b)
+	}
+
+	// The copy editors use must be current
+	file, err := ioutil.ReadFile("metadata.schema.json")
+	if err != nil || string(file) != string(b) {
+		t.Error("metadata.schema.json is not current; run go run . schema -o ../metadata/metadata.schema.json in MetadataTool")
+	}
+}
@@ -0,0 +1,78 @@
+package metadata
+
+import (
+	"encoding/json"
+)
+
+// schemaID identifies the schema
+const schemaID = "https://github.com/Doug-AWS/code-examples/go/utilities/metadata/metadata.schema.json"
+
+type object map[string]interface{}
+
+// nonEmptyString is a string with something in it
+var nonEmptyString = object{"type": "string", "minLength": 1}
+
+// JSONSchema returns a draft-07 JSON Schema for version 2 metadata,
+// for editors and other tools that check YAML against a schema.
+// It can't express that paths and service names are unique, which Parse checks.
+func JSONSchema() ([]byte, error) {
+	service := object{
+		"type":                 "object",
+		"additionalProperties": false,
+		"required":             []string{"service", "actions"},
+		"properties": object{
+			"service": object{"type": "string", "minLength": 1, "description": "The service, such as s3"},
+			"actions": object{
+				"type":        "array",
+				"items":       nonEmptyString,
+				"minItems":    1,
+				"uniqueItems": true,
+				"description": "The actions of the service the example calls, such as CopyObject",
+			},
+		},
+	}
+
+	file := object{
+		"type":                 "object",
+		"additionalProperties": false,
+		"required":             []string{"path", "services"},
+		"properties": object{
+			"path":        object{"type": "string", "minLength": 1, "description": "The path of the file, relative to the metadata file"},
+			"description": object{"type": "string", "minLength": 1},
+			"sdklink":     object{"type": "string", "minLength": 1, "description": "A link to the example in the SDK's documentation"},
+			"services": object{
+				"type":     "array",
+				"items":    object{"$ref": "#/definitions/service"},
+				"minItems": 1,
+			},
+		},
+	}
+
+	schema := object{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"$id":                  schemaID,
+		"title":                "Code example metadata",
+		"type":                 "object",
+		"additionalProperties": false,
+		"required":             []string{"files"},
+		"properties": object{
+			"version": object{"const": Version},
+			"files": object{
+				"type":     "array",
+				"items":    object{"$ref": "#/definitions/file"},
+				"minItems": 1,
+			},
+		},
+		"definitions": object{
+			"file":    file,
+			"service": service,
+		},
+	}
+
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}